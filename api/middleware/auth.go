@@ -0,0 +1,220 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fs714/github-star-manager/db/jsondb"
+	"github.com/fs714/github-star-manager/pkg/auth"
+	"github.com/fs714/github-star-manager/pkg/config"
+	"github.com/fs714/github-star-manager/pkg/tenant"
+	"github.com/fs714/github-star-manager/pkg/utils/code"
+	"github.com/fs714/github-star-manager/pkg/utils/log"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+const (
+	userKey     = "gsm.user"
+	tenantKey   = "gsm.tenant"
+	apiTokenKey = "gsm.api_token"
+)
+
+// AuthWithSkipPath authenticates requests by basic auth with password, or by api token in
+// header "Authorization: Bearer <token>" or "Authorization: token <token>". The user, tenant
+// of the user and api token are saved into context, requests of paths in skipPath are not
+// authenticated. users is nil if auth is disabled, then only api tokens of the server database
+// are accepted, and they are required once any of them is created.
+func AuthWithSkipPath(users *auth.UserStore, manager *tenant.Manager, skipPath []string) gin.HandlerFunc {
+	skipPathMap := make(map[string]bool, len(skipPath))
+	for _, path := range skipPath {
+		skipPathMap[path] = true
+	}
+
+	return func(c *gin.Context) {
+		if _, ok := skipPathMap[c.Request.URL.Path]; ok {
+			c.Next()
+			return
+		}
+
+		user, t, token, err := authenticate(users, manager, c.Request)
+		if errors.Is(err, auth.ErrUnauthorized) {
+			if users != nil {
+				c.Header("WWW-Authenticate", `Basic realm="github-star-manager"`)
+			} else {
+				c.Header("WWW-Authenticate", `Bearer realm="github-star-manager"`)
+			}
+			abort(c, http.StatusUnauthorized, code.RespUnauthorized, "unauthorized")
+			log.Debugw("failed to authenticate request", "path", c.Request.URL.Path, "err", err.Error())
+			return
+		} else if err != nil {
+			abort(c, http.StatusInternalServerError, code.RespCommonError, "failed to authenticate request")
+			log.Errorf("failed to authenticate request:\n%+v", err)
+			return
+		}
+
+		if user != nil {
+			c.Set(userKey, user)
+		}
+		c.Set(tenantKey, t)
+		if token != nil {
+			c.Set(apiTokenKey, token)
+		}
+		c.Next()
+	}
+}
+
+func authenticate(users *auth.UserStore, manager *tenant.Manager,
+	r *http.Request) (*auth.User, *tenant.Tenant, *jsondb.APIToken, error) {
+	if users == nil {
+		t, token, err := authenticateDefault(r)
+		return nil, t, token, err
+	}
+
+	if name, password, ok := r.BasicAuth(); ok {
+		user, err := users.Authenticate(name, password)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		t, err := manager.Get(user.Name)
+		return user, t, nil, err
+	}
+
+	value, err := bearerToken(r)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// tokens of server database belong to admin user
+	name, err := auth.ParseToken(value)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if name == "" {
+		name = config.Config.Auth.AdminUser
+	}
+
+	user, err := users.Get(name)
+	if errors.Is(err, auth.ErrUserNotFound) {
+		return nil, nil, nil, auth.ErrUnauthorized
+	} else if err != nil {
+		return nil, nil, nil, err
+	}
+
+	t, err := manager.Get(user.Name)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	token, err := auth.AuthenticateAPIToken(t.Store, value, time.Now())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return user, t, token, nil
+}
+
+// authenticateDefault authenticates request to the server database when auth is disabled,
+// requests without token are allowed until the first api token is created.
+func authenticateDefault(r *http.Request) (*tenant.Tenant, *jsondb.APIToken, error) {
+	t := tenant.Default()
+	if r.Header.Get("Authorization") == "" {
+		if len(t.Store.GetAPITokens()) > 0 {
+			return nil, nil, auth.ErrUnauthorized
+		}
+
+		return t, nil, nil
+	}
+
+	value, err := bearerToken(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	name, err := auth.ParseToken(value)
+	if err != nil {
+		return nil, nil, err
+	} else if name != "" {
+		return nil, nil, auth.ErrUnauthorized
+	}
+
+	token, err := auth.AuthenticateAPIToken(t.Store, value, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return t, token, nil
+}
+
+func bearerToken(r *http.Request) (string, error) {
+	scheme, value, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if s := strings.ToLower(scheme); s != "bearer" && s != "token" {
+		return "", auth.ErrUnauthorized
+	}
+
+	return strings.TrimSpace(value), nil
+}
+
+// RequireScope rejects requests authenticated by api token without scope. Requests
+// authenticated by password have every scope, so do requests without token if auth is disabled
+// and no api token is created.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := CurrentAPIToken(c); token != nil && !token.HasScope(scope) {
+			abort(c, http.StatusForbidden, code.RespForbidden, "api token does not have scope "+scope)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireAdmin rejects users who are not admin.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if user := CurrentUser(c); user == nil || !user.Admin {
+			abort(c, http.StatusForbidden, code.RespForbidden, "admin is required")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// CurrentUser returns the authenticated user, nil if auth is disabled.
+func CurrentUser(c *gin.Context) *auth.User {
+	if v, ok := c.Get(userKey); ok {
+		return v.(*auth.User)
+	}
+
+	return nil
+}
+
+// CurrentTenant returns tenant of the authenticated user, tenant.Default() if auth is disabled.
+func CurrentTenant(c *gin.Context) *tenant.Tenant {
+	if v, ok := c.Get(tenantKey); ok {
+		return v.(*tenant.Tenant)
+	}
+
+	return tenant.Default()
+}
+
+// CurrentAPIToken returns the api token authenticating request, nil if request is authenticated
+// by password or without token.
+func CurrentAPIToken(c *gin.Context) *jsondb.APIToken {
+	if v, ok := c.Get(apiTokenKey); ok {
+		return v.(*jsondb.APIToken)
+	}
+
+	return nil
+}
+
+func abort(c *gin.Context, status int, respCode code.RespCode, msg string) {
+	c.AbortWithStatusJSON(status, gin.H{
+		"status": respCode,
+		"msg":    msg,
+		"data":   "",
+	})
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/fs714/github-star-manager/db/jsondb"
+	"github.com/fs714/github-star-manager/pkg/auth"
+	"github.com/fs714/github-star-manager/pkg/config"
+	"github.com/fs714/github-star-manager/pkg/tenant"
+	"github.com/fs714/github-star-manager/pkg/utils/code"
+	"github.com/fs714/github-star-manager/pkg/utils/log"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+const shareKey = "gsm.share"
+
+// Share finds the share of token in path param, and saves the share and tenant owning it into
+// context. users is nil if auth is disabled, then only shares of server database are found.
+// Unknown, expired and revoked shares are not found.
+func Share(users *auth.UserStore, manager *tenant.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t, share, err := findShare(users, manager, c.Param("token"))
+		if errors.Is(err, auth.ErrUnauthorized) {
+			abort(c, http.StatusNotFound, code.RespNotFound, "share not found")
+			return
+		} else if err != nil {
+			abort(c, http.StatusInternalServerError, code.RespCommonError, "failed to find share")
+			log.Errorf("failed to find share:\n%+v", err)
+			return
+		}
+
+		c.Set(tenantKey, t)
+		c.Set(shareKey, share)
+		c.Next()
+	}
+}
+
+func findShare(users *auth.UserStore, manager *tenant.Manager, token string) (*tenant.Tenant, *jsondb.Share, error) {
+	name, err := auth.ParseShareToken(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	t := tenant.Default()
+	if users != nil {
+		// shares of server database belong to admin user
+		if name == "" {
+			name = config.Config.Auth.AdminUser
+		}
+
+		_, err = users.Get(name)
+		if errors.Is(err, auth.ErrUserNotFound) {
+			return nil, nil, auth.ErrUnauthorized
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		t, err = manager.Get(name)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else if name != "" {
+		return nil, nil, auth.ErrUnauthorized
+	}
+
+	share, err := auth.AuthenticateShare(t.Store, token, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return t, share, nil
+}
+
+// CurrentShare returns the share found by Share.
+func CurrentShare(c *gin.Context) *jsondb.Share {
+	if v, ok := c.Get(shareKey); ok {
+		return v.(*jsondb.Share)
+	}
+
+	return nil
+}
@@ -3,7 +3,9 @@ package api
 import (
 	"github.com/fs714/github-star-manager/api/middleware"
 	"github.com/fs714/github-star-manager/api/v1/public"
+	"github.com/fs714/github-star-manager/pkg/auth"
 	"github.com/fs714/github-star-manager/pkg/config"
+	"github.com/fs714/github-star-manager/pkg/tenant"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/gzip"
 	"github.com/gin-contrib/pprof"
@@ -17,17 +19,45 @@ func InitRouter() *gin.Engine {
 	r.Use(middleware.LogWithSkipPath([]string{}))
 	r.Use(gzip.Gzip(gzip.DefaultCompression, gzip.WithDecompressFn(gzip.DefaultDecompressHandle)))
 	r.Use(gin.Recovery())
-	r.Use(cors.Default())
+	if len(config.Config.HttpServer.CorsAllowOrigins) > 0 {
+		r.Use(newCors(config.Config.HttpServer.CorsAllowOrigins))
+	}
 
 	if config.Config.Common.Profiling {
 		pprof.Register(r)
 	}
 
-	// no authentication
-	v1PublicGroup := r.Group("")
+	// requests except health check are authenticated, routes check scopes of api tokens. Only api
+	// tokens of the server database are accepted if auth is disabled.
+	var users *auth.UserStore
+	if config.Config.Auth.Enabled {
+		users = auth.CurrentUserStore()
+	}
+	v1PublicGroup := r.Group("", middleware.AuthWithSkipPath(users, tenant.CurrentManager(),
+		[]string{"/api/v1/health"}))
 	{
 		public.InitRoute(v1PublicGroup)
 	}
 
+	// share links are authenticated by their own token and read-only
+	sharedGroup := r.Group("")
+	{
+		public.InitShareRoute(sharedGroup, middleware.Share(users, tenant.CurrentManager()))
+	}
+
 	return r
 }
+
+func newCors(origins []string) gin.HandlerFunc {
+	cfg := cors.DefaultConfig()
+	cfg.AllowHeaders = append(cfg.AllowHeaders, "Authorization")
+	for _, origin := range origins {
+		if origin == "*" {
+			cfg.AllowAllOrigins = true
+			return cors.New(cfg)
+		}
+	}
+	cfg.AllowOrigins = origins
+
+	return cors.New(cfg)
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/fs714/github-star-manager/db"
+	"github.com/fs714/github-star-manager/pkg/config"
+)
+
+func doRequest(r http.Handler, method string, url string, body interface{}, token string) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest(method, url, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestAPITokenWithoutAuth(t *testing.T) {
+	old := config.Config
+	t.Cleanup(func() { config.Config = old })
+	config.Config = config.GetDefaultConfig()
+
+	s, err := db.InitStore(config.Database{Driver: db.DriverJson, Path: filepath.Join(t.TempDir(), "db.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	db.Storage = s
+
+	r := InitRouter()
+
+	// requests are not authenticated until the first api token is created
+	if w := doRequest(r, http.MethodGet, "/api/v1/repo", nil, ""); w.Code != http.StatusOK {
+		t.Fatalf("get repos without token returns %d: %s", w.Code, w.Body.String())
+	}
+
+	w := doRequest(r, http.MethodPost, "/api/v1/tokens", map[string]interface{}{"Scopes": []string{"repo:read"}}, "")
+	var resp struct {
+		Data struct {
+			Token string
+		} `json:"data"`
+	}
+	if err = json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("create api token returns %d: %s", w.Code, w.Body.String())
+	}
+	token := resp.Data.Token
+
+	cases := []struct {
+		method string
+		url    string
+		body   interface{}
+		token  string
+		status int
+	}{
+		{http.MethodGet, "/api/v1/health", nil, "", http.StatusOK},
+		{http.MethodGet, "/api/v1/repo", nil, "", http.StatusUnauthorized},
+		{http.MethodGet, "/api/v1/repo", nil, "gsm_wrong", http.StatusUnauthorized},
+		{http.MethodGet, "/api/v1/repo", nil, token, http.StatusOK},
+		{http.MethodPost, "/api/v1/repo", map[string]interface{}{"Name": "fs714/gsm"}, token, http.StatusForbidden},
+		{http.MethodDelete, "/api/v1/repo/fs714/gsm", nil, token, http.StatusForbidden},
+		{http.MethodPut, "/api/v1/github/token", map[string]interface{}{"Token": "secret"}, token, http.StatusForbidden},
+		{http.MethodPost, "/api/v1/tokens", map[string]interface{}{"Scopes": []string{"admin"}}, "", http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		if w := doRequest(r, c.method, c.url, c.body, c.token); w.Code != c.status {
+			t.Errorf("%s %s returns %d, expected %d: %s", c.method, c.url, w.Code, c.status, w.Body.String())
+		}
+	}
+}
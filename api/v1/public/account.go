@@ -0,0 +1,18 @@
+package public
+
+import (
+	"net/http"
+
+	"github.com/fs714/github-star-manager/pkg/utils/code"
+	"github.com/gin-gonic/gin"
+)
+
+// GetAccounts lists synced github accounts with number of repositories they star, repositories
+// of accounts are listed by GetRepos with query account.
+func GetAccounts(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data":   storeOf(c).GetAccountCounts(),
+	})
+}
@@ -1,16 +1,101 @@
 package public
 
 import (
+	"github.com/fs714/github-star-manager/api/middleware"
+	"github.com/fs714/github-star-manager/db"
+	"github.com/fs714/github-star-manager/db/jsondb"
+	"github.com/fs714/github-star-manager/pkg/config"
+	"github.com/fs714/github-star-manager/pkg/tenant"
 	"github.com/gin-gonic/gin"
 )
 
+// InitRoute registers routes by scope an api token needs, see middleware.RequireScope.
 func InitRoute(Router *gin.RouterGroup) gin.IRoutes {
 	baseRoute := Router.Group("/api/v1")
 	{
 		baseRoute.GET("health", Health)
-		baseRoute.POST("github/sync", SyncFromGithub)
-		baseRoute.GET("repo", GetRepos)
+	}
+
+	readRoute := baseRoute.Group("", middleware.RequireScope(jsondb.ScopeRepoRead))
+	{
+		readRoute.GET("github/sync/history", GetSyncHistory)
+		readRoute.GET("github/sync/history/:id", GetSyncHistoryByID)
+		readRoute.GET("accounts", GetAccounts)
+		readRoute.GET("jobs", GetJobs)
+		readRoute.GET("jobs/:id", GetJob)
+		readRoute.GET("github/token", GetGithubToken)
+		readRoute.GET("repo", GetRepos)
+		readRoute.GET("repo/:owner/:name", GetRepo)
+		readRoute.GET("search", Search)
+		readRoute.GET("folders", GetFolders)
+		readRoute.GET("tags", GetTags)
+		readRoute.GET("db/integrity", CheckDbIntegrity)
+		readRoute.GET("shares", GetShares)
+	}
+
+	writeRoute := baseRoute.Group("", middleware.RequireScope(jsondb.ScopeRepoWrite))
+	{
+		writeRoute.POST("repo", CreateRepo)
+		writeRoute.PUT("repo/:owner/:name", ReplaceRepo)
+		writeRoute.PATCH("repo/:owner/:name", PatchRepo)
+		writeRoute.DELETE("repo/:owner/:name", DeleteRepo)
+		writeRoute.POST("repo/:owner/:name/restar", RestarRepo)
+		writeRoute.POST("repo/:owner/:name/star", StarRepo)
+		writeRoute.POST("repo/:owner/:name/unstar", UnstarRepo)
+		writeRoute.DELETE("unstarred", PurgeUnstarred)
+		writeRoute.POST("folders", CreateFolder)
+		writeRoute.POST("folders/rename", RenameFolder)
+		writeRoute.POST("folders/move", MoveFolder)
+		writeRoute.DELETE("folders", DeleteFolder)
+		writeRoute.PUT("tags/:tag", RenameTag)
+		writeRoute.DELETE("tags/:tag", DeleteTag)
+		writeRoute.POST("tags/merge", MergeTags)
+		writeRoute.POST("tags/bulk", BulkUpdateTags)
+		writeRoute.POST("db/integrity/repair", RepairDbIntegrity)
+		writeRoute.POST("shares", CreateShare)
+		writeRoute.DELETE("shares/:id", DeleteShare)
+	}
+
+	syncRoute := baseRoute.Group("", middleware.RequireScope(jsondb.ScopeSync))
+	{
+		syncRoute.POST("github/sync", SyncFromGithub)
+		syncRoute.POST("jobs/:id/cancel", CancelJob)
+	}
+
+	// admin scope manages credentials of the user, and other users if the user is admin
+	adminRoute := baseRoute.Group("", middleware.RequireScope(jsondb.ScopeAdmin))
+	{
+		adminRoute.PUT("github/token", UpdateGithubToken)
+		adminRoute.GET("tokens", GetAPITokens)
+		adminRoute.POST("tokens", CreateAPIToken)
+		adminRoute.DELETE("tokens/:id", DeleteAPIToken)
+	}
+
+	// scheduled sync only writes the server database, so its schedule is only for admin users if
+	// auth is enabled
+	if config.Config.Auth.Enabled {
+		readRoute.GET("github/sync/schedule", middleware.RequireAdmin(), GetSyncSchedule)
+		readRoute.GET("me", GetMe)
+		adminRoute.PATCH("me", UpdateMe)
+
+		userRoute := adminRoute.Group("", middleware.RequireAdmin())
+		userRoute.GET("users", GetUsers)
+		userRoute.POST("users", CreateUser)
+		userRoute.GET("users/:name", GetUser)
+		userRoute.PATCH("users/:name", UpdateUser)
+		userRoute.DELETE("users/:name", DeleteUser)
+	} else {
+		readRoute.GET("github/sync/schedule", GetSyncSchedule)
 	}
 
 	return baseRoute
 }
+
+// tenantOf returns data of the authenticated user, or the server database if auth is disabled.
+func tenantOf(c *gin.Context) *tenant.Tenant {
+	return middleware.CurrentTenant(c)
+}
+
+func storeOf(c *gin.Context) db.Store {
+	return tenantOf(c).Store
+}
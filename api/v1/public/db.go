@@ -0,0 +1,60 @@
+package public
+
+import (
+	"net/http"
+
+	"github.com/fs714/github-star-manager/pkg/utils/code"
+	"github.com/fs714/github-star-manager/pkg/utils/log"
+	"github.com/gin-gonic/gin"
+)
+
+func CheckDbIntegrity(c *gin.Context) {
+	doCheckDbIntegrity(c, false)
+}
+
+func RepairDbIntegrity(c *gin.Context) {
+	doCheckDbIntegrity(c, true)
+}
+
+func doCheckDbIntegrity(c *gin.Context, repair bool) {
+	problems, remaining, err := storeOf(c).CheckIntegrity(repair)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": code.RespCommonError,
+			"msg":    "failed to check db integrity",
+			"data":   "",
+		})
+
+		log.Errorf("failed to check db integrity:\n%+v", err)
+		return
+	}
+
+	// problems left after rebuilding indexes, like duplicated repositories, are not repaired
+	repaired := make([]string, 0)
+	if repair {
+		left := make(map[string]bool)
+		for _, p := range remaining {
+			left[p] = true
+		}
+
+		for _, p := range problems {
+			if !left[p] {
+				repaired = append(repaired, p)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		log.Warnw("db integrity problems found", "problems", problems, "repaired", repaired, "remaining", remaining)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data": gin.H{
+			"Problems":  problems,
+			"Repaired":  repaired,
+			"Remaining": remaining,
+		},
+	})
+}
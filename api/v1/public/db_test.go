@@ -0,0 +1,47 @@
+package public
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/fs714/github-star-manager/db"
+	"github.com/fs714/github-star-manager/db/jsondb"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRepairDbIntegrity(t *testing.T) {
+	r := newTestRouter(t)
+
+	for _, repo := range []gin.H{
+		{"Name": "fs714/gsm", "Path": []string{"tool"}},
+		{"Name": "fs714/web", "Path": []string{"web"}},
+	} {
+		if w := doRequest(r, http.MethodPost, "/api/v1/repo", repo); w.Code != http.StatusCreated {
+			t.Fatalf("create repo returns %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	// a drifted index is repaired, a duplicated repository is not
+	repos := db.Storage.GetRepositories([]string{})
+	delete(repos.NameIndexes, "fs714/web")
+	web := repos.Get([]string{"web"})
+	web.Repositories = append(web.Repositories, &jsondb.Repository{Name: "fs714/gsm"})
+
+	w := doRequest(r, http.MethodPost, "/api/v1/db/integrity/repair", nil)
+	var resp struct {
+		Data struct {
+			Problems  []string
+			Repaired  []string
+			Remaining []string
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("repair returns %d: %s", w.Code, w.Body.String())
+	}
+
+	if len(resp.Data.Repaired) != 1 || resp.Data.Repaired[0] != "name index of fs714/web is missing" ||
+		len(resp.Data.Remaining) != 1 || resp.Data.Remaining[0] != "repository fs714/gsm is duplicated at tool[0], web[1]" {
+		t.Errorf("repair result is %s", w.Body.String())
+	}
+}
@@ -0,0 +1,55 @@
+package public
+
+import (
+	"net/http"
+
+	"github.com/fs714/github-star-manager/db/jsondb"
+	"github.com/fs714/github-star-manager/pkg/auth"
+	"github.com/fs714/github-star-manager/pkg/github_api"
+	"github.com/fs714/github-star-manager/pkg/job"
+	"github.com/fs714/github-star-manager/pkg/utils/code"
+	"github.com/fs714/github-star-manager/pkg/utils/log"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+var ErrInvalidParameter = errors.New("invalid parameter")
+
+// respondError maps err to http status and response code, msg is returned to client.
+func respondError(c *gin.Context, msg string, err error) {
+	status := http.StatusInternalServerError
+	respCode := code.RespCommonError
+
+	switch {
+	case errors.Is(err, ErrInvalidParameter), errors.Is(err, jsondb.ErrInvalidPath),
+		errors.Is(err, auth.ErrInvalidUser), errors.Is(err, jsondb.ErrInvalidScope):
+		status = http.StatusBadRequest
+		respCode = code.RespInvalidParameter
+	case errors.Is(err, jsondb.ErrRepositoryNotFound), errors.Is(err, jsondb.ErrPathNotFound),
+		errors.Is(err, job.ErrJobNotFound), errors.Is(err, jsondb.ErrSyncHistoryNotFound),
+		errors.Is(err, github_api.ErrRepoNotFound), errors.Is(err, auth.ErrUserNotFound),
+		errors.Is(err, jsondb.ErrAPITokenNotFound), errors.Is(err, jsondb.ErrShareNotFound),
+		errors.Is(err, jsondb.ErrTagNotFound):
+		status = http.StatusNotFound
+		respCode = code.RespNotFound
+	case errors.Is(err, jsondb.ErrRepositoryExists), errors.Is(err, jsondb.ErrFolderExists),
+		errors.Is(err, job.ErrJobRunning), errors.Is(err, job.ErrJobFinished), errors.Is(err, auth.ErrUserExists):
+		status = http.StatusConflict
+		respCode = code.RespConflict
+	case errors.Is(err, auth.ErrUnauthorized):
+		status = http.StatusUnauthorized
+		respCode = code.RespUnauthorized
+	}
+
+	c.JSON(status, gin.H{
+		"status": respCode,
+		"msg":    msg,
+		"data":   "",
+	})
+
+	if status == http.StatusInternalServerError {
+		log.Errorf("%s:\n%+v", msg, err)
+	} else {
+		log.Debugw(msg, "err", err.Error())
+	}
+}
@@ -0,0 +1,140 @@
+package public
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/fs714/github-star-manager/pkg/utils/code"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+type folderPostData struct {
+	Path   []string
+	Name   string
+	Parent []string
+}
+
+func GetFolders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data":   storeOf(c).GetFolderTree(),
+	})
+}
+
+func CreateFolder(c *gin.Context) {
+	postData, err := bindFolderPostData(c)
+	if err != nil {
+		respondError(c, err.Error(), err)
+		return
+	}
+
+	err = storeOf(c).CreateFolder(postData.Path)
+	if err != nil {
+		respondError(c, "failed to create folder", err)
+		return
+	}
+
+	respondFolders(c, http.StatusCreated)
+}
+
+func RenameFolder(c *gin.Context) {
+	postData, err := bindFolderPostData(c)
+	if err != nil {
+		respondError(c, err.Error(), err)
+		return
+	}
+
+	err = validatePath([]string{postData.Name})
+	if err != nil {
+		respondError(c, err.Error(), err)
+		return
+	}
+
+	err = storeOf(c).RenameFolder(postData.Path, postData.Name)
+	if err != nil {
+		respondError(c, "failed to rename folder", err)
+		return
+	}
+
+	respondFolders(c, http.StatusOK)
+}
+
+func MoveFolder(c *gin.Context) {
+	postData, err := bindFolderPostData(c)
+	if err != nil {
+		respondError(c, err.Error(), err)
+		return
+	}
+
+	err = validatePath(postData.Parent)
+	if err != nil {
+		respondError(c, err.Error(), err)
+		return
+	}
+
+	err = storeOf(c).MoveFolder(postData.Path, normalizePath(postData.Parent))
+	if err != nil {
+		respondError(c, "failed to move folder", err)
+		return
+	}
+
+	respondFolders(c, http.StatusOK)
+}
+
+func DeleteFolder(c *gin.Context) {
+	path := parsePath(c.Query("path"))
+	if len(path) == 0 {
+		err := errors.WithMessage(ErrInvalidParameter, "path of folder should not be empty")
+		respondError(c, err.Error(), err)
+		return
+	}
+
+	err := storeOf(c).DeleteFolder(path, c.Query("cascade") == "true")
+	if err != nil {
+		respondError(c, "failed to delete folder", err)
+		return
+	}
+
+	respondFolders(c, http.StatusOK)
+}
+
+func bindFolderPostData(c *gin.Context) (*folderPostData, error) {
+	var postData folderPostData
+	err := c.ShouldBindJSON(&postData)
+	if err != nil {
+		return nil, errors.WithMessage(ErrInvalidParameter, "failed to bind post json to struct")
+	}
+
+	if len(postData.Path) == 0 {
+		return nil, errors.WithMessage(ErrInvalidParameter, "path of folder should not be empty")
+	}
+
+	err = validatePath(postData.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &postData, nil
+}
+
+func respondFolders(c *gin.Context, status int) {
+	c.JSON(status, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data":   storeOf(c).GetFolderTree(),
+	})
+}
+
+// parsePath splits folder path like linux/ebpf used in query string
+func parsePath(s string) []string {
+	path := make([]string, 0)
+	for _, p := range strings.Split(s, "/") {
+		if p != "" {
+			path = append(path, p)
+		}
+	}
+
+	return path
+}
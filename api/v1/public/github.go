@@ -3,128 +3,101 @@ package public
 import (
 	"net/http"
 
-	"github.com/fs714/github-star-manager/db/jsondb"
 	"github.com/fs714/github-star-manager/pkg/github_api"
+	"github.com/fs714/github-star-manager/pkg/job"
+	"github.com/fs714/github-star-manager/pkg/syncer"
 	"github.com/fs714/github-star-manager/pkg/utils/code"
 	"github.com/fs714/github-star-manager/pkg/utils/log"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
 )
 
+// SyncFromGithub starts a sync job and returns it at once, progress of the job is queried by
+// GetJob. If a sync of the same user is running, the running job is returned with 409. A dry
+// run job only reports the diff in its result without changing db.
 func SyncFromGithub(c *gin.Context) {
-	msg, err := doSyncFromGithub(c)
+	var postData = struct {
+		User   string
+		Full   bool
+		DryRun bool
+	}{}
+	err := c.ShouldBindJSON(&postData)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status": code.RespCommonError,
-			"msg":    msg,
-			"data":   "",
-		})
+		respondError(c, "failed to bind post json to struct", errors.WithMessage(ErrInvalidParameter, err.Error()))
+		return
+	}
 
-		log.Errorf("failed to sync from github:\n%+v", err)
+	j, err := syncer.SubmitSync(tenantOf(c), postData.User, syncer.Options{Full: postData.Full, DryRun: postData.DryRun})
+	if errors.Is(err, job.ErrJobRunning) {
+		c.JSON(http.StatusConflict, gin.H{
+			"status": code.RespConflict,
+			"msg":    "sync of the user is running",
+			"data":   j,
+		})
+		return
+	} else if err != nil {
+		respondError(c, "failed to start sync job", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	c.JSON(http.StatusAccepted, gin.H{
 		"status": code.RespOk,
 		"msg":    "",
-		"data":   "",
+		"data":   j,
 	})
 }
 
-func doSyncFromGithub(c *gin.Context) (string, error) {
-	var msg string
+func GetGithubToken(c *gin.Context) {
+	t := tenantOf(c)
+	c.JSON(http.StatusOK, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data": gin.H{
+			"Source": t.TokenSource,
+			"Token":  github_api.MaskToken(t.Store.GetGithubToken()),
+		},
+	})
+}
 
+func UpdateGithubToken(c *gin.Context) {
 	var postData = struct {
-		User string
+		Token string
 	}{}
 	err := c.ShouldBindJSON(&postData)
 	if err != nil {
-		msg = "failed to bind post json to struct"
-		err = errors.Wrap(err, msg)
-		return msg, err
-	}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status": code.RespCommonError,
+			"msg":    "failed to bind post json to struct",
+			"data":   "",
+		})
 
-	repos, err := github_api.GetStarredRepos(postData.User)
-	if err != nil {
-		msg = "failed to get starred repos from github"
-		err = errors.Wrap(err, msg)
-		return msg, err
+		log.Errorf("failed to bind post json to struct:\n%+v", err)
+		return
 	}
 
-	newRepos := jsondb.NewRepositories()
-	for _, repo := range repos {
-		path, _, r := jsondb.Jsondb.GetAllRepositoryByName(*repo.Repository.FullName)
-		if r != nil {
-			if repo.Repository.FullName != nil {
-				r.Name = *repo.Repository.FullName
-			}
-
-			if repo.Repository.HTMLURL != nil {
-				r.Url = *repo.Repository.HTMLURL
-			}
-
-			if repo.Repository.Language != nil {
-				r.Language = *repo.Repository.Language
-			}
-
-			if repo.Repository.StargazersCount != nil {
-				r.StarsCount = *repo.Repository.StargazersCount
-			}
-
-			if repo.Repository.ForksCount != nil {
-				r.ForksCount = *repo.Repository.ForksCount
-			}
-
-			if repo.Repository.Description != nil {
-				r.Description = *repo.Repository.Description
-			}
-
-			r.CreatedAt = repo.Repository.CreatedAt.Unix()
-			r.UpdatedAt = repo.Repository.UpdatedAt.Unix()
-			r.PushedAt = repo.Repository.PushedAt.Unix()
-
-			newRepos.Add(path, r)
-		} else {
-			r := &jsondb.Repository{
-				CreatedAt: repo.Repository.CreatedAt.Unix(),
-				UpdatedAt: repo.Repository.UpdatedAt.Unix(),
-				PushedAt:  repo.Repository.PushedAt.Unix(),
-			}
-
-			if repo.Repository.FullName != nil {
-				r.Name = *repo.Repository.FullName
-			}
-
-			if repo.Repository.HTMLURL != nil {
-				r.Url = *repo.Repository.HTMLURL
-			}
-
-			if repo.Repository.Language != nil {
-				r.Language = *repo.Repository.Language
-			}
-
-			if repo.Repository.StargazersCount != nil {
-				r.StarsCount = *repo.Repository.StargazersCount
-			}
-
-			if repo.Repository.ForksCount != nil {
-				r.ForksCount = *repo.Repository.ForksCount
-			}
-
-			if repo.Repository.Description != nil {
-				r.Description = *repo.Repository.Description
-			}
+	t := tenantOf(c)
+	err = t.Store.UpdateGithubToken(postData.Token)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": code.RespCommonError,
+			"msg":    "failed to update github token",
+			"data":   "",
+		})
 
-			newRepos.Add([]string{}, r)
-		}
+		log.Errorf("failed to update github token:\n%+v", err)
+		return
 	}
 
-	err = jsondb.Jsondb.LoadRepositories(newRepos)
-	if err != nil {
-		msg = "failed to load new repositories to db"
-		err = errors.Wrap(err, msg)
-		return msg, err
+	if t.TokenSource != github_api.TokenSourceDB {
+		log.Warnw("github token is updated in db but it is not used", "TokenSource", t.TokenSource)
 	}
 
-	return msg, err
+	c.JSON(http.StatusOK, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data": gin.H{
+			"Source": t.TokenSource,
+			"Token":  github_api.MaskToken(postData.Token),
+		},
+	})
 }
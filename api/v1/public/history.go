@@ -0,0 +1,51 @@
+package public
+
+import (
+	"net/http"
+
+	"github.com/fs714/github-star-manager/db/jsondb"
+	"github.com/fs714/github-star-manager/pkg/utils/code"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+const defaultSyncHistoryLimit = 20
+
+// GetSyncHistory lists diffs of finished syncs newest first, query string accepts user and limit.
+func GetSyncHistory(c *gin.Context) {
+	limit := defaultSyncHistoryLimit
+	l, err := parseIntParam(c, "limit")
+	if err != nil {
+		respondError(c, err.Error(), err)
+		return
+	}
+	if l != nil {
+		limit = *l
+	}
+
+	if limit <= 0 || limit > jsondb.MaxSyncHistory {
+		err = errors.WithMessagef(ErrInvalidParameter, "limit should be in [1, %d]", jsondb.MaxSyncHistory)
+		respondError(c, err.Error(), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data":   storeOf(c).GetSyncHistory(c.Query("user"), limit),
+	})
+}
+
+func GetSyncHistoryByID(c *gin.Context) {
+	h := storeOf(c).GetSyncHistoryByID(c.Param("id"))
+	if h == nil {
+		respondError(c, "sync history not found", jsondb.ErrSyncHistoryNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data":   h,
+	})
+}
@@ -0,0 +1,87 @@
+package public
+
+import (
+	"net/http"
+
+	"github.com/fs714/github-star-manager/api/middleware"
+	"github.com/fs714/github-star-manager/pkg/job"
+	"github.com/fs714/github-star-manager/pkg/utils/code"
+	"github.com/gin-gonic/gin"
+)
+
+// GetJobs lists jobs newest first, ?type=sync filters jobs by type. Users other than admin only
+// see their own jobs.
+func GetJobs(c *gin.Context) {
+	jobs := make([]*job.Job, 0)
+	for _, j := range job.Manager.List(c.Query("type")) {
+		if canAccessJob(c, j) {
+			jobs = append(jobs, j)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data":   jobs,
+	})
+}
+
+func GetJob(c *gin.Context) {
+	j, err := getJob(c)
+	if err != nil {
+		respondError(c, "failed to get job", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data":   j,
+	})
+}
+
+// CancelJob asks the job to stop, the job becomes canceled when it returns.
+func CancelJob(c *gin.Context) {
+	_, err := getJob(c)
+	if err != nil {
+		respondError(c, "failed to get job", err)
+		return
+	}
+
+	err = job.Manager.Cancel(c.Param("id"))
+	if err != nil {
+		respondError(c, "failed to cancel job", err)
+		return
+	}
+
+	j, err := job.Manager.Get(c.Param("id"))
+	if err != nil {
+		respondError(c, "failed to get job", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data":   j,
+	})
+}
+
+// getJob returns job of param id, jobs of other users are not found.
+func getJob(c *gin.Context) (*job.Job, error) {
+	j, err := job.Manager.Get(c.Param("id"))
+	if err != nil {
+		return nil, err
+	}
+
+	if !canAccessJob(c, j) {
+		return nil, job.ErrJobNotFound
+	}
+
+	return j, nil
+}
+
+func canAccessJob(c *gin.Context, j *job.Job) bool {
+	user := middleware.CurrentUser(c)
+	return user == nil || user.Admin || j.Owner == user.Name
+}
@@ -0,0 +1,52 @@
+package public
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/fs714/github-star-manager/pkg/job"
+	"github.com/fs714/github-star-manager/pkg/syncer"
+)
+
+func TestJobRoutes(t *testing.T) {
+	r := newTestRouter(t)
+
+	release := make(chan struct{})
+	j, err := job.Manager.Submit(syncer.JobType, syncer.JobKey("", "FS714"), func(ctx context.Context, j *job.Job) (interface{}, error) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return nil, ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(release)
+
+	cases := []struct {
+		method string
+		url    string
+		body   interface{}
+		status int
+	}{
+		{http.MethodGet, "/api/v1/jobs?type=sync", nil, http.StatusOK},
+		{http.MethodGet, "/api/v1/jobs/" + j.ID, nil, http.StatusOK},
+		{http.MethodGet, "/api/v1/jobs/missing", nil, http.StatusNotFound},
+		{http.MethodGet, "/api/v1/github/sync/schedule", nil, http.StatusOK},
+		{http.MethodGet, "/api/v1/github/sync/history?user=fs714", nil, http.StatusOK},
+		{http.MethodGet, "/api/v1/github/sync/history?limit=0", nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/v1/github/sync/history/missing", nil, http.StatusNotFound},
+		{http.MethodPost, "/api/v1/github/sync", map[string]string{"User": "fs714"}, http.StatusConflict},
+		{http.MethodPost, "/api/v1/jobs/" + j.ID + "/cancel", nil, http.StatusAccepted},
+		{http.MethodPost, "/api/v1/jobs/missing/cancel", nil, http.StatusNotFound},
+	}
+
+	for _, c := range cases {
+		w := doRequest(r, c.method, c.url, c.body)
+		if w.Code != c.status {
+			t.Fatalf("%s %s returns %d, expected %d: %s", c.method, c.url, w.Code, c.status, w.Body.String())
+		}
+	}
+}
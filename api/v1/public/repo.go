@@ -2,28 +2,581 @@ package public
 
 import (
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/fs714/github-star-manager/db/jsondb"
+	"github.com/fs714/github-star-manager/pkg/syncer"
 	"github.com/fs714/github-star-manager/pkg/utils/code"
 	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
 )
 
+var repositoryNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+$`)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+type repositoryPostData struct {
+	Path []string
+	// Star stars the repository on github when it is created, metadata is fetched from github
+	Star bool
+	jsondb.Repository
+}
+
+type repositoryPatchData struct {
+	Tags                *[]string
+	DescriptionOverride *string
+	Note                *string
+	Path                *[]string
+}
+
+// GetRepos lists repositories matching filters in query string, see parseRepositoryQuery.
 func GetRepos(c *gin.Context) {
-	repos := jsondb.Jsondb.GetAllRepositoryByPath([]string{})
-	// if err != nil {
-	// 	c.JSON(http.StatusInternalServerError, gin.H{
-	// 		"status": code.RespCommonError,
-	// 		"msg":    msg,
-	// 		"data":   "",
-	// 	})
-
-	// 	log.Errorf("failed to get repos:\n%+v", err)
-	// 	return
-	// }
+	q, err := parseRepositoryQuery(c)
+	if err != nil {
+		respondError(c, err.Error(), err)
+		return
+	}
+
+	result, err := storeOf(c).QueryRepositories(q)
+	if err != nil {
+		if errors.Is(err, jsondb.ErrInvalidQuery) {
+			err = errors.WithMessage(ErrInvalidParameter, "invalid sort key or cursor")
+			respondError(c, err.Error(), err)
+			return
+		}
+
+		respondError(c, "failed to query repositories", err)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"status": code.RespOk,
 		"msg":    "",
-		"data":   repos,
+		"data":   result,
 	})
 }
+
+func GetRepo(c *gin.Context) {
+	name := repositoryNameFromParam(c)
+
+	path, _, repo := storeOf(c).GetAllRepositoryByName(name)
+	if repo == nil {
+		respondError(c, "repository not found", jsondb.ErrRepositoryNotFound)
+		return
+	}
+
+	respondRepository(c, http.StatusOK, path, repo)
+}
+
+func CreateRepo(c *gin.Context) {
+	var postData repositoryPostData
+	err := c.ShouldBindJSON(&postData)
+	if err != nil {
+		respondError(c, "failed to bind post json to struct", errors.WithMessage(ErrInvalidParameter, err.Error()))
+		return
+	}
+
+	repo := postData.Repository
+	err = validateRepository(&repo, postData.Path)
+	if err != nil {
+		respondError(c, err.Error(), err)
+		return
+	}
+
+	if postData.Star {
+		createStarredRepo(c, &repo, postData.Path)
+		return
+	}
+
+	err = storeOf(c).AddRepository(normalizePath(postData.Path), &repo)
+	if err != nil {
+		respondError(c, "failed to add repository", err)
+		return
+	}
+
+	respondRepository(c, http.StatusCreated, postData.Path, &repo)
+}
+
+// createStarredRepo stars repo on github and adds it with metadata from github, nothing is
+// changed if query dry_run is true.
+func createStarredRepo(c *gin.Context, repo *jsondb.Repository, path []string) {
+	if _, _, exist := storeOf(c).GetAllRepositoryByName(repo.Name); exist != nil && exist.UnstarredAt == 0 {
+		respondError(c, "failed to add repository", jsondb.ErrRepositoryExists)
+		return
+	}
+
+	dryRun := c.Query("dry_run") == "true"
+	t := tenantOf(c)
+	result, err := syncer.Star(c.Request.Context(), t.Store, githubClientOf(t), repo.Name, normalizePath(path), repo.Tags, dryRun)
+	if err != nil {
+		respondError(c, "failed to star repository", err)
+		return
+	}
+
+	if dryRun {
+		respondStarResult(c, result)
+		return
+	}
+
+	respondRepository(c, http.StatusCreated, result.Path, result.Repository)
+}
+
+func ReplaceRepo(c *gin.Context) {
+	name := repositoryNameFromParam(c)
+
+	var postData repositoryPostData
+	err := c.ShouldBindJSON(&postData)
+	if err != nil {
+		respondError(c, "failed to bind post json to struct", errors.WithMessage(ErrInvalidParameter, err.Error()))
+		return
+	}
+
+	repo := postData.Repository
+	if repo.Name == "" {
+		repo.Name = name
+	} else if repo.Name != name {
+		err = errors.WithMessage(ErrInvalidParameter, "repository name in body does not match url")
+		respondError(c, err.Error(), err)
+		return
+	}
+
+	err = validateRepository(&repo, postData.Path)
+	if err != nil {
+		respondError(c, err.Error(), err)
+		return
+	}
+
+	path, _, exist := storeOf(c).GetAllRepositoryByName(name)
+	if exist == nil {
+		respondError(c, "repository not found", jsondb.ErrRepositoryNotFound)
+		return
+	}
+
+	// name in url may be a previous name
+	repo.Name = exist.Name
+	keepSyncedFields(&repo, exist)
+
+	if postData.Path != nil && !equalPath(path, postData.Path) {
+		err = storeOf(c).UpdateAndMoveRepository(&repo, normalizePath(postData.Path))
+		if err != nil {
+			respondError(c, "failed to update repository", err)
+			return
+		}
+		path = postData.Path
+	} else {
+		err = storeOf(c).UpdateRepository(&repo)
+		if err != nil {
+			respondError(c, "failed to update repository", err)
+			return
+		}
+	}
+
+	respondRepository(c, http.StatusOK, path, &repo)
+}
+
+// keepSyncedFields copies fields managed by sync from exist into repo replacing it. Github
+// identity and starred time are kept unless they are given, star state and accounts are always
+// kept, and metadata imported from github is kept for synced repositories since sync owns it.
+func keepSyncedFields(repo *jsondb.Repository, exist *jsondb.Repository) {
+	if repo.ID == 0 {
+		repo.ID = exist.ID
+	}
+	if repo.NodeID == "" {
+		repo.NodeID = exist.NodeID
+	}
+	if repo.PreviousNames == nil {
+		repo.PreviousNames = exist.PreviousNames
+	}
+	if repo.StarredAt == 0 {
+		repo.StarredAt = exist.StarredAt
+	}
+
+	repo.UnstarredAt = exist.UnstarredAt
+	repo.UnstarredFrom = exist.UnstarredFrom
+	repo.StarredBy = exist.StarredBy
+
+	if exist.ID == 0 {
+		return
+	}
+
+	repo.Url = exist.Url
+	repo.Language = exist.Language
+	repo.StarsCount = exist.StarsCount
+	repo.ForksCount = exist.ForksCount
+	repo.Description = exist.Description
+	repo.Archived = exist.Archived
+	repo.Topics = exist.Topics
+	repo.License = exist.License
+	repo.Homepage = exist.Homepage
+	repo.Fork = exist.Fork
+	repo.Disabled = exist.Disabled
+	repo.DefaultBranch = exist.DefaultBranch
+	repo.Size = exist.Size
+	repo.OwnerType = exist.OwnerType
+	repo.CreatedAt = exist.CreatedAt
+	repo.UpdatedAt = exist.UpdatedAt
+	repo.PushedAt = exist.PushedAt
+}
+
+func PatchRepo(c *gin.Context) {
+	name := repositoryNameFromParam(c)
+
+	var patchData repositoryPatchData
+	err := c.ShouldBindJSON(&patchData)
+	if err != nil {
+		respondError(c, "failed to bind post json to struct", errors.WithMessage(ErrInvalidParameter, err.Error()))
+		return
+	}
+
+	path, _, exist := storeOf(c).GetAllRepositoryByName(name)
+	if exist == nil {
+		respondError(c, "repository not found", jsondb.ErrRepositoryNotFound)
+		return
+	}
+
+	repo := *exist
+	if patchData.Tags != nil {
+		repo.Tags = normalizeTags(*patchData.Tags)
+	}
+
+	if patchData.DescriptionOverride != nil {
+		repo.DescriptionOverride = *patchData.DescriptionOverride
+	}
+
+	if patchData.Note != nil {
+		repo.Note = *patchData.Note
+	}
+
+	if patchData.Path != nil {
+		err = validatePath(*patchData.Path)
+		if err != nil {
+			respondError(c, err.Error(), err)
+			return
+		}
+	}
+
+	// changes and move are saved in one write, so a failed request changes nothing
+	updated := patchData.Tags != nil || patchData.DescriptionOverride != nil || patchData.Note != nil
+	moved := patchData.Path != nil && !equalPath(path, *patchData.Path)
+	switch {
+	case updated && moved:
+		err = storeOf(c).UpdateAndMoveRepository(&repo, normalizePath(*patchData.Path))
+	case updated:
+		err = storeOf(c).UpdateRepository(&repo)
+	case moved:
+		err = storeOf(c).MoveRepository(repo.Name, normalizePath(*patchData.Path))
+	}
+	if err != nil {
+		respondError(c, "failed to update repository", err)
+		return
+	}
+	if moved {
+		path = *patchData.Path
+	}
+
+	respondRepository(c, http.StatusOK, path, &repo)
+}
+
+// DeleteRepo deletes repository from db, it is unstarred on github too if query unstar is true,
+// and nothing is changed if query dry_run is true as well.
+func DeleteRepo(c *gin.Context) {
+	name := repositoryNameFromParam(c)
+
+	_, _, repo := storeOf(c).GetAllRepositoryByName(name)
+	if repo == nil {
+		respondError(c, "repository not found", jsondb.ErrRepositoryNotFound)
+		return
+	}
+
+	if c.Query("unstar") == "true" {
+		t := tenantOf(c)
+		result, err := syncer.Unstar(c.Request.Context(), t.Store, githubClientOf(t), repo.Name, true, c.Query("dry_run") == "true")
+		if err != nil {
+			respondError(c, "failed to unstar repository", err)
+			return
+		}
+
+		respondStarResult(c, result)
+		return
+	}
+
+	err := storeOf(c).DeleteRepository(repo.Name)
+	if err != nil {
+		respondError(c, "failed to delete repository", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data":   "",
+	})
+}
+
+func respondRepository(c *gin.Context, status int, path []string, repo *jsondb.Repository) {
+	c.JSON(status, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data": gin.H{
+			"Path":       normalizePath(path),
+			"Repository": repo,
+		},
+	})
+}
+
+func repositoryNameFromParam(c *gin.Context) string {
+	return c.Param("owner") + "/" + c.Param("name")
+}
+
+func validateRepository(repo *jsondb.Repository, path []string) error {
+	if !repositoryNameRegexp.MatchString(repo.Name) {
+		return errors.WithMessagef(ErrInvalidParameter, "invalid repository name %q, expected owner/name", repo.Name)
+	}
+
+	if repo.Url == "" {
+		repo.Url = "https://github.com/" + repo.Name
+	}
+
+	if repo.StarsCount < 0 || repo.ForksCount < 0 {
+		return errors.WithMessage(ErrInvalidParameter, "stars and forks count should not be negative")
+	}
+
+	repo.Tags = normalizeTags(repo.Tags)
+
+	return validatePath(path)
+}
+
+func validatePath(path []string) error {
+	for _, p := range path {
+		if strings.TrimSpace(p) == "" || strings.Contains(p, "/") {
+			return errors.WithMessagef(ErrInvalidParameter, "invalid folder name %q in path", p)
+		}
+	}
+
+	return nil
+}
+
+func normalizePath(path []string) []string {
+	if path == nil {
+		return []string{}
+	}
+
+	return path
+}
+
+func normalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		if t == "" || seen[t] {
+			continue
+		}
+
+		seen[t] = true
+		normalized = append(normalized, t)
+	}
+
+	return normalized
+}
+
+func equalPath(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseRepositoryQuery reads filters of parseRepositoryFilter, text, sort and paging from query
+// string:
+//
+//	q=text sort=name|stars|pushed|created|starred order=asc|desc offset limit cursor
+func parseRepositoryQuery(c *gin.Context) (*jsondb.RepositoryQuery, error) {
+	q, err := parseRepositoryFilter(c)
+	if err != nil {
+		return nil, err
+	}
+
+	q.Text = strings.TrimSpace(c.Query("q"))
+	q.Sort = c.DefaultQuery("sort", jsondb.SortByName)
+	q.Desc = c.Query("order") == "desc"
+	q.Cursor = c.Query("cursor")
+
+	offset, err := parseIntParam(c, "offset")
+	if err != nil {
+		return nil, err
+	}
+	if offset != nil {
+		q.Offset = *offset
+	}
+
+	q.Limit = defaultPageLimit
+	limit, err := parseIntParam(c, "limit")
+	if err != nil {
+		return nil, err
+	}
+	if limit != nil {
+		q.Limit = *limit
+	}
+
+	if q.Offset < 0 || q.Limit <= 0 || q.Limit > maxPageLimit {
+		return nil, errors.WithMessagef(ErrInvalidParameter, "offset should not be negative and limit should be in [1, %d]",
+			maxPageLimit)
+	}
+
+	return q, nil
+}
+
+// parseRepositoryFilter reads filters shared by repository list and search from query string:
+//
+//	language=go,rust tag=a,b tag_mode=any|all path=linux/ebpf descendants=true|false
+//	account=alice,bob account_mode=any|all
+//	min_stars max_stars min_forks max_forks min_size max_size pushed_after pushed_before
+//	topic=cli,tui license=MIT,Apache-2.0 owner_type=User,Organization
+//	archived=true|false fork=true|false disabled=true|false unstarred=include|exclude|only
+func parseRepositoryFilter(c *gin.Context) (*jsondb.RepositoryQuery, error) {
+	var err error
+
+	q := &jsondb.RepositoryQuery{
+		Languages:         splitList(c.Query("language")),
+		Tags:              splitList(c.Query("tag")),
+		TagMode:           c.DefaultQuery("tag_mode", jsondb.TagModeAny),
+		IncludeSubFolders: c.DefaultQuery("descendants", "true") == "true",
+		Accounts:          splitList(c.Query("account")),
+		AccountMode:       c.DefaultQuery("account_mode", jsondb.TagModeAny),
+		Topics:            splitList(c.Query("topic")),
+		Licenses:          splitList(c.Query("license")),
+		OwnerTypes:        splitList(c.Query("owner_type")),
+		Unstarred:         c.DefaultQuery("unstarred", jsondb.UnstarredInclude),
+	}
+
+	if q.TagMode != jsondb.TagModeAny && q.TagMode != jsondb.TagModeAll {
+		return nil, errors.WithMessagef(ErrInvalidParameter, "invalid tag_mode %q", q.TagMode)
+	}
+
+	if q.AccountMode != jsondb.TagModeAny && q.AccountMode != jsondb.TagModeAll {
+		return nil, errors.WithMessagef(ErrInvalidParameter, "invalid account_mode %q", q.AccountMode)
+	}
+
+	switch q.Unstarred {
+	case jsondb.UnstarredInclude, jsondb.UnstarredExclude, jsondb.UnstarredOnly:
+	default:
+		return nil, errors.WithMessagef(ErrInvalidParameter, "invalid unstarred %q", q.Unstarred)
+	}
+
+	if path, ok := c.GetQuery("path"); ok {
+		q.Path = parsePath(path)
+	}
+
+	intParams := map[string]**int{
+		"min_stars": &q.MinStars,
+		"max_stars": &q.MaxStars,
+		"min_forks": &q.MinForks,
+		"max_forks": &q.MaxForks,
+		"min_size":  &q.MinSize,
+		"max_size":  &q.MaxSize,
+	}
+	for name, target := range intParams {
+		*target, err = parseIntParam(c, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	boolParams := map[string]**bool{
+		"archived": &q.Archived,
+		"fork":     &q.Fork,
+		"disabled": &q.Disabled,
+	}
+	for name, target := range boolParams {
+		*target, err = parseBoolParam(c, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	q.PushedAfter, err = parseTimeParam(c, "pushed_after")
+	if err != nil {
+		return nil, err
+	}
+
+	q.PushedBefore, err = parseTimeParam(c, "pushed_before")
+	if err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+func splitList(s string) []string {
+	list := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
+
+func parseIntParam(c *gin.Context, name string) (*int, error) {
+	s, ok := c.GetQuery(name)
+	if !ok || s == "" {
+		return nil, nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, errors.WithMessagef(ErrInvalidParameter, "invalid %s %q", name, s)
+	}
+
+	return &v, nil
+}
+
+func parseBoolParam(c *gin.Context, name string) (*bool, error) {
+	s, ok := c.GetQuery(name)
+	if !ok || s == "" {
+		return nil, nil
+	}
+
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return nil, errors.WithMessagef(ErrInvalidParameter, "invalid %s %q", name, s)
+	}
+
+	return &v, nil
+}
+
+// parseTimeParam accepts unix seconds, RFC3339 time or date like 2006-01-02
+func parseTimeParam(c *gin.Context, name string) (int64, error) {
+	s := c.Query(name)
+	if s == "" {
+		return 0, nil
+	}
+
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return v, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Unix(), nil
+		}
+	}
+
+	return 0, errors.WithMessagef(ErrInvalidParameter, "invalid %s %q", name, s)
+}
@@ -0,0 +1,261 @@
+package public
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/fs714/github-star-manager/api/middleware"
+	"github.com/fs714/github-star-manager/db"
+	"github.com/fs714/github-star-manager/db/jsondb"
+	"github.com/fs714/github-star-manager/pkg/config"
+	"github.com/fs714/github-star-manager/pkg/tenant"
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-github/v50/github"
+)
+
+func newTestRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	s, err := db.InitStore(config.Database{Driver: db.DriverJson, Path: filepath.Join(t.TempDir(), "db.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	db.Storage = s
+
+	r := gin.New()
+	InitRoute(r.Group("", middleware.AuthWithSkipPath(nil, nil, []string{"/api/v1/health"})))
+	InitShareRoute(r.Group(""), middleware.Share(nil, nil))
+
+	return r
+}
+
+func doRequest(r *gin.Engine, method string, url string, body interface{}) *httptest.ResponseRecorder {
+	return doRequestWithHeader(r, method, url, body, nil)
+}
+
+func doRequestWithHeader(r *gin.Engine, method string, url string, body interface{},
+	header map[string]string) *httptest.ResponseRecorder {
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req := httptest.NewRequest(method, url, reader)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestRepoCrud(t *testing.T) {
+	r := newTestRouter(t)
+
+	cases := []struct {
+		method string
+		url    string
+		body   interface{}
+		status int
+	}{
+		{http.MethodGet, "/api/v1/repo/fs714/missing", nil, http.StatusNotFound},
+		{http.MethodPost, "/api/v1/repo", gin.H{"Name": "invalid"}, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/repo", gin.H{"Name": "fs714/gsm", "Tags": []string{"go"}, "Path": []string{"tool"}},
+			http.StatusCreated},
+		{http.MethodPost, "/api/v1/repo", gin.H{"Name": "fs714/gsm"}, http.StatusConflict},
+		{http.MethodGet, "/api/v1/repo/fs714/gsm", nil, http.StatusOK},
+		{http.MethodGet, "/api/v1/repo?tag=go&path=tool&sort=stars&order=desc&limit=10", nil, http.StatusOK},
+		{http.MethodGet, "/api/v1/repo?limit=0", nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/v1/repo?pushed_after=yesterday", nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/v1/repo?cursor=invalid", nil, http.StatusBadRequest},
+		{http.MethodPatch, "/api/v1/repo/fs714/gsm",
+			gin.H{"Tags": []string{"go", "github"}, "DescriptionOverride": "mine", "Path": []string{"go", "tool"}},
+			http.StatusOK},
+		{http.MethodPatch, "/api/v1/repo/fs714/missing", gin.H{"Tags": []string{}}, http.StatusNotFound},
+		{http.MethodPut, "/api/v1/repo/fs714/gsm", gin.H{"Name": "other/name"}, http.StatusBadRequest},
+		{http.MethodPut, "/api/v1/repo/fs714/gsm", gin.H{"StarsCount": 10, "Tags": []string{"go"}}, http.StatusOK},
+		{http.MethodPut, "/api/v1/tags/go", gin.H{"Name": "golang"}, http.StatusOK},
+		{http.MethodPut, "/api/v1/tags/missing", gin.H{"Name": "other"}, http.StatusNotFound},
+		{http.MethodDelete, "/api/v1/tags/missing", nil, http.StatusNotFound},
+		{http.MethodGet, "/api/v1/search?q=gsm&limit=5", nil, http.StatusOK},
+		{http.MethodGet, "/api/v1/search?q=", nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/v1/search?q=gsm&limit=-1", nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/v1/repo?archived=false&fork=false&topic=cli&license=MIT&min_size=1", nil, http.StatusOK},
+		{http.MethodGet, "/api/v1/repo?archived=maybe", nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/v1/repo?account=alice,bob&account_mode=all", nil, http.StatusOK},
+		{http.MethodGet, "/api/v1/repo?account=alice&account_mode=some", nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/v1/accounts", nil, http.StatusOK},
+		{http.MethodGet, "/api/v1/search?q=gsm&fork=false&owner_type=User", nil, http.StatusOK},
+		{http.MethodGet, "/api/v1/search?q=gsm&max_size=big", nil, http.StatusBadRequest},
+		{http.MethodDelete, "/api/v1/repo/fs714/gsm", nil, http.StatusOK},
+		{http.MethodDelete, "/api/v1/repo/fs714/gsm", nil, http.StatusNotFound},
+	}
+
+	for _, c := range cases {
+		w := doRequest(r, c.method, c.url, c.body)
+		if w.Code != c.status {
+			t.Fatalf("%s %s returns %d, expected %d: %s", c.method, c.url, w.Code, c.status, w.Body.String())
+		}
+
+		if c.method == http.MethodPatch && c.status == http.StatusOK {
+			path, _, repo := db.Storage.GetAllRepositoryByName("fs714/gsm")
+			if repo.DescriptionOverride != "mine" || len(repo.Tags) != 2 || !equalPath(path, []string{"go", "tool"}) {
+				t.Errorf("patched repository is %+v at %v", repo, path)
+			}
+		}
+	}
+}
+
+func TestUnstarredRoutes(t *testing.T) {
+	r := newTestRouter(t)
+
+	repos := []*jsondb.Repository{
+		{Name: "fs714/starred"},
+		{Name: "fs714/old", UnstarredAt: 100, UnstarredFrom: []string{"go"}},
+		{Name: "fs714/older", UnstarredAt: 50},
+	}
+	for _, repo := range repos {
+		path := []string{}
+		if repo.UnstarredFrom != nil {
+			path = []string{"unstarred"}
+		}
+
+		err := db.Storage.AddRepository(path, repo)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		method string
+		url    string
+		status int
+		total  int
+	}{
+		{http.MethodGet, "/api/v1/repo?unstarred=only", http.StatusOK, 2},
+		{http.MethodGet, "/api/v1/repo?unstarred=exclude", http.StatusOK, 1},
+		{http.MethodGet, "/api/v1/repo", http.StatusOK, 3},
+		{http.MethodGet, "/api/v1/repo?unstarred=maybe", http.StatusBadRequest, 0},
+		{http.MethodPost, "/api/v1/repo/fs714/starred/restar", http.StatusBadRequest, 0},
+		{http.MethodPost, "/api/v1/repo/fs714/old/restar", http.StatusOK, 0},
+		{http.MethodDelete, "/api/v1/unstarred?before=10", http.StatusOK, 0},
+		{http.MethodDelete, "/api/v1/unstarred", http.StatusOK, 0},
+		{http.MethodGet, "/api/v1/repo", http.StatusOK, 2},
+	}
+
+	for _, c := range cases {
+		w := doRequest(r, c.method, c.url, nil)
+		if w.Code != c.status {
+			t.Fatalf("%s %s returns %d, expected %d: %s", c.method, c.url, w.Code, c.status, w.Body.String())
+		}
+
+		if c.method == http.MethodGet && c.status == http.StatusOK {
+			var resp struct {
+				Data jsondb.RepositoryQueryResult `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Data.Total != c.total {
+				t.Errorf("%s returns %d repositories, expected %d: %v", c.url, resp.Data.Total, c.total, err)
+			}
+		}
+	}
+
+	path, _, repo := db.Storage.GetAllRepositoryByName("fs714/old")
+	if repo == nil || repo.UnstarredAt != 0 || !equalPath(path, []string{"go"}) {
+		t.Errorf("restarred repository is %+v at %v", repo, path)
+	}
+}
+
+func TestReplaceSyncedRepo(t *testing.T) {
+	r := newTestRouter(t)
+
+	err := db.Storage.AddRepository([]string{"unstarred"}, &jsondb.Repository{ID: 1, Name: "fs714/gsm",
+		Description: "from github", Archived: true, StarredAt: 10, UnstarredAt: 100, UnstarredFrom: []string{"go"},
+		StarredBy: []string{"alice", "bob"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := doRequest(r, http.MethodPut, "/api/v1/repo/fs714/gsm", gin.H{"Tags": []string{"go"}, "Note": "mine"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("replace repo returns %d: %s", w.Code, w.Body.String())
+	}
+
+	_, _, repo := db.Storage.GetAllRepositoryByName("fs714/gsm")
+	if repo.ID != 1 || repo.Note != "mine" || len(repo.Tags) != 1 || repo.StarredAt != 10 ||
+		repo.UnstarredAt != 100 || !equalPath(repo.UnstarredFrom, []string{"go"}) ||
+		!equalPath(repo.StarredBy, []string{"alice", "bob"}) || repo.Description != "from github" || !repo.Archived {
+		t.Errorf("replaced repository is %+v", repo)
+	}
+}
+
+func TestStarRoutes(t *testing.T) {
+	r := newTestRouter(t)
+
+	starred := map[string]bool{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/user/starred/fs714/gsm", func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.Method == http.MethodGet && !starred["fs714/gsm"]:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case req.Method == http.MethodPut:
+			starred["fs714/gsm"] = true
+		case req.Method == http.MethodDelete:
+			delete(starred, "fs714/gsm")
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"login": "fs714"}`))
+	})
+	mux.HandleFunc("/repos/fs714/gsm", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(`{"id": 1, "full_name": "fs714/gsm"}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	defer func(f func(*tenant.Tenant) *github.Client) { githubClientOf = f }(githubClientOf)
+	githubClientOf = func(*tenant.Tenant) *github.Client {
+		client := github.NewClient(nil)
+		client.BaseURL, _ = url.Parse(server.URL + "/")
+		return client
+	}
+
+	cases := []struct {
+		method  string
+		url     string
+		body    interface{}
+		status  int
+		starred bool
+	}{
+		{http.MethodPost, "/api/v1/repo?dry_run=true", gin.H{"Name": "fs714/gsm", "Star": true}, http.StatusOK, false},
+		{http.MethodPost, "/api/v1/repo", gin.H{"Name": "fs714/gsm", "Star": true, "Path": []string{"tool"}},
+			http.StatusCreated, true},
+		{http.MethodPost, "/api/v1/repo", gin.H{"Name": "fs714/gsm", "Star": true}, http.StatusConflict, true},
+		{http.MethodPost, "/api/v1/repo/fs714/gsm/unstar", nil, http.StatusOK, false},
+		{http.MethodPost, "/api/v1/repo/fs714/gsm/star", nil, http.StatusOK, true},
+		{http.MethodPost, "/api/v1/repo/fs714/missing/star", nil, http.StatusNotFound, true},
+		{http.MethodDelete, "/api/v1/repo/fs714/gsm?unstar=true&dry_run=true", nil, http.StatusOK, true},
+		{http.MethodDelete, "/api/v1/repo/fs714/gsm?unstar=true", nil, http.StatusOK, false},
+		{http.MethodGet, "/api/v1/repo/fs714/gsm", nil, http.StatusNotFound, false},
+	}
+
+	for _, c := range cases {
+		w := doRequest(r, c.method, c.url, c.body)
+		if w.Code != c.status || starred["fs714/gsm"] != c.starred {
+			t.Fatalf("%s %s returns %d, starred %v, expected %d, %v: %s", c.method, c.url, w.Code,
+				starred["fs714/gsm"], c.status, c.starred, w.Body.String())
+		}
+	}
+}
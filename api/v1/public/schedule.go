@@ -0,0 +1,37 @@
+package public
+
+import (
+	"net/http"
+
+	"github.com/fs714/github-star-manager/pkg/syncer"
+	"github.com/fs714/github-star-manager/pkg/utils/code"
+	"github.com/gin-gonic/gin"
+)
+
+// GetSyncSchedule returns next and last run of scheduled sync of every configured user. Scheduled
+// syncs write the server database, so only admin users see them if auth is enabled.
+func GetSyncSchedule(c *gin.Context) {
+	s := syncer.CurrentScheduler
+	if s == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status": code.RespOk,
+			"msg":    "",
+			"data": gin.H{
+				"Enabled": false,
+				"Entries": []syncer.ScheduleEntry{},
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data": gin.H{
+			"Enabled": true,
+			"Spec":    s.Spec,
+			"Jitter":  int(s.Jitter.Seconds()),
+			"Entries": s.Entries(),
+		},
+	})
+}
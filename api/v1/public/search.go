@@ -0,0 +1,52 @@
+package public
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/fs714/github-star-manager/pkg/utils/code"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+const defaultSearchLimit = 20
+
+// Search returns repositories ranked by relevance to q, matched words in results are
+// highlighted with <em></em>. Results are narrowed down by the same filters as repository list,
+// see parseRepositoryFilter.
+func Search(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		err := errors.WithMessage(ErrInvalidParameter, "search query q should not be empty")
+		respondError(c, err.Error(), err)
+		return
+	}
+
+	limit := defaultSearchLimit
+	l, err := parseIntParam(c, "limit")
+	if err != nil {
+		respondError(c, err.Error(), err)
+		return
+	}
+	if l != nil {
+		limit = *l
+	}
+
+	if limit <= 0 || limit > maxPageLimit {
+		err = errors.WithMessagef(ErrInvalidParameter, "limit should be in [1, %d]", maxPageLimit)
+		respondError(c, err.Error(), err)
+		return
+	}
+
+	filter, err := parseRepositoryFilter(c)
+	if err != nil {
+		respondError(c, err.Error(), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data":   storeOf(c).Search(q, filter, limit),
+	})
+}
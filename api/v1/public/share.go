@@ -0,0 +1,266 @@
+package public
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/fs714/github-star-manager/api/middleware"
+	"github.com/fs714/github-star-manager/db/jsondb"
+	"github.com/fs714/github-star-manager/pkg/auth"
+	"github.com/fs714/github-star-manager/pkg/utils/code"
+	"github.com/fs714/github-star-manager/pkg/utils/log"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// sharedRoutePrefix is where share links are served, see InitShareRoute.
+const sharedRoutePrefix = "/api/v1/shared/"
+
+// InitShareRoute registers read-only routes of share links, which need no authentication and
+// only see repositories of the shared folder or tag. Only GET routes are registered, so other
+// methods are not found.
+func InitShareRoute(Router *gin.RouterGroup, share gin.HandlerFunc) gin.IRoutes {
+	sharedRoute := Router.Group(sharedRoutePrefix+":token", share)
+	{
+		sharedRoute.GET("", GetShared)
+		sharedRoute.GET("repo", GetSharedRepos)
+	}
+
+	return sharedRoute
+}
+
+// ShareInfo is share returned to its owner without hash.
+type ShareInfo struct {
+	ID        string
+	Name      string
+	Path      []string
+	Tag       string
+	CreatedAt int64
+	ExpiresAt int64
+}
+
+func newShareInfo(s *jsondb.Share) *ShareInfo {
+	return &ShareInfo{
+		ID:        s.ID,
+		Name:      s.Name,
+		Path:      normalizePath(s.Path),
+		Tag:       s.Tag,
+		CreatedAt: s.CreatedAt,
+		ExpiresAt: s.ExpiresAt,
+	}
+}
+
+func GetShares(c *gin.Context) {
+	shares := make([]*ShareInfo, 0)
+	for _, s := range storeOf(c).GetShares() {
+		shares = append(shares, newShareInfo(s))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data":   shares,
+	})
+}
+
+// CreateShare publishes a folder at Path or a tag by a read-only link, ExpiresIn is days before
+// the link expires and 0 means never. The link is only returned in this response.
+func CreateShare(c *gin.Context) {
+	var postData = struct {
+		Name      string
+		Path      []string
+		Tag       string
+		ExpiresIn int
+	}{}
+	err := c.ShouldBindJSON(&postData)
+	if err != nil {
+		respondError(c, "failed to bind post json to struct", errors.WithMessage(ErrInvalidParameter, err.Error()))
+		return
+	}
+
+	if postData.ExpiresIn < 0 {
+		err = errors.WithMessage(ErrInvalidParameter, "expires in should not be negative")
+		respondError(c, err.Error(), err)
+		return
+	}
+
+	var expiresAt int64
+	if postData.ExpiresIn > 0 {
+		expiresAt = time.Now().AddDate(0, 0, postData.ExpiresIn).Unix()
+	}
+
+	// shares of server database are not bound to user, see auth.NewToken
+	user := tenantOf(c).Name
+	if auth.IsServerAdmin(user) {
+		user = ""
+	}
+
+	token, s, err := auth.CreateShare(storeOf(c), user, postData.Name, postData.Path, postData.Tag, expiresAt)
+	if err != nil {
+		respondError(c, "failed to create share", err)
+		return
+	}
+
+	log.Infow("share is created", "user", tenantOf(c).Name, "id", s.ID, "path", s.Path, "tag", s.Tag)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data": gin.H{
+			"Token": token,
+			"Url":   sharedRoutePrefix + token,
+			"Share": newShareInfo(s),
+		},
+	})
+}
+
+// DeleteShare revokes share link at once.
+func DeleteShare(c *gin.Context) {
+	err := storeOf(c).DeleteShare(c.Param("id"))
+	if err != nil {
+		respondError(c, "failed to delete share", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data":   "",
+	})
+}
+
+// GetShared returns name of share, and tree of shared folder with paths relative to it.
+func GetShared(c *gin.Context) {
+	s := middleware.CurrentShare(c)
+
+	var folder *jsondb.Folder
+	if len(s.Path) > 0 {
+		folder = storeOf(c).GetFolderTree().Find(s.Path)
+		if folder == nil {
+			respondError(c, "shared folder not found", jsondb.ErrPathNotFound)
+			return
+		}
+		folder = relativeFolder(folder, []string{})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data": gin.H{
+			"Name":      s.Name,
+			"Tag":       s.Tag,
+			"ExpiresAt": s.ExpiresAt,
+			"Folder":    folder,
+		},
+	})
+}
+
+// SharedRepository is repository returned by share link. Only public fields from github and tags
+// are shared, user's notes, accounts and unstarred state are not.
+type SharedRepository struct {
+	Name        string
+	Url         string
+	Language    string
+	StarsCount  int
+	ForksCount  int
+	Description string
+	Archived    bool
+	Topics      []string
+	License     string
+	Homepage    string
+	Fork        bool
+	CreatedAt   int64
+	UpdatedAt   int64
+	PushedAt    int64
+	Tags        []string
+}
+
+func newSharedRepository(r *jsondb.Repository) *SharedRepository {
+	description := r.Description
+	if r.DescriptionOverride != "" {
+		description = r.DescriptionOverride
+	}
+
+	return &SharedRepository{
+		Name:        r.Name,
+		Url:         r.Url,
+		Language:    r.Language,
+		StarsCount:  r.StarsCount,
+		ForksCount:  r.ForksCount,
+		Description: description,
+		Archived:    r.Archived,
+		Topics:      r.Topics,
+		License:     r.License,
+		Homepage:    r.Homepage,
+		Fork:        r.Fork,
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+		PushedAt:    r.PushedAt,
+		Tags:        r.Tags,
+	}
+}
+
+// GetSharedRepos lists repositories of share with the same query string as GetRepos, path is
+// relative to the shared folder. Repositories kept after unstar are not shared.
+func GetSharedRepos(c *gin.Context) {
+	s := middleware.CurrentShare(c)
+
+	q, err := parseRepositoryQuery(c)
+	if err != nil {
+		respondError(c, err.Error(), err)
+		return
+	}
+
+	q.Accounts = nil
+	q.Unstarred = jsondb.UnstarredExclude
+	if len(s.Path) > 0 {
+		q.Path = append(append([]string{}, s.Path...), q.Path...)
+	} else {
+		q.Tags = []string{s.Tag}
+		q.TagMode = jsondb.TagModeAny
+	}
+
+	result, err := storeOf(c).QueryRepositories(q)
+	if err != nil {
+		if errors.Is(err, jsondb.ErrInvalidQuery) {
+			err = errors.WithMessage(ErrInvalidParameter, "invalid sort key or cursor")
+			respondError(c, err.Error(), err)
+			return
+		}
+
+		respondError(c, "failed to query repositories", err)
+		return
+	}
+
+	repos := make([]*SharedRepository, 0, len(result.Repositories))
+	for _, repo := range result.Repositories {
+		repos = append(repos, newSharedRepository(repo))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data": gin.H{
+			"Total":        result.Total,
+			"Offset":       result.Offset,
+			"NextCursor":   result.NextCursor,
+			"Repositories": repos,
+		},
+	})
+}
+
+func relativeFolder(f *jsondb.Folder, path []string) *jsondb.Folder {
+	r := &jsondb.Folder{
+		Name:       f.Name,
+		Path:       path,
+		Count:      f.Count,
+		TotalCount: f.TotalCount,
+		SubFolders: make([]*jsondb.Folder, 0, len(f.SubFolders)),
+	}
+
+	for _, sub := range f.SubFolders {
+		r.SubFolders = append(r.SubFolders, relativeFolder(sub, append(append([]string{}, path...), sub.Name)))
+	}
+
+	return r
+}
@@ -0,0 +1,193 @@
+package public
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// createShare shares folder at path or tag and returns url of the share link.
+func createShare(t *testing.T, r *gin.Engine, header map[string]string, body gin.H) string {
+	w := doRequestWithHeader(r, http.MethodPost, "/api/v1/shares", body, header)
+	var resp struct {
+		Data struct {
+			Url   string
+			Share struct {
+				ID string
+			}
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("create share returns %d: %s", w.Code, w.Body.String())
+	}
+
+	return resp.Data.Url
+}
+
+func sharedRepoNames(t *testing.T, r *gin.Engine, url string) []string {
+	w := doRequest(r, http.MethodGet, url, nil)
+	var resp struct {
+		Data struct {
+			Repositories []map[string]interface{}
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("get %s returns %d: %s", url, w.Code, w.Body.String())
+	}
+
+	names := make([]string, 0)
+	for _, repo := range resp.Data.Repositories {
+		for _, key := range []string{"StarredBy", "Note", "UnstarredAt", "UnstarredFrom", "PreviousNames"} {
+			if _, ok := repo[key]; ok {
+				t.Errorf("shared repository %v has %s", repo["Name"], key)
+			}
+		}
+		names = append(names, repo["Name"].(string))
+	}
+
+	return names
+}
+
+func TestShares(t *testing.T) {
+	r := newTestRouter(t)
+
+	for _, repo := range []gin.H{
+		{"Name": "fs714/gsm", "Tags": []string{"go"}, "Path": []string{"tool", "cli"}, "StarredBy": []string{"fs714"},
+			"Note": "private"},
+		{"Name": "fs714/web", "Tags": []string{"go"}, "Path": []string{"web"}},
+		{"Name": "fs714/other", "Path": []string{"tool"}},
+	} {
+		if w := doRequest(r, http.MethodPost, "/api/v1/repo", repo); w.Code != http.StatusCreated {
+			t.Fatalf("create repo returns %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	cases := []struct {
+		body   gin.H
+		status int
+	}{
+		{gin.H{"Name": "nothing"}, http.StatusBadRequest},
+		{gin.H{"Path": []string{"tool"}, "Tag": "go"}, http.StatusBadRequest},
+		{gin.H{"Path": []string{"missing"}}, http.StatusNotFound},
+		{gin.H{"Tag": "go", "ExpiresIn": -1}, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		if w := doRequest(r, http.MethodPost, "/api/v1/shares", c.body); w.Code != c.status {
+			t.Errorf("create share %v returns %d, expected %d: %s", c.body, w.Code, c.status, w.Body.String())
+		}
+	}
+
+	folderUrl := createShare(t, r, nil, gin.H{"Name": "tools", "Path": []string{"tool"}, "ExpiresIn": 7})
+	if names := sharedRepoNames(t, r, folderUrl+"/repo?sort=name"); len(names) != 2 ||
+		names[0] != "fs714/gsm" || names[1] != "fs714/other" {
+		t.Errorf("shared folder has %v", names)
+	}
+
+	if names := sharedRepoNames(t, r, folderUrl+"/repo?path=cli"); len(names) != 1 || names[0] != "fs714/gsm" {
+		t.Errorf("sub folder of shared folder has %v", names)
+	}
+
+	tagUrl := createShare(t, r, nil, gin.H{"Tag": "go"})
+	if names := sharedRepoNames(t, r, tagUrl+"/repo?tag=other&tag_mode=all"); len(names) != 2 {
+		t.Errorf("shared tag has %v", names)
+	}
+
+	w := doRequest(r, http.MethodGet, folderUrl, nil)
+	var resp struct {
+		Data struct {
+			Name   string
+			Folder struct {
+				Path       []string
+				TotalCount int
+				SubFolders []struct {
+					Path []string
+				}
+			}
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Data.Name != "tools" ||
+		len(resp.Data.Folder.Path) != 0 || resp.Data.Folder.TotalCount != 2 ||
+		len(resp.Data.Folder.SubFolders) != 1 || !equalPath(resp.Data.Folder.SubFolders[0].Path, []string{"cli"}) {
+		t.Errorf("shared folder is %s", w.Body.String())
+	}
+
+	for _, c := range []struct {
+		method string
+		url    string
+		status int
+	}{
+		{http.MethodPost, folderUrl + "/repo", http.StatusNotFound},
+		{http.MethodGet, "/api/v1/shared/gss_invalid/repo", http.StatusNotFound},
+		{http.MethodGet, "/api/v1/shared/" + "gsm_" + folderUrl[len("/api/v1/shared/gss_"):], http.StatusNotFound},
+	} {
+		if w := doRequest(r, c.method, c.url, nil); w.Code != c.status {
+			t.Errorf("%s %s returns %d, expected %d", c.method, c.url, w.Code, c.status)
+		}
+	}
+
+	// revoked share is not found
+	w = doRequest(r, http.MethodGet, "/api/v1/shares", nil)
+	var list struct {
+		Data []struct {
+			ID   string
+			Name string
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || len(list.Data) != 2 {
+		t.Fatalf("list shares returns %s", w.Body.String())
+	}
+
+	if w = doRequest(r, http.MethodDelete, "/api/v1/shares/"+list.Data[0].ID, nil); w.Code != http.StatusOK {
+		t.Fatalf("delete share returns %d: %s", w.Code, w.Body.String())
+	}
+
+	if w = doRequest(r, http.MethodGet, folderUrl+"/repo", nil); w.Code != http.StatusNotFound {
+		t.Errorf("revoked share returns %d", w.Code)
+	}
+
+	if w = doRequest(r, http.MethodDelete, "/api/v1/shares/"+list.Data[0].ID, nil); w.Code != http.StatusNotFound {
+		t.Errorf("delete revoked share returns %d", w.Code)
+	}
+}
+
+func TestSharesFollowFolder(t *testing.T) {
+	r := newTestRouter(t)
+
+	repo := gin.H{"Name": "fs714/gsm", "Path": []string{"tool", "cli"}}
+	if w := doRequest(r, http.MethodPost, "/api/v1/repo", repo); w.Code != http.StatusCreated {
+		t.Fatalf("create repo returns %d: %s", w.Code, w.Body.String())
+	}
+
+	url := createShare(t, r, nil, gin.H{"Path": []string{"tool", "cli"}})
+
+	steps := []struct {
+		method string
+		url    string
+		body   gin.H
+	}{
+		{http.MethodPost, "/api/v1/folders/rename", gin.H{"Path": []string{"tool", "cli"}, "Name": "cmd"}},
+		{http.MethodPost, "/api/v1/folders/rename", gin.H{"Path": []string{"tool"}, "Name": "tools"}},
+		{http.MethodPost, "/api/v1/folders", gin.H{"Path": []string{"dev"}}},
+		{http.MethodPost, "/api/v1/folders/move", gin.H{"Path": []string{"tools"}, "Parent": []string{"dev"}}},
+	}
+	for _, step := range steps {
+		if w := doRequest(r, step.method, step.url, step.body); w.Code != http.StatusOK && w.Code != http.StatusCreated {
+			t.Fatalf("%s %s returns %d: %s", step.method, step.url, w.Code, w.Body.String())
+		}
+
+		if names := sharedRepoNames(t, r, url+"/repo"); len(names) != 1 || names[0] != "fs714/gsm" {
+			t.Errorf("shared folder has %v after %s %v", names, step.url, step.body)
+		}
+	}
+
+	if w := doRequest(r, http.MethodDelete, "/api/v1/folders?path=dev/tools/cmd", nil); w.Code != http.StatusOK {
+		t.Fatalf("delete folder returns %d: %s", w.Code, w.Body.String())
+	}
+
+	// share of deleted folder is deleted, it is not moved to parent with repositories
+	if w := doRequest(r, http.MethodGet, url+"/repo", nil); w.Code != http.StatusNotFound {
+		t.Errorf("share of deleted folder returns %d", w.Code)
+	}
+}
@@ -0,0 +1,52 @@
+package public
+
+import (
+	"net/http"
+
+	"github.com/fs714/github-star-manager/pkg/syncer"
+	"github.com/fs714/github-star-manager/pkg/tenant"
+	"github.com/fs714/github-star-manager/pkg/utils/code"
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-github/v50/github"
+)
+
+// githubClientOf is replaced in tests
+var githubClientOf = func(t *tenant.Tenant) *github.Client {
+	return t.Client
+}
+
+// StarRepo stars repository on github and saves its metadata into db, nothing is changed if
+// query dry_run is true.
+func StarRepo(c *gin.Context) {
+	t := tenantOf(c)
+	result, err := syncer.Star(c.Request.Context(), t.Store, githubClientOf(t), repositoryNameFromParam(c), nil, nil,
+		c.Query("dry_run") == "true")
+	if err != nil {
+		respondError(c, "failed to star repository", err)
+		return
+	}
+
+	respondStarResult(c, result)
+}
+
+// UnstarRepo unstars repository on github and applies unstarred policy to it in db, nothing is
+// changed if query dry_run is true.
+func UnstarRepo(c *gin.Context) {
+	t := tenantOf(c)
+	result, err := syncer.Unstar(c.Request.Context(), t.Store, githubClientOf(t), repositoryNameFromParam(c), false,
+		c.Query("dry_run") == "true")
+	if err != nil {
+		respondError(c, "failed to unstar repository", err)
+		return
+	}
+
+	respondStarResult(c, result)
+}
+
+func respondStarResult(c *gin.Context, result *syncer.StarResult) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data":   result,
+	})
+}
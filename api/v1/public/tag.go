@@ -0,0 +1,119 @@
+package public
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/fs714/github-star-manager/pkg/utils/code"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+func GetTags(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data":   storeOf(c).GetTagCounts(),
+	})
+}
+
+func RenameTag(c *gin.Context) {
+	var postData = struct {
+		Name string
+	}{}
+	err := c.ShouldBindJSON(&postData)
+	if err != nil {
+		respondError(c, "failed to bind post json to struct", errors.WithMessage(ErrInvalidParameter, err.Error()))
+		return
+	}
+
+	name := strings.TrimSpace(postData.Name)
+	if name == "" {
+		err = errors.WithMessage(ErrInvalidParameter, "new tag name should not be empty")
+		respondError(c, err.Error(), err)
+		return
+	}
+
+	count, err := storeOf(c).RenameTag(c.Param("tag"), name)
+	if err != nil {
+		respondError(c, "failed to rename tag", err)
+		return
+	}
+
+	respondTagUpdated(c, count)
+}
+
+func MergeTags(c *gin.Context) {
+	var postData = struct {
+		Tags   []string
+		Target string
+	}{}
+	err := c.ShouldBindJSON(&postData)
+	if err != nil {
+		respondError(c, "failed to bind post json to struct", errors.WithMessage(ErrInvalidParameter, err.Error()))
+		return
+	}
+
+	target := strings.TrimSpace(postData.Target)
+	if target == "" || len(postData.Tags) == 0 {
+		err = errors.WithMessage(ErrInvalidParameter, "tags and target should not be empty")
+		respondError(c, err.Error(), err)
+		return
+	}
+
+	count, err := storeOf(c).MergeTags(postData.Tags, target)
+	if err != nil {
+		respondError(c, "failed to merge tags", err)
+		return
+	}
+
+	respondTagUpdated(c, count)
+}
+
+func DeleteTag(c *gin.Context) {
+	count, err := storeOf(c).DeleteTag(c.Param("tag"))
+	if err != nil {
+		respondError(c, "failed to delete tag", err)
+		return
+	}
+
+	respondTagUpdated(c, count)
+}
+
+func BulkUpdateTags(c *gin.Context) {
+	var postData = struct {
+		Repositories []string
+		Add          []string
+		Remove       []string
+	}{}
+	err := c.ShouldBindJSON(&postData)
+	if err != nil {
+		respondError(c, "failed to bind post json to struct", errors.WithMessage(ErrInvalidParameter, err.Error()))
+		return
+	}
+
+	if len(postData.Repositories) == 0 {
+		err = errors.WithMessage(ErrInvalidParameter, "repositories should not be empty")
+		respondError(c, err.Error(), err)
+		return
+	}
+
+	count, err := storeOf(c).UpdateRepositoriesTags(postData.Repositories, normalizeTags(postData.Add),
+		normalizeTags(postData.Remove))
+	if err != nil {
+		respondError(c, "failed to update tags of repositories", err)
+		return
+	}
+
+	respondTagUpdated(c, count)
+}
+
+func respondTagUpdated(c *gin.Context, count int) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data": gin.H{
+			"Updated": count,
+		},
+	})
+}
@@ -0,0 +1,110 @@
+package public
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/fs714/github-star-manager/db/jsondb"
+	"github.com/fs714/github-star-manager/pkg/auth"
+	"github.com/fs714/github-star-manager/pkg/utils/code"
+	"github.com/fs714/github-star-manager/pkg/utils/log"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// APITokenInfo is api token returned by api without hash.
+type APITokenInfo struct {
+	ID         string
+	Name       string
+	Scopes     []string
+	CreatedAt  int64
+	ExpiresAt  int64
+	LastUsedAt int64
+}
+
+func newAPITokenInfo(t *jsondb.APIToken) *APITokenInfo {
+	return &APITokenInfo{
+		ID:         t.ID,
+		Name:       t.Name,
+		Scopes:     t.Scopes,
+		CreatedAt:  t.CreatedAt,
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+	}
+}
+
+func GetAPITokens(c *gin.Context) {
+	tokens := make([]*APITokenInfo, 0)
+	for _, t := range storeOf(c).GetAPITokens() {
+		tokens = append(tokens, newAPITokenInfo(t))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data":   tokens,
+	})
+}
+
+// CreateAPIToken mints a token of the authenticated user, ExpiresIn is days before the token
+// expires and 0 means never. The token is only returned in this response.
+func CreateAPIToken(c *gin.Context) {
+	var postData = struct {
+		Name      string
+		Scopes    []string
+		ExpiresIn int
+	}{}
+	err := c.ShouldBindJSON(&postData)
+	if err != nil {
+		respondError(c, "failed to bind post json to struct", errors.WithMessage(ErrInvalidParameter, err.Error()))
+		return
+	}
+
+	if postData.ExpiresIn < 0 {
+		err = errors.WithMessage(ErrInvalidParameter, "expires in should not be negative")
+		respondError(c, err.Error(), err)
+		return
+	}
+
+	var expiresAt int64
+	if postData.ExpiresIn > 0 {
+		expiresAt = time.Now().AddDate(0, 0, postData.ExpiresIn).Unix()
+	}
+
+	// tokens of server database are not bound to user, see auth.NewToken
+	user := tenantOf(c).Name
+	if auth.IsServerAdmin(user) {
+		user = ""
+	}
+
+	token, t, err := auth.CreateAPIToken(storeOf(c), user, postData.Name, postData.Scopes, expiresAt)
+	if err != nil {
+		respondError(c, "failed to create api token", err)
+		return
+	}
+
+	log.Infow("api token is created", "user", tenantOf(c).Name, "id", t.ID, "scopes", t.Scopes)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data": gin.H{
+			"Token":    token,
+			"APIToken": newAPITokenInfo(t),
+		},
+	})
+}
+
+func DeleteAPIToken(c *gin.Context) {
+	err := storeOf(c).DeleteAPIToken(c.Param("id"))
+	if err != nil {
+		respondError(c, "failed to delete api token", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data":   "",
+	})
+}
@@ -0,0 +1,89 @@
+package public
+
+import (
+	"net/http"
+
+	"github.com/fs714/github-star-manager/db/jsondb"
+	"github.com/fs714/github-star-manager/pkg/utils/code"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// RestarRepo marks an unstarred repository as starred and moves it back to the folder it is
+// archived from. Only db is changed, it is marked unstarred again by the next full sync unless
+// it is starred on github too.
+func RestarRepo(c *gin.Context) {
+	name := repositoryNameFromParam(c)
+
+	path, _, exist := storeOf(c).GetAllRepositoryByName(name)
+	if exist == nil {
+		respondError(c, "repository not found", jsondb.ErrRepositoryNotFound)
+		return
+	}
+
+	if exist.UnstarredAt == 0 {
+		err := errors.WithMessagef(ErrInvalidParameter, "repository %s is not unstarred", exist.Name)
+		respondError(c, err.Error(), err)
+		return
+	}
+
+	repo := *exist
+	target := repo.UnstarredFrom
+	repo.UnstarredAt = 0
+	repo.UnstarredFrom = nil
+
+	err := storeOf(c).UpdateRepository(&repo)
+	if err != nil {
+		respondError(c, "failed to update repository", err)
+		return
+	}
+
+	if target != nil && !equalPath(path, target) {
+		err = storeOf(c).MoveRepository(repo.Name, target)
+		if err != nil {
+			respondError(c, "failed to move repository", err)
+			return
+		}
+		path = target
+	}
+
+	respondRepository(c, http.StatusOK, path, &repo)
+}
+
+// PurgeUnstarred deletes unstarred repositories permanently, they could be limited by name list
+// and by before, which is compared with UnstarredAt and accepts the same formats as pushed_before.
+func PurgeUnstarred(c *gin.Context) {
+	before, err := parseTimeParam(c, "before")
+	if err != nil {
+		respondError(c, err.Error(), err)
+		return
+	}
+
+	names := make(map[string]bool)
+	for _, n := range splitList(c.Query("name")) {
+		names[n] = true
+	}
+
+	purged := make([]string, 0)
+	for _, r := range storeOf(c).GetAllRepositoryByPath([]string{}) {
+		if r.UnstarredAt == 0 || (before > 0 && r.UnstarredAt >= before) || (len(names) > 0 && !names[r.Name]) {
+			continue
+		}
+
+		purged = append(purged, r.Name)
+	}
+
+	count, err := storeOf(c).DeleteRepositories(purged)
+	if err != nil {
+		respondError(c, "failed to purge unstarred repositories", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data": gin.H{
+			"Deleted": count,
+		},
+	})
+}
@@ -0,0 +1,184 @@
+package public
+
+import (
+	"net/http"
+
+	"github.com/fs714/github-star-manager/api/middleware"
+	"github.com/fs714/github-star-manager/pkg/auth"
+	"github.com/fs714/github-star-manager/pkg/job"
+	"github.com/fs714/github-star-manager/pkg/tenant"
+	"github.com/fs714/github-star-manager/pkg/utils/code"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// UserInfo is user returned by api without hashes.
+type UserInfo struct {
+	Name      string
+	Admin     bool
+	CreatedAt int64
+	UpdatedAt int64
+}
+
+func newUserInfo(u *auth.User) *UserInfo {
+	return &UserInfo{
+		Name:      u.Name,
+		Admin:     u.Admin,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+	}
+}
+
+func GetUsers(c *gin.Context) {
+	users := make([]*UserInfo, 0)
+	for _, u := range auth.CurrentUserStore().List() {
+		users = append(users, newUserInfo(u))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data":   users,
+	})
+}
+
+func GetUser(c *gin.Context) {
+	u, err := auth.CurrentUserStore().Get(c.Param("name"))
+	if err != nil {
+		respondError(c, "failed to get user", err)
+		return
+	}
+
+	respondUser(c, http.StatusOK, u)
+}
+
+// CreateUser adds a user, whose database is created on first request.
+func CreateUser(c *gin.Context) {
+	var postData = struct {
+		Name     string
+		Password string
+		Admin    bool
+	}{}
+	err := c.ShouldBindJSON(&postData)
+	if err != nil {
+		respondError(c, "failed to bind post json to struct", errors.WithMessage(ErrInvalidParameter, err.Error()))
+		return
+	}
+
+	u, err := auth.CurrentUserStore().Create(postData.Name, postData.Password, postData.Admin)
+	if err != nil {
+		respondError(c, "failed to create user", err)
+		return
+	}
+
+	respondUser(c, http.StatusCreated, u)
+}
+
+// UpdateUser changes password or admin flag of user, fields missing in body are not changed.
+func UpdateUser(c *gin.Context) {
+	var patchData = struct {
+		Password *string
+		Admin    *bool
+	}{}
+	err := c.ShouldBindJSON(&patchData)
+	if err != nil {
+		respondError(c, "failed to bind post json to struct", errors.WithMessage(ErrInvalidParameter, err.Error()))
+		return
+	}
+
+	if patchData.Admin != nil && !*patchData.Admin && auth.IsServerAdmin(c.Param("name")) {
+		err = errors.WithMessage(auth.ErrInvalidUser, "admin user of server can not be demoted")
+		respondError(c, err.Error(), err)
+		return
+	}
+
+	u, err := auth.CurrentUserStore().Update(c.Param("name"), patchData.Password, patchData.Admin)
+	if err != nil {
+		respondError(c, "failed to update user", err)
+		return
+	}
+
+	respondUser(c, http.StatusOK, u)
+}
+
+// DeleteUser removes user and cancels running jobs of the user, data of the user is deleted as
+// well if query purge is true.
+func DeleteUser(c *gin.Context) {
+	name := auth.NormalizeName(c.Param("name"))
+	if auth.IsServerAdmin(name) {
+		err := errors.WithMessage(auth.ErrInvalidUser, "admin user of server can not be deleted")
+		respondError(c, err.Error(), err)
+		return
+	}
+
+	err := auth.CurrentUserStore().Delete(name)
+	if err != nil {
+		respondError(c, "failed to delete user", err)
+		return
+	}
+
+	for _, j := range job.Manager.List("") {
+		if j.Owner == name {
+			job.Manager.Cancel(j.ID)
+		}
+	}
+
+	err = tenant.CurrentManager().Remove(name, c.Query("purge") == "true")
+	if err != nil {
+		respondError(c, "failed to remove data of user", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data":   "",
+	})
+}
+
+func GetMe(c *gin.Context) {
+	u, err := auth.CurrentUserStore().Get(middleware.CurrentUser(c).Name)
+	if err != nil {
+		respondError(c, "failed to get user", err)
+		return
+	}
+
+	respondUser(c, http.StatusOK, u)
+}
+
+// UpdateMe changes password of the authenticated user, the current password is required.
+func UpdateMe(c *gin.Context) {
+	var patchData = struct {
+		CurrentPassword string
+		Password        string
+	}{}
+	err := c.ShouldBindJSON(&patchData)
+	if err != nil {
+		respondError(c, "failed to bind post json to struct", errors.WithMessage(ErrInvalidParameter, err.Error()))
+		return
+	}
+
+	name := middleware.CurrentUser(c).Name
+	_, err = auth.CurrentUserStore().Authenticate(name, patchData.CurrentPassword)
+	if err != nil {
+		err = errors.WithMessage(ErrInvalidParameter, "current password is wrong")
+		respondError(c, err.Error(), err)
+		return
+	}
+
+	u, err := auth.CurrentUserStore().Update(name, &patchData.Password, nil)
+	if err != nil {
+		respondError(c, "failed to update user", err)
+		return
+	}
+
+	respondUser(c, http.StatusOK, u)
+}
+
+func respondUser(c *gin.Context, status int, u *auth.User) {
+	c.JSON(status, gin.H{
+		"status": code.RespOk,
+		"msg":    "",
+		"data":   newUserInfo(u),
+	})
+}
@@ -0,0 +1,160 @@
+package public
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fs714/github-star-manager/api/middleware"
+	"github.com/fs714/github-star-manager/db"
+	"github.com/fs714/github-star-manager/pkg/auth"
+	"github.com/fs714/github-star-manager/pkg/config"
+	"github.com/fs714/github-star-manager/pkg/tenant"
+	"github.com/gin-gonic/gin"
+)
+
+func newAuthTestRouter(t *testing.T) (*gin.Engine, string) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+
+	old := config.Config.Auth
+	t.Cleanup(func() { config.Config.Auth = old })
+	config.Config.Auth = config.Auth{Enabled: true, UsersPath: filepath.Join(dir, "users.json"),
+		DataDir: filepath.Join(dir, "data"), AdminUser: "admin", AdminPassword: "password0"}
+
+	s, err := db.InitStore(config.Database{Driver: db.DriverJson, Path: filepath.Join(dir, "db.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	db.Storage = s
+
+	err = auth.InitUserStoreFromConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := tenant.NewManager("admin", config.Config.Auth.DataDir,
+		config.Database{Driver: db.DriverJson, Path: "./db.json"}, config.Github{})
+	t.Cleanup(func() { m.Close() })
+	tenant.SetManager(m)
+
+	r := gin.New()
+	g := r.Group("")
+	g.Use(middleware.AuthWithSkipPath(auth.CurrentUserStore(), m, []string{"/api/v1/health"}))
+	InitRoute(g)
+	InitShareRoute(r.Group(""), middleware.Share(auth.CurrentUserStore(), m))
+
+	return r, dir
+}
+
+func basicAuth(name string, password string) map[string]string {
+	return map[string]string{"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(name+":"+password))}
+}
+
+func TestAuthAndUsers(t *testing.T) {
+	r, dir := newAuthTestRouter(t)
+	admin := basicAuth("admin", "password0")
+	alice := basicAuth("alice", "password1")
+
+	cases := []struct {
+		method string
+		url    string
+		body   interface{}
+		header map[string]string
+		status int
+	}{
+		{http.MethodGet, "/api/v1/health", nil, nil, http.StatusOK},
+		{http.MethodGet, "/api/v1/repo", nil, nil, http.StatusUnauthorized},
+		{http.MethodGet, "/api/v1/repo", nil, basicAuth("admin", "wrong"), http.StatusUnauthorized},
+		{http.MethodGet, "/api/v1/repo", nil, map[string]string{"Authorization": "Bearer gsm_wrong"},
+			http.StatusUnauthorized},
+		{http.MethodPost, "/api/v1/repo", gin.H{"Name": "fs714/gsm"}, admin, http.StatusCreated},
+		{http.MethodPost, "/api/v1/users", gin.H{"Name": "alice", "Password": "password1"}, admin,
+			http.StatusCreated},
+		{http.MethodPost, "/api/v1/users", gin.H{"Name": "alice", "Password": "password1"}, admin,
+			http.StatusConflict},
+		{http.MethodPost, "/api/v1/users", gin.H{"Name": "bob", "Password": "short"}, admin, http.StatusBadRequest},
+		{http.MethodGet, "/api/v1/users", nil, alice, http.StatusForbidden},
+		{http.MethodDelete, "/api/v1/users/admin", nil, admin, http.StatusBadRequest},
+		{http.MethodPatch, "/api/v1/users/admin", gin.H{"Admin": false}, admin, http.StatusBadRequest},
+		// alice has her own database
+		{http.MethodGet, "/api/v1/repo/fs714/gsm", nil, alice, http.StatusNotFound},
+		{http.MethodPost, "/api/v1/repo", gin.H{"Name": "alice/repo"}, alice, http.StatusCreated},
+		{http.MethodGet, "/api/v1/repo/alice/repo", nil, admin, http.StatusNotFound},
+		{http.MethodPatch, "/api/v1/me", gin.H{"CurrentPassword": "wrong", "Password": "password2"}, alice,
+			http.StatusBadRequest},
+		{http.MethodGet, "/api/v1/me", nil, alice, http.StatusOK},
+		// scheduled sync writes the server database of admin
+		{http.MethodGet, "/api/v1/github/sync/schedule", nil, alice, http.StatusForbidden},
+		{http.MethodGet, "/api/v1/github/sync/schedule", nil, admin, http.StatusOK},
+	}
+
+	for _, c := range cases {
+		w := doRequestWithHeader(r, c.method, c.url, c.body, c.header)
+		if w.Code != c.status {
+			t.Fatalf("%s %s returns %d, expected %d: %s", c.method, c.url, w.Code, c.status, w.Body.String())
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "data", "alice", "db.json")); err != nil {
+		t.Errorf("database of alice is not created: %v", err)
+	}
+
+	// api token of alice is bound to her database and scopes
+	token := createAPIToken(t, r, alice, []string{"repo:read"})
+	for _, c := range []struct {
+		method string
+		url    string
+		status int
+	}{
+		{http.MethodGet, "/api/v1/repo/alice/repo", http.StatusOK},
+		{http.MethodDelete, "/api/v1/repo/alice/repo", http.StatusForbidden},
+		{http.MethodPost, "/api/v1/tokens", http.StatusForbidden},
+		{http.MethodGet, "/api/v1/users", http.StatusForbidden},
+	} {
+		if w := doRequestWithHeader(r, c.method, c.url, nil, token); w.Code != c.status {
+			t.Fatalf("%s %s with token returns %d, expected %d: %s", c.method, c.url, w.Code, c.status,
+				w.Body.String())
+		}
+	}
+
+	// token of server database belongs to admin
+	adminToken := createAPIToken(t, r, admin, []string{"admin"})
+	if w := doRequestWithHeader(r, http.MethodGet, "/api/v1/users", nil, adminToken); w.Code != http.StatusOK {
+		t.Fatalf("list users with admin token returns %d: %s", w.Code, w.Body.String())
+	}
+
+	w := doRequestWithHeader(r, http.MethodDelete, "/api/v1/users/alice?purge=true", nil, admin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("delete user returns %d: %s", w.Code, w.Body.String())
+	}
+
+	if w = doRequestWithHeader(r, http.MethodGet, "/api/v1/repo", nil, token); w.Code != http.StatusUnauthorized {
+		t.Errorf("token of deleted user returns %d", w.Code)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "data", "alice")); !os.IsNotExist(err) {
+		t.Errorf("data of purged user exists: %v", err)
+	}
+}
+
+// createAPIToken mints token with scopes by request authenticated with header, and returns
+// header authenticated by the token.
+func createAPIToken(t *testing.T, r *gin.Engine, header map[string]string, scopes []string) map[string]string {
+	w := doRequestWithHeader(r, http.MethodPost, "/api/v1/tokens", gin.H{"Name": "test", "Scopes": scopes,
+		"ExpiresIn": 1}, header)
+	var resp struct {
+		Data struct {
+			Token string
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Data.Token == "" {
+		t.Fatalf("create token returns %d: %s", w.Code, w.Body.String())
+	}
+
+	return map[string]string{"Authorization": "Bearer " + resp.Data.Token}
+}
@@ -0,0 +1,27 @@
+package db
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var dbPath string
+
+var StartCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Manage database",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+func InitStartCmd() {
+	StartCmd.PersistentFlags().SortFlags = false
+	StartCmd.Flags().SortFlags = false
+
+	StartCmd.PersistentFlags().StringVarP(&dbPath, "db-path", "", "",
+		"Path for database file, path in configuration file will be used if it is empty")
+
+	initMigrateCmd()
+
+	StartCmd.AddCommand(migrateCmd)
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/fs714/github-star-manager/db/jsondb"
+	"github.com/fs714/github-star-manager/pkg/config"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var dryRun bool
+
+var migrateCmd = &cobra.Command{
+	Use:          "migrate",
+	Short:        "Migrate json database to current schema version",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return migrate()
+	},
+}
+
+func initMigrateCmd() {
+	migrateCmd.Flags().BoolVarP(&dryRun, "dry-run", "", false, "Only report migrations which would run")
+}
+
+func getDbPath() string {
+	if dbPath != "" {
+		return dbPath
+	}
+
+	return config.Config.Database.Path
+}
+
+func migrate() error {
+	if config.Config.Database.Driver != "" && config.Config.Database.Driver != "json" {
+		return errors.Errorf("migrate is only supported by json driver, current driver is %s",
+			config.Config.Database.Driver)
+	}
+
+	path := getDbPath()
+	version, steps, err := jsondb.MigrateJsondb(path, dryRun)
+	if err != nil {
+		return errors.WithMessage(err, "failed to migrate database")
+	}
+
+	fmt.Printf("Database: %s\n", path)
+	fmt.Printf("Schema version: %d, current version: %d\n", version, jsondb.CurrentSchemaVersion)
+
+	if len(steps) == 0 {
+		fmt.Println("Database is up to date")
+		return nil
+	}
+
+	if dryRun {
+		fmt.Println("Migrations would run:")
+	} else {
+		fmt.Println("Migrations applied:")
+	}
+
+	for _, m := range steps {
+		fmt.Printf("  v%d: %s\n", m.Version, m.Description)
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package github
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fs714/github-star-manager/db"
+	"github.com/fs714/github-star-manager/pkg/config"
+	"github.com/fs714/github-star-manager/pkg/github_api"
+	"github.com/fs714/github-star-manager/pkg/syncer"
+	gh "github.com/google/go-github/v50/github"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dbPath string
+	dryRun bool
+	yes    bool
+)
+
+var StartCmd = &cobra.Command{
+	Use:   "github",
+	Short: "Star or unstar repositories on github and save the change into database",
+	Long: "Star or unstar repositories on github and save the change into database. The json database " +
+		"should not be changed while server is running, use the api of server instead.",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+func InitStartCmd() {
+	StartCmd.PersistentFlags().SortFlags = false
+	StartCmd.Flags().SortFlags = false
+
+	StartCmd.PersistentFlags().StringVarP(&dbPath, "db-path", "", "",
+		"Path for database file, path in configuration file will be used if it is empty")
+	StartCmd.PersistentFlags().BoolVarP(&dryRun, "dry-run", "", false,
+		"Only report what would be changed on github and in database")
+	StartCmd.PersistentFlags().BoolVarP(&yes, "yes", "y", false, "Do not ask for confirmation")
+
+	initStarCmd()
+
+	StartCmd.AddCommand(starCmd)
+	StartCmd.AddCommand(unstarCmd)
+}
+
+// initGithub opens database and github client configured by configuration file, the returned
+// function closes database.
+func initGithub() (func(), error) {
+	if dbPath != "" {
+		config.Config.Database.Path = dbPath
+	}
+
+	err := db.InitStoreFromConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	closeDb := func() {
+		db.Storage.Close()
+	}
+
+	err = github_api.InitTokenSourceFromConfig(db.Storage.GetGithubToken)
+	if err == nil {
+		err = github_api.InitCacheFromConfig()
+	}
+	if err == nil {
+		err = github_api.InitClientFromConfig()
+	}
+	if err == nil {
+		err = syncer.InitUnstarredPolicyFromConfig()
+	}
+	if err != nil {
+		closeDb()
+		return nil, err
+	}
+
+	return closeDb, nil
+}
+
+// run reports result of a dry run first, and runs fn for real after it is confirmed.
+func run(fn func(client *gh.Client, dryRun bool) (*syncer.StarResult, error)) error {
+	closeDb, err := initGithub()
+	if err != nil {
+		return err
+	}
+	defer closeDb()
+
+	client := github_api.CurrentClient()
+	result, err := fn(client, true)
+	if err != nil {
+		return err
+	}
+
+	printStarResult(result)
+	if dryRun {
+		return nil
+	}
+
+	if !yes && !confirm(fmt.Sprintf("Confirm %s of %s on github?", result.Action, result.Name)) {
+		return errors.New("canceled")
+	}
+
+	result, err = fn(client, false)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Done: %s %s on github\n", result.Action, result.Name)
+
+	return nil
+}
+
+func printStarResult(result *syncer.StarResult) {
+	fmt.Printf("Repository: %s\n", result.Name)
+	fmt.Printf("Starred on github: %v\n", result.Starred)
+
+	if result.Repository == nil {
+		fmt.Println("Database: not saved")
+		return
+	}
+
+	state := "starred"
+	if result.Repository.UnstarredAt != 0 {
+		state = "unstarred"
+	}
+	fmt.Printf("Database: %s in folder /%s\n", state, strings.Join(result.Path, "/"))
+}
+
+func confirm(prompt string) bool {
+	fmt.Printf("%s [y/N] ", prompt)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes"
+}
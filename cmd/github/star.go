@@ -0,0 +1,61 @@
+package github
+
+import (
+	"context"
+	"strings"
+
+	"github.com/fs714/github-star-manager/db"
+	"github.com/fs714/github-star-manager/pkg/syncer"
+	gh "github.com/google/go-github/v50/github"
+	"github.com/spf13/cobra"
+)
+
+var (
+	folder string
+	tags   []string
+	remove bool
+)
+
+var starCmd = &cobra.Command{
+	Use:          "star owner/name",
+	Short:        "Star repository on github and save its metadata into database",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return run(func(client *gh.Client, dryRun bool) (*syncer.StarResult, error) {
+			return syncer.Star(context.Background(), db.Storage, client, args[0], splitFolder(folder), tags, dryRun)
+		})
+	},
+}
+
+var unstarCmd = &cobra.Command{
+	Use:          "unstar owner/name",
+	Short:        "Unstar repository on github and apply unstarred policy to it in database",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return run(func(client *gh.Client, dryRun bool) (*syncer.StarResult, error) {
+			return syncer.Unstar(context.Background(), db.Storage, client, args[0], remove, dryRun)
+		})
+	},
+}
+
+func initStarCmd() {
+	starCmd.Flags().StringVarP(&folder, "folder", "", "", "Folder of new repository, nested folders are split by /")
+	starCmd.Flags().StringSliceVarP(&tags, "tags", "", []string{}, "Tags of new repository")
+
+	unstarCmd.Flags().BoolVarP(&remove, "remove", "", false, "Delete repository from database instead of "+
+		"applying unstarred policy")
+}
+
+func splitFolder(folder string) []string {
+	path := make([]string, 0)
+	for _, f := range strings.Split(folder, "/") {
+		f = strings.TrimSpace(f)
+		if f != "" {
+			path = append(path, f)
+		}
+	}
+
+	return path
+}
@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"os"
 
+	cmd_db "github.com/fs714/github-star-manager/cmd/db"
+	cmd_github "github.com/fs714/github-star-manager/cmd/github"
 	cmd_server "github.com/fs714/github-star-manager/cmd/server"
+	cmd_token "github.com/fs714/github-star-manager/cmd/token"
 	cmd_version "github.com/fs714/github-star-manager/cmd/version"
 	"github.com/fs714/github-star-manager/pkg/config"
 	"github.com/fs714/github-star-manager/pkg/utils/log"
@@ -46,9 +49,15 @@ func init() {
 
 	cmd_version.InitStartCmd()
 	cmd_server.InitStartCmd()
+	cmd_db.InitStartCmd()
+	cmd_github.InitStartCmd()
+	cmd_token.InitStartCmd()
 
 	rootCmd.AddCommand(cmd_version.StartCmd)
 	rootCmd.AddCommand(cmd_server.StartCmd)
+	rootCmd.AddCommand(cmd_db.StartCmd)
+	rootCmd.AddCommand(cmd_github.StartCmd)
+	rootCmd.AddCommand(cmd_token.StartCmd)
 }
 
 func initConfig() {
@@ -11,8 +11,13 @@ import (
 	"time"
 
 	"github.com/fs714/github-star-manager/api"
-	"github.com/fs714/github-star-manager/db/jsondb"
+	"github.com/fs714/github-star-manager/db"
+	"github.com/fs714/github-star-manager/pkg/auth"
 	"github.com/fs714/github-star-manager/pkg/config"
+	"github.com/fs714/github-star-manager/pkg/github_api"
+	"github.com/fs714/github-star-manager/pkg/job"
+	"github.com/fs714/github-star-manager/pkg/syncer"
+	"github.com/fs714/github-star-manager/pkg/tenant"
 	"github.com/fs714/github-star-manager/pkg/utils/log"
 	"github.com/fs714/github-star-manager/pkg/utils/version"
 	"github.com/pkg/errors"
@@ -20,14 +25,42 @@ import (
 )
 
 var (
-	httpHost     string
-	httpPort     string
-	readTimeout  int
-	writeTimeout int
-	dbPath       string
-	logFile      string
-	logLevel     string
-	logFormat    string
+	httpHost         string
+	httpPort         string
+	readTimeout      int
+	writeTimeout     int
+	corsOrigins      []string
+	authEnabled      bool
+	authUsersPath    string
+	authDataDir      string
+	adminUser        string
+	adminPassword    string
+	dbDriver         string
+	dbPath           string
+	dbWriteDelay     int
+	dbWal            bool
+	logFile          string
+	logLevel         string
+	logFormat        string
+	tokenSource      string
+	tokenFile        string
+	tokenEnv         string
+	githubBaseURL    string
+	githubUploadURL  string
+	githubCAFile     string
+	githubProxy      string
+	githubTimeout    int
+	fullSyncInterval int
+	githubCacheDir   string
+	checkpointDir    string
+	maxRetries       int
+	maxRateLimitWait int
+	syncUsers        []string
+	syncCron         string
+	syncInterval     int
+	syncJitter       int
+	unstarredPolicy  string
+	unstarredPath    string
 )
 
 var StartCmd = &cobra.Command{
@@ -67,10 +100,56 @@ func InitStartCmd() {
 	config.Viper.BindPFlag("http_server.write_timeout", StartCmd.Flags().Lookup("write-timeout"))
 	config.Viper.BindEnv("http_server.write_timeout", "HTTP_WRITE_TIMEOUT")
 
+	StartCmd.Flags().StringSliceVarP(&corsOrigins, "cors-allow-origins", "",
+		config.DefaultConfig.HttpServer.CorsAllowOrigins,
+		"Origins allowed by cors, * allows all unless auth is enabled and empty disables cors")
+	config.Viper.BindPFlag("http_server.cors_allow_origins", StartCmd.Flags().Lookup("cors-allow-origins"))
+	config.Viper.BindEnv("http_server.cors_allow_origins", "HTTP_CORS_ALLOW_ORIGINS")
+
+	StartCmd.Flags().BoolVarP(&authEnabled, "auth", "", config.DefaultConfig.Auth.Enabled,
+		"Require users to authenticate by password or api token")
+	config.Viper.BindPFlag("auth.enabled", StartCmd.Flags().Lookup("auth"))
+	config.Viper.BindEnv("auth.enabled", "AUTH_ENABLED")
+
+	StartCmd.Flags().StringVarP(&authUsersPath, "auth-users-path", "", config.DefaultConfig.Auth.UsersPath,
+		"Path for file of users")
+	config.Viper.BindPFlag("auth.users_path", StartCmd.Flags().Lookup("auth-users-path"))
+	config.Viper.BindEnv("auth.users_path", "AUTH_USERS_PATH")
+
+	StartCmd.Flags().StringVarP(&authDataDir, "auth-data-dir", "", config.DefaultConfig.Auth.DataDir,
+		"Dir of databases of users other than admin")
+	config.Viper.BindPFlag("auth.data_dir", StartCmd.Flags().Lookup("auth-data-dir"))
+	config.Viper.BindEnv("auth.data_dir", "AUTH_DATA_DIR")
+
+	StartCmd.Flags().StringVarP(&adminUser, "auth-admin-user", "", config.DefaultConfig.Auth.AdminUser,
+		"Admin user which uses the server database and github token")
+	config.Viper.BindPFlag("auth.admin_user", StartCmd.Flags().Lookup("auth-admin-user"))
+	config.Viper.BindEnv("auth.admin_user", "AUTH_ADMIN_USER")
+
+	StartCmd.Flags().StringVarP(&adminPassword, "auth-admin-password", "", config.DefaultConfig.Auth.AdminPassword,
+		"Password of admin user created on first start, a random one is logged if it is empty")
+	config.Viper.BindPFlag("auth.admin_password", StartCmd.Flags().Lookup("auth-admin-password"))
+	config.Viper.BindEnv("auth.admin_password", "AUTH_ADMIN_PASSWORD")
+
+	StartCmd.Flags().StringVarP(&dbDriver, "db-driver", "", config.DefaultConfig.Database.Driver,
+		"Database driver, could be json or sqlite")
+	config.Viper.BindPFlag("database.driver", StartCmd.Flags().Lookup("db-driver"))
+	config.Viper.BindEnv("database.driver", "DB_DRIVER")
+
 	StartCmd.Flags().StringVarP(&dbPath, "db-path", "", config.DefaultConfig.Database.Path, "Path for database file")
 	config.Viper.BindPFlag("database.path", StartCmd.Flags().Lookup("db-path"))
 	config.Viper.BindEnv("database.path", "DB_PATH")
 
+	StartCmd.Flags().IntVarP(&dbWriteDelay, "db-write-delay", "", config.DefaultConfig.Database.WriteDelay,
+		"Milliseconds to coalesce writes of json database, 0 means write on every change")
+	config.Viper.BindPFlag("database.write_delay", StartCmd.Flags().Lookup("db-write-delay"))
+	config.Viper.BindEnv("database.write_delay", "DB_WRITE_DELAY")
+
+	StartCmd.Flags().BoolVarP(&dbWal, "db-wal", "", config.DefaultConfig.Database.WAL,
+		"Log changes of json database waiting for db-write-delay into write-ahead log, it needs db-write-delay")
+	config.Viper.BindPFlag("database.wal", StartCmd.Flags().Lookup("db-wal"))
+	config.Viper.BindEnv("database.wal", "DB_WAL")
+
 	StartCmd.Flags().StringVarP(&logFile, "log-file", "", config.DefaultConfig.Logging.File,
 		"Set logging file, stderr will be used if file is empty string")
 	config.Viper.BindPFlag("logging.file", StartCmd.Flags().Lookup("log-file"))
@@ -85,6 +164,101 @@ func InitStartCmd() {
 		"Set logging format, could be console or json")
 	config.Viper.BindPFlag("logging.format", StartCmd.Flags().Lookup("log-format"))
 	config.Viper.BindEnv("logging.format", "LOGGING_FORMAT")
+
+	StartCmd.Flags().StringVarP(&tokenSource, "github-token-source", "", config.DefaultConfig.Github.TokenSource,
+		"Set where to read github token from, could be db, file or env")
+	config.Viper.BindPFlag("github.token_source", StartCmd.Flags().Lookup("github-token-source"))
+	config.Viper.BindEnv("github.token_source", "GITHUB_TOKEN_SOURCE")
+
+	StartCmd.Flags().StringVarP(&tokenFile, "github-token-file", "", config.DefaultConfig.Github.TokenFile,
+		"File containing github token, used when token source is file")
+	config.Viper.BindPFlag("github.token_file", StartCmd.Flags().Lookup("github-token-file"))
+	config.Viper.BindEnv("github.token_file", "GITHUB_TOKEN_FILE")
+
+	StartCmd.Flags().StringVarP(&tokenEnv, "github-token-env", "", config.DefaultConfig.Github.TokenEnv,
+		"Environment variable containing github token, used when token source is env")
+	config.Viper.BindPFlag("github.token_env", StartCmd.Flags().Lookup("github-token-env"))
+	config.Viper.BindEnv("github.token_env", "GITHUB_TOKEN_ENV")
+
+	StartCmd.Flags().StringVarP(&githubBaseURL, "github-base-url", "", config.DefaultConfig.Github.BaseURL,
+		"Api url of GitHub Enterprise Server, empty string means github.com")
+	config.Viper.BindPFlag("github.base_url", StartCmd.Flags().Lookup("github-base-url"))
+	config.Viper.BindEnv("github.base_url", "GITHUB_BASE_URL")
+
+	StartCmd.Flags().StringVarP(&githubUploadURL, "github-upload-url", "", config.DefaultConfig.Github.UploadURL,
+		"Upload url of GitHub Enterprise Server, empty string means the same as base url")
+	config.Viper.BindPFlag("github.upload_url", StartCmd.Flags().Lookup("github-upload-url"))
+	config.Viper.BindEnv("github.upload_url", "GITHUB_UPLOAD_URL")
+
+	StartCmd.Flags().StringVarP(&githubCAFile, "github-ca-file", "", config.DefaultConfig.Github.CAFile,
+		"PEM bundle of CA certificates trusted by github client in addition to system ones")
+	config.Viper.BindPFlag("github.ca_file", StartCmd.Flags().Lookup("github-ca-file"))
+	config.Viper.BindEnv("github.ca_file", "GITHUB_CA_FILE")
+
+	StartCmd.Flags().StringVarP(&githubProxy, "github-proxy", "", config.DefaultConfig.Github.Proxy,
+		"Http proxy url of github client, empty string means proxy environment variables are used")
+	config.Viper.BindPFlag("github.proxy", StartCmd.Flags().Lookup("github-proxy"))
+	config.Viper.BindEnv("github.proxy", "GITHUB_PROXY")
+
+	StartCmd.Flags().IntVarP(&githubTimeout, "github-timeout", "", config.DefaultConfig.Github.Timeout,
+		"Seconds to wait for connection and response header of one github request, 0 means no timeout")
+	config.Viper.BindPFlag("github.timeout", StartCmd.Flags().Lookup("github-timeout"))
+	config.Viper.BindEnv("github.timeout", "GITHUB_TIMEOUT")
+
+	StartCmd.Flags().IntVarP(&fullSyncInterval, "github-full-sync-interval", "",
+		config.DefaultConfig.Github.FullSyncInterval, "Hours between full syncs of github stars, 0 means every sync is full")
+	config.Viper.BindPFlag("github.full_sync_interval", StartCmd.Flags().Lookup("github-full-sync-interval"))
+	config.Viper.BindEnv("github.full_sync_interval", "GITHUB_FULL_SYNC_INTERVAL")
+
+	StartCmd.Flags().StringVarP(&githubCacheDir, "github-cache-dir", "", config.DefaultConfig.Github.CacheDir,
+		"Dir to cache etag of github responses, empty string disables cache")
+	config.Viper.BindPFlag("github.cache_dir", StartCmd.Flags().Lookup("github-cache-dir"))
+	config.Viper.BindEnv("github.cache_dir", "GITHUB_CACHE_DIR")
+
+	StartCmd.Flags().StringVarP(&checkpointDir, "github-checkpoint-dir", "", config.DefaultConfig.Github.CheckpointDir,
+		"Dir to keep pages fetched by an interrupted sync, empty string disables checkpoint")
+	config.Viper.BindPFlag("github.checkpoint_dir", StartCmd.Flags().Lookup("github-checkpoint-dir"))
+	config.Viper.BindEnv("github.checkpoint_dir", "GITHUB_CHECKPOINT_DIR")
+
+	StartCmd.Flags().IntVarP(&maxRetries, "github-max-retries", "", config.DefaultConfig.Github.MaxRetries,
+		"Max retries of a github request failed with server error")
+	config.Viper.BindPFlag("github.max_retries", StartCmd.Flags().Lookup("github-max-retries"))
+	config.Viper.BindEnv("github.max_retries", "GITHUB_MAX_RETRIES")
+
+	StartCmd.Flags().IntVarP(&maxRateLimitWait, "github-max-rate-limit-wait", "",
+		config.DefaultConfig.Github.MaxRateLimitWait, "Max seconds to wait for github rate limit reset")
+	config.Viper.BindPFlag("github.max_rate_limit_wait", StartCmd.Flags().Lookup("github-max-rate-limit-wait"))
+	config.Viper.BindEnv("github.max_rate_limit_wait", "GITHUB_MAX_RATE_LIMIT_WAIT")
+
+	StartCmd.Flags().StringSliceVarP(&syncUsers, "sync-users", "", config.DefaultConfig.Sync.Users,
+		"Github users whose stars are synced periodically")
+	config.Viper.BindPFlag("sync.users", StartCmd.Flags().Lookup("sync-users"))
+	config.Viper.BindEnv("sync.users", "SYNC_USERS")
+
+	StartCmd.Flags().StringVarP(&syncCron, "sync-cron", "", config.DefaultConfig.Sync.Cron,
+		"Cron expression of scheduled sync, takes precedence over interval")
+	config.Viper.BindPFlag("sync.cron", StartCmd.Flags().Lookup("sync-cron"))
+	config.Viper.BindEnv("sync.cron", "SYNC_CRON")
+
+	StartCmd.Flags().IntVarP(&syncInterval, "sync-interval", "", config.DefaultConfig.Sync.Interval,
+		"Minutes between scheduled syncs, 0 disables scheduled sync if cron is empty")
+	config.Viper.BindPFlag("sync.interval", StartCmd.Flags().Lookup("sync-interval"))
+	config.Viper.BindEnv("sync.interval", "SYNC_INTERVAL")
+
+	StartCmd.Flags().IntVarP(&syncJitter, "sync-jitter", "", config.DefaultConfig.Sync.Jitter,
+		"Max seconds of random delay added to every scheduled sync")
+	config.Viper.BindPFlag("sync.jitter", StartCmd.Flags().Lookup("sync-jitter"))
+	config.Viper.BindEnv("sync.jitter", "SYNC_JITTER")
+
+	StartCmd.Flags().StringVarP(&unstarredPolicy, "sync-unstarred-policy", "", config.DefaultConfig.Sync.UnstarredPolicy,
+		"What full sync does to repositories no longer starred, could be drop, keep or archive")
+	config.Viper.BindPFlag("sync.unstarred_policy", StartCmd.Flags().Lookup("sync-unstarred-policy"))
+	config.Viper.BindEnv("sync.unstarred_policy", "SYNC_UNSTARRED_POLICY")
+
+	StartCmd.Flags().StringVarP(&unstarredPath, "sync-unstarred-path", "", config.DefaultConfig.Sync.UnstarredPath,
+		"Folder of archived unstarred repositories, nested folders are split by /")
+	config.Viper.BindPFlag("sync.unstarred_path", StartCmd.Flags().Lookup("sync-unstarred-path"))
+	config.Viper.BindEnv("sync.unstarred_path", "SYNC_UNSTARRED_PATH")
 }
 
 func initLog() {
@@ -115,19 +289,89 @@ func initLog() {
 func startServer() (err error) {
 	log.Infow("start http server", "BaseVersion", version.BaseVersion, "GitVersion", version.GitVersion)
 
-	log.Infow("initialize database", "path", config.Config.Database.Path)
-	err = jsondb.InitJsondbFromConfig()
+	log.Infow("initialize database", "driver", config.Config.Database.Driver, "path", config.Config.Database.Path)
+	err = db.InitStoreFromConfig()
 	if err != nil {
 		log.Errorf("failed to initialize database:\n%+v", err)
 		return
 	}
 
+	log.Infow("initialize github token source", "source", config.Config.Github.TokenSource)
+	err = github_api.InitTokenSourceFromConfig(db.Storage.GetGithubToken)
+	if err != nil {
+		log.Errorf("failed to initialize github token source:\n%+v", err)
+		return
+	}
+
+	log.Infow("initialize github cache", "dir", config.Config.Github.CacheDir)
+	err = github_api.InitCacheFromConfig()
+	if err != nil {
+		log.Errorf("failed to initialize github cache:\n%+v", err)
+		return
+	}
+
+	log.Infow("initialize github client", "base_url", config.Config.Github.BaseURL,
+		"ca_file", config.Config.Github.CAFile)
+	err = github_api.InitClientFromConfig()
+	if err != nil {
+		log.Errorf("failed to initialize github client:\n%+v", err)
+		return
+	}
+
+	log.Infow("initialize github checkpoint", "dir", config.Config.Github.CheckpointDir)
+	err = github_api.InitCheckpointFromConfig()
+	if err != nil {
+		log.Errorf("failed to initialize github checkpoint:\n%+v", err)
+		return
+	}
+
+	log.Infow("initialize unstarred policy", "policy", config.Config.Sync.UnstarredPolicy,
+		"path", config.Config.Sync.UnstarredPath)
+	err = syncer.InitUnstarredPolicyFromConfig()
+	if err != nil {
+		log.Errorf("failed to initialize unstarred policy:\n%+v", err)
+		return
+	}
+
+	if config.Config.Auth.Enabled {
+		for _, origin := range config.Config.HttpServer.CorsAllowOrigins {
+			if origin == "*" {
+				log.Errorf("cors should not allow all origins when auth is enabled, list allowed origins instead")
+				return
+			}
+		}
+
+		log.Infow("initialize users", "path", config.Config.Auth.UsersPath, "data_dir", config.Config.Auth.DataDir)
+		err = auth.InitUserStoreFromConfig()
+		if err != nil {
+			log.Errorf("failed to initialize users:\n%+v", err)
+			return
+		}
+	}
+	tenant.InitManagerFromConfig()
+
+	log.Infow("initialize sync scheduler", "users", config.Config.Sync.Users, "cron", config.Config.Sync.Cron,
+		"interval", config.Config.Sync.Interval)
+	err = syncer.InitSchedulerFromConfig()
+	if err != nil {
+		log.Errorf("failed to initialize sync scheduler:\n%+v", err)
+		return
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	signalCh := make(chan os.Signal, 1)
 	signal.Notify(signalCh, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 
 	exitWg := &sync.WaitGroup{}
 
+	if syncer.CurrentScheduler != nil {
+		exitWg.Add(1)
+		go func(ctx context.Context) {
+			defer exitWg.Done()
+			syncer.CurrentScheduler.Run(ctx)
+		}(ctx)
+	}
+
 	{
 		router := api.InitRouter()
 		srv := &http.Server{
@@ -172,6 +416,27 @@ func startServer() (err error) {
 	<-signalCh
 	cancel()
 	exitWg.Wait()
+
+	log.Infow("cancel running jobs")
+	jctx, jcancel := context.WithTimeout(context.Background(), 30*time.Second)
+	err = job.Manager.Shutdown(jctx)
+	jcancel()
+	if err != nil {
+		log.Errorf("failed to shutdown jobs:\n%+v", err)
+	}
+
+	log.Infow("flush and close databases of users")
+	err = tenant.CurrentManager().Close()
+	if err != nil {
+		log.Errorf("failed to close databases of users:\n%+v", err)
+	}
+
+	log.Infow("flush and close database")
+	err = db.Storage.Close()
+	if err != nil {
+		log.Errorf("failed to close database:\n%+v", err)
+	}
+
 	log.Infow("github-star-manager exit")
 
 	return
@@ -0,0 +1,167 @@
+package token
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fs714/github-star-manager/db"
+	"github.com/fs714/github-star-manager/db/jsondb"
+	"github.com/fs714/github-star-manager/pkg/auth"
+	"github.com/fs714/github-star-manager/pkg/config"
+	"github.com/fs714/github-star-manager/pkg/tenant"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dbPath    string
+	user      string
+	name      string
+	scopes    []string
+	expiresIn int
+)
+
+var StartCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Manage personal api tokens",
+	Long: "Manage personal api tokens saved hashed in database. The json database should not be changed " +
+		"while server is running, use the api of server instead.",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var createCmd = &cobra.Command{
+	Use:          "create",
+	Short:        "Create an api token, the token is only printed once",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withStore(func(store db.Store, tokenUser string) error {
+			var expiresAt int64
+			if expiresIn > 0 {
+				expiresAt = time.Now().AddDate(0, 0, expiresIn).Unix()
+			}
+
+			token, t, err := auth.CreateAPIToken(store, tokenUser, name, scopes, expiresAt)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("ID: %s\n", t.ID)
+			fmt.Printf("Scopes: %s\n", strings.Join(t.Scopes, ","))
+			fmt.Printf("Expires: %s\n", formatTime(t.ExpiresAt, "never"))
+			fmt.Printf("Token: %s\n", token)
+
+			return nil
+		})
+	},
+}
+
+var listCmd = &cobra.Command{
+	Use:          "list",
+	Short:        "List api tokens",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withStore(func(store db.Store, tokenUser string) error {
+			printTokens(store.GetAPITokens())
+			return nil
+		})
+	},
+}
+
+var deleteCmd = &cobra.Command{
+	Use:          "delete id",
+	Short:        "Delete an api token",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withStore(func(store db.Store, tokenUser string) error {
+			err := store.DeleteAPIToken(args[0])
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Deleted api token %s\n", args[0])
+			return nil
+		})
+	},
+}
+
+func InitStartCmd() {
+	StartCmd.PersistentFlags().SortFlags = false
+	StartCmd.Flags().SortFlags = false
+
+	StartCmd.PersistentFlags().StringVarP(&dbPath, "db-path", "", "",
+		"Path for database file, path in configuration file will be used if it is empty")
+	StartCmd.PersistentFlags().StringVarP(&user, "user", "u", "",
+		"User owning tokens when auth is enabled, empty means admin user whose database is db-path")
+
+	createCmd.Flags().StringVarP(&name, "name", "", "", "Name describing what the token is used for")
+	createCmd.Flags().StringSliceVarP(&scopes, "scopes", "", []string{jsondb.ScopeRepoRead},
+		"Scopes of token, could be "+strings.Join(jsondb.Scopes, ", "))
+	createCmd.Flags().IntVarP(&expiresIn, "expires-in", "", 90, "Days before token expires, 0 means never")
+
+	StartCmd.AddCommand(createCmd)
+	StartCmd.AddCommand(listCmd)
+	StartCmd.AddCommand(deleteCmd)
+}
+
+// withStore opens database of user and runs fn with user which new tokens are bound to, which
+// is empty for the server database.
+func withStore(fn func(store db.Store, tokenUser string) error) error {
+	if dbPath != "" {
+		config.Config.Database.Path = dbPath
+	}
+
+	err := db.InitStoreFromConfig()
+	if err != nil {
+		return err
+	}
+	defer db.Storage.Close()
+
+	if user == "" || auth.IsServerAdmin(user) {
+		return fn(db.Storage, "")
+	}
+
+	users, err := auth.NewUserStore(config.Config.Auth.UsersPath)
+	if err != nil {
+		return err
+	}
+
+	if _, err = users.Get(user); err != nil {
+		return errors.WithMessagef(err, "failed to find user %s", user)
+	}
+
+	tenant.InitManagerFromConfig()
+	defer tenant.CurrentManager().Close()
+
+	t, err := tenant.CurrentManager().Get(user)
+	if err != nil {
+		return err
+	}
+
+	return fn(t.Store, t.Name)
+}
+
+func printTokens(tokens []*jsondb.APIToken) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSCOPES\tCREATED\tEXPIRES\tLAST USED")
+	for _, t := range tokens {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", t.ID, t.Name, strings.Join(t.Scopes, ","),
+			formatTime(t.CreatedAt, "-"), formatTime(t.ExpiresAt, "never"), formatTime(t.LastUsedAt, "never"))
+	}
+	w.Flush()
+}
+
+func formatTime(unix int64, zero string) string {
+	if unix == 0 {
+		return zero
+	}
+
+	return time.Unix(unix, 0).Format(time.RFC3339)
+}
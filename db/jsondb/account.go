@@ -0,0 +1,69 @@
+package jsondb
+
+import (
+	"sort"
+	"strings"
+)
+
+type AccountCount struct {
+	Account string
+	Count   int
+}
+
+// HasAccount reports whether account is in accounts, accounts are lower-cased logins.
+func HasAccount(accounts []string, account string) bool {
+	account = strings.ToLower(account)
+	for _, a := range accounts {
+		if a == account {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AddAccount returns accounts with account appended if it is not in accounts yet.
+func AddAccount(accounts []string, account string) []string {
+	if HasAccount(accounts, account) {
+		return accounts
+	}
+
+	result := make([]string, 0, len(accounts)+1)
+	result = append(result, accounts...)
+
+	return append(result, strings.ToLower(account))
+}
+
+// RemoveAccount returns a copy of accounts without account.
+func RemoveAccount(accounts []string, account string) []string {
+	account = strings.ToLower(account)
+	result := make([]string, 0, len(accounts))
+	for _, a := range accounts {
+		if a != account {
+			result = append(result, a)
+		}
+	}
+
+	return result
+}
+
+// GetAccountCounts returns number of repositories starred by every account ordered by account.
+func (rs *Repositories) GetAccountCounts() []*AccountCount {
+	counts := make(map[string]int)
+	for _, r := range rs.GetAllRepositoryByPath([]string{}) {
+		for _, a := range r.StarredBy {
+			counts[a]++
+		}
+	}
+
+	result := make([]*AccountCount, 0, len(counts))
+	for a, c := range counts {
+		result = append(result, &AccountCount{Account: a, Count: c})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Account < result[j].Account
+	})
+
+	return result
+}
@@ -4,79 +4,199 @@ import (
 	"encoding/json"
 	"os"
 	"sync"
+	"time"
 
-	"github.com/fs714/github-star-manager/pkg/config"
+	"github.com/fs714/github-star-manager/pkg/utils/log"
 	"github.com/pkg/errors"
 )
 
-var Jsondb JsonConfig
+var ErrWALWithoutDelay = errors.New("wal needs write delay")
 
-func InitJsondbFromConfig() (err error) {
-	err = InitJsondb(config.Config.Database.Path)
-	if err != nil {
-		errors.WithMessage(err, "failed to init db from config")
-		return
+type Options struct {
+	// WriteDelay coalesces all writes happened in this duration into one, 0 means write immediately.
+	WriteDelay time.Duration
+	// WAL appends every change to a write-ahead log before it is acknowledged, so changes
+	// waiting for a delayed write could be replayed after crash. Without it they are lost. It
+	// needs WriteDelay, since every change is written into file at once otherwise.
+	WAL bool
+}
+
+func InitJsondb(path string, opts Options) (*JsonConfig, error) {
+	if opts.WAL && opts.WriteDelay <= 0 {
+		return nil, ErrWALWithoutDelay
 	}
 
-	return
-}
+	if !opts.WAL && opts.WriteDelay > 0 {
+		log.Warnw("wal is disabled, changes waiting for delayed write are lost by crash", "path", path)
+	}
 
-func InitJsondb(path string) (err error) {
-	Jsondb = JsonConfig{
-		Path:         path,
-		Common:       &Common{},
-		Repositories: NewRepositories(),
+	j := &JsonConfig{
+		SchemaVersion: CurrentSchemaVersion,
+		Path:          path,
+		Common:        &Common{},
+		Repositories:  NewRepositories(),
+		opts:          opts,
 	}
 
-	if _, err := os.Stat(path); err == nil {
-		err = Jsondb.Read()
+	var err error
+	if _, err = os.Stat(path); err == nil {
+		err = j.Read()
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		return nil
+		if j.migrated {
+			err = j.Write()
+			if err != nil {
+				return nil, err
+			}
+		}
 	} else if errors.Is(err, os.ErrNotExist) {
-		return Jsondb.Write()
+		err = j.Write()
+		if err != nil {
+			return nil, err
+		}
 	} else {
-		return errors.Wrap(err, "stat error")
+		return nil, errors.Wrap(err, "stat error")
 	}
+
+	if opts.WAL {
+		j.wal, err = openWal(walPath(path))
+		if err != nil {
+			return nil, err
+		}
+
+		replayed, err := j.wal.Replay(j)
+		if err != nil {
+			j.wal.Close()
+			return nil, err
+		}
+
+		if replayed > 0 {
+			err = j.Write()
+			if err != nil {
+				j.wal.Close()
+				return nil, err
+			}
+		}
+	}
+
+	if opts.WriteDelay > 0 {
+		j.writer = newWriter(j, opts.WriteDelay)
+	}
+
+	return j, nil
 }
 
 type JsonConfig struct {
-	Path         string
-	Common       *Common
-	Repositories *Repositories
-	sync.RWMutex
+	SchemaVersion int
+	Path          string `json:"-"`
+	Common        *Common
+	Repositories  *Repositories
+	SyncHistory   []*SyncHistory `json:",omitempty"`
+	// WalSeq is Seq of the last wal entry written into file
+	WalSeq       int64 `json:",omitempty"`
+	sync.RWMutex `json:"-"`
+	opts         Options
+	wal          *wal
+	writer       *writer
+	migrated     bool
 }
 
+// Read loads db file, and upgrades it to current schema version if it is written by an
+// older version.
 func (j *JsonConfig) Read() error {
 	data, err := os.ReadFile(j.Path)
 	if err != nil {
 		return errors.Wrap(err, "read file error")
 	}
 
+	data, applied, err := migrate(j.Path, data, false)
+	if err != nil {
+		return err
+	}
+	j.migrated = len(applied) > 0
+
 	err = json.Unmarshal(data, j)
 	if err != nil {
 		return errors.Wrap(err, "unmarshal error")
 	}
 
+	j.Repositories.RebuildIndexes()
+
 	return nil
 }
 
+// Write persists the whole db into file, caller should hold the lock. Data is written into
+// a temp file and renamed to target path, so the file is either old or new after crash.
 func (j *JsonConfig) Write() error {
+	if j.wal != nil {
+		j.WalSeq = j.wal.seq
+	}
+
 	data, err := json.MarshalIndent(j, "", "  ")
 	if err != nil {
 		return errors.Wrap(err, "marshal error")
 	}
 
-	err = os.WriteFile(j.Path, data, 0644)
+	err = writeFileAtomic(j.Path, data, 0644)
 	if err != nil {
 		return errors.Wrap(err, "write file error")
 	}
 
+	if j.wal != nil {
+		err = j.wal.Truncate()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// save records the change described by entry and schedules a write, caller should hold the lock.
+func (j *JsonConfig) save(entry *walEntry) error {
+	if j.writer == nil {
+		return j.Write()
+	}
+
+	if j.wal != nil {
+		err := j.wal.Append(entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	j.writer.Notify()
+
 	return nil
 }
 
+// Flush writes pending changes into file immediately.
+func (j *JsonConfig) Flush() error {
+	if j.writer == nil {
+		return nil
+	}
+
+	return j.writer.Flush()
+}
+
+func (j *JsonConfig) Close() error {
+	var err error
+	if j.writer != nil {
+		err = j.writer.Close()
+	}
+
+	if j.wal != nil {
+		werr := j.wal.Close()
+		if err == nil {
+			err = werr
+		}
+	}
+
+	return err
+}
+
 func (j *JsonConfig) GetGithubToken() string {
 	j.RLock()
 	defer j.RUnlock()
@@ -90,25 +210,169 @@ func (j *JsonConfig) UpdateGithubToken(token string) error {
 
 	j.Common.GithubToken = token
 
-	return j.Write()
+	return j.save(&walEntry{Op: walOpToken, Token: token})
+}
+
+func (j *JsonConfig) GetSyncState(user string) *SyncState {
+	j.RLock()
+	defer j.RUnlock()
+
+	return j.Common.GetSyncState(user)
+}
+
+func (j *JsonConfig) UpdateSyncState(user string, state *SyncState) error {
+	j.Lock()
+	defer j.Unlock()
+
+	j.Common.UpdateSyncState(user, state)
+
+	return j.save(&walEntry{Op: walOpSyncState, Name: user, SyncState: state})
+}
+
+func (j *JsonConfig) GetAPITokens() []*APIToken {
+	j.RLock()
+	defer j.RUnlock()
+
+	return j.Common.GetAPITokens()
+}
+
+func (j *JsonConfig) GetAPITokenByHash(hash string) *APIToken {
+	j.RLock()
+	defer j.RUnlock()
+
+	return j.Common.GetAPITokenByHash(hash)
+}
+
+func (j *JsonConfig) AddAPIToken(token *APIToken) error {
+	j.Lock()
+	defer j.Unlock()
+
+	j.Common.AddAPIToken(token)
+
+	return j.saveAPITokens()
+}
+
+func (j *JsonConfig) DeleteAPIToken(id string) error {
+	j.Lock()
+	defer j.Unlock()
+
+	err := j.Common.DeleteAPIToken(id)
+	if err != nil {
+		return err
+	}
+
+	return j.saveAPITokens()
+}
+
+func (j *JsonConfig) UpdateAPITokenLastUsed(id string, at int64) error {
+	j.Lock()
+	defer j.Unlock()
+
+	err := j.Common.UpdateAPITokenLastUsed(id, at)
+	if err != nil {
+		return err
+	}
+
+	return j.saveAPITokens()
+}
+
+// saveAPITokens logs all tokens in one wal entry, it should be called with lock held.
+func (j *JsonConfig) saveAPITokens() error {
+	return j.save(&walEntry{Op: walOpAPITokens, APITokens: j.Common.GetAPITokens()})
+}
+
+func (j *JsonConfig) GetShares() []*Share {
+	j.RLock()
+	defer j.RUnlock()
+
+	return j.Common.GetShares()
+}
+
+func (j *JsonConfig) GetShareByHash(hash string) *Share {
+	j.RLock()
+	defer j.RUnlock()
+
+	return j.Common.GetShareByHash(hash)
+}
+
+func (j *JsonConfig) AddShare(share *Share) error {
+	j.Lock()
+	defer j.Unlock()
+
+	j.Common.AddShare(share)
+
+	return j.save(&walEntry{Op: walOpShares, Shares: j.Common.GetShares()})
+}
+
+func (j *JsonConfig) DeleteShare(id string) error {
+	j.Lock()
+	defer j.Unlock()
+
+	err := j.Common.DeleteShare(id)
+	if err != nil {
+		return err
+	}
+
+	return j.save(&walEntry{Op: walOpShares, Shares: j.Common.GetShares()})
+}
+
+func (j *JsonConfig) AddSyncHistory(history *SyncHistory) error {
+	j.Lock()
+	defer j.Unlock()
+
+	j.SyncHistory = AppendSyncHistory(j.SyncHistory, history)
+
+	return j.save(&walEntry{Op: walOpHistory, SyncHistory: history})
+}
+
+func (j *JsonConfig) GetSyncHistory(user string, limit int) []*SyncHistory {
+	j.RLock()
+	defer j.RUnlock()
+
+	return FilterSyncHistory(j.SyncHistory, user, limit)
+}
+
+func (j *JsonConfig) GetSyncHistoryByID(id string) *SyncHistory {
+	j.RLock()
+	defer j.RUnlock()
+
+	for _, h := range j.SyncHistory {
+		if h.ID == id {
+			return h
+		}
+	}
+
+	return nil
 }
 
 func (j *JsonConfig) LoadRepositories(repos *Repositories) error {
 	j.Lock()
 	defer j.Unlock()
 
+	repos.RebuildIndexes()
 	j.Repositories = repos
 
-	return j.Write()
+	return j.save(&walEntry{Op: walOpLoad, Repositories: repos})
 }
 
 func (j *JsonConfig) AddRepository(path []string, repo *Repository) error {
-	j.Repositories.Add(path, repo)
-
 	j.Lock()
 	defer j.Unlock()
 
-	return j.Write()
+	// a previous name of another repository can be reused, so only current name and id conflict
+	if _, _, r := j.Repositories.GetRepositoryByName(repo.Name); r != nil && r.Name == repo.Name {
+		return ErrRepositoryExists
+	}
+
+	if repo.ID != 0 {
+		if _, _, r := j.Repositories.GetRepositoryByID(repo.ID); r != nil {
+			return ErrRepositoryExists
+		}
+	}
+
+	j.Repositories.Add(path, repo)
+
+	return j.save(&walEntry{Op: walOpAdd, Path: path, Repository: repo})
 }
 
 func (j *JsonConfig) GetRepositories(path []string) *Repositories {
@@ -123,6 +387,14 @@ func (j *JsonConfig) GetAllRepositoryByTag(tag string) []*Repository {
 	return j.Repositories.GetAllRepositoryByTag(tag)
 }
 
+func (j *JsonConfig) QueryRepositories(q *RepositoryQuery) (*RepositoryQueryResult, error) {
+	return j.Repositories.Query(q)
+}
+
+func (j *JsonConfig) Search(query string, filter *RepositoryQuery, limit int) []*SearchResult {
+	return j.Repositories.Search(query, filter, limit)
+}
+
 func (j *JsonConfig) GetAllTag() []string {
 	return j.Repositories.GetAllTag()
 }
@@ -131,22 +403,207 @@ func (j *JsonConfig) GetAllRepositoryByName(name string) ([]string, int, *Reposi
 	return j.Repositories.GetRepositoryByName(name)
 }
 
+func (j *JsonConfig) GetRepositoryByID(id int64) ([]string, int, *Repository) {
+	return j.Repositories.GetRepositoryByID(id)
+}
+
 func (j *JsonConfig) UpdateRepository(repo *Repository) error {
+	j.Lock()
+	defer j.Unlock()
+
 	err := j.Repositories.Update(repo)
 	if err != nil {
 		return err
 	}
 
+	return j.save(&walEntry{Op: walOpUpdate, Repository: repo})
+}
+
+func (j *JsonConfig) MoveRepository(name string, path []string) error {
+	j.Lock()
+	defer j.Unlock()
+
+	err := j.Repositories.Move(name, path)
+	if err != nil {
+		return err
+	}
+
+	return j.save(&walEntry{Op: walOpMove, Name: name, Path: path})
+}
+
+func (j *JsonConfig) UpdateAndMoveRepository(repo *Repository, path []string) error {
+	j.Lock()
+	defer j.Unlock()
+
+	err := j.Repositories.UpdateAndMove(repo, path)
+	if err != nil {
+		return err
+	}
+
+	return j.save(&walEntry{Op: walOpUpdateMove, Repository: repo, Path: path})
+}
+
+func (j *JsonConfig) ApplySync(items []*SyncItem) ([]string, error) {
+	j.Lock()
+	defer j.Unlock()
+
+	skipped := j.Repositories.ApplySync(items)
+
+	return skipped, j.save(&walEntry{Op: walOpSync, SyncItems: items})
+}
+
+func (j *JsonConfig) GetFolderTree() *Folder {
+	return j.Repositories.GetFolderTree()
+}
+
+func (j *JsonConfig) CreateFolder(path []string) error {
+	j.Lock()
+	defer j.Unlock()
+
+	err := j.Repositories.CreateFolder(path)
+	if err != nil {
+		return err
+	}
+
+	return j.save(&walEntry{Op: walOpCreateFolder, Path: path})
+}
+
+func (j *JsonConfig) RenameFolder(path []string, name string) error {
+	j.Lock()
+	defer j.Unlock()
+
+	err := j.Repositories.RenameFolder(path, name)
+	if err != nil {
+		return err
+	}
+	j.Common.MoveSharedFolder(path, path[:len(path)-1], name)
+
+	return j.save(&walEntry{Op: walOpRenameFolder, Path: path, Name: name})
+}
+
+func (j *JsonConfig) MoveFolder(path []string, parent []string) error {
+	j.Lock()
+	defer j.Unlock()
+
+	err := j.Repositories.MoveFolder(path, parent, "")
+	if err != nil {
+		return err
+	}
+	j.Common.MoveSharedFolder(path, parent, "")
+
+	return j.save(&walEntry{Op: walOpMoveFolder, Path: path, Parent: parent})
+}
+
+func (j *JsonConfig) DeleteFolder(path []string, cascade bool) error {
+	j.Lock()
+	defer j.Unlock()
+
+	err := j.Repositories.DeleteFolder(path, cascade)
+	if err != nil {
+		return err
+	}
+	j.Common.DeleteSharedFolder(path)
+
+	return j.save(&walEntry{Op: walOpDeleteFolder, Path: path, Cascade: cascade})
+}
+
+func (j *JsonConfig) GetTagCounts() []*TagCount {
+	return j.Repositories.GetTagCounts()
+}
+
+func (j *JsonConfig) GetAccountCounts() []*AccountCount {
+	return j.Repositories.GetAccountCounts()
+}
+
+func (j *JsonConfig) RenameTag(tag string, name string) (int, error) {
+	j.Lock()
+	defer j.Unlock()
+
+	if !j.Repositories.HasTag(tag) {
+		return 0, ErrTagNotFound
+	}
+
+	changed := j.Repositories.RenameTag(tag, name)
+
+	return len(changed), j.save(&walEntry{Op: walOpMergeTags, Tags: []string{tag}, Name: name})
+}
+
+func (j *JsonConfig) MergeTags(tags []string, target string) (int, error) {
+	j.Lock()
+	defer j.Unlock()
+
+	changed := j.Repositories.MergeTags(tags, target)
+	if len(changed) == 0 {
+		return 0, nil
+	}
+
+	return len(changed), j.save(&walEntry{Op: walOpMergeTags, Tags: tags, Name: target})
+}
+
+func (j *JsonConfig) DeleteTag(tag string) (int, error) {
+	j.Lock()
+	defer j.Unlock()
+
+	if !j.Repositories.HasTag(tag) {
+		return 0, ErrTagNotFound
+	}
+
+	changed := j.Repositories.DeleteTag(tag)
+
+	return len(changed), j.save(&walEntry{Op: walOpDeleteTag, Name: tag})
+}
+
+func (j *JsonConfig) UpdateRepositoriesTags(names []string, add []string, remove []string) (int, error) {
 	j.Lock()
 	defer j.Unlock()
 
-	return j.Write()
+	changed, err := j.Repositories.UpdateTags(names, add, remove)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(changed) == 0 {
+		return 0, nil
+	}
+
+	return len(changed), j.save(&walEntry{Op: walOpUpdateTags, Names: names, Tags: add, RemoveTags: remove})
+}
+
+func (j *JsonConfig) CheckIntegrity(repair bool) ([]string, []string, error) {
+	j.Lock()
+	defer j.Unlock()
+
+	problems, remaining := j.Repositories.CheckIndexes(repair)
+
+	return problems, remaining, nil
 }
 
 func (j *JsonConfig) DeleteRepository(name string) error {
+	j.Lock()
+	defer j.Unlock()
+
 	j.Repositories.Delete(name)
 
+	return j.save(&walEntry{Op: walOpDelete, Name: name})
+}
+
+// DeleteRepositories deletes repositories with names in one write and returns the number of
+// deleted ones, missing names are ignored.
+func (j *JsonConfig) DeleteRepositories(names []string) (int, error) {
 	j.Lock()
 	defer j.Unlock()
-	return j.Write()
+
+	count := 0
+	for _, name := range names {
+		if _, _, r := j.Repositories.GetRepositoryByName(name); r != nil && r.Name == name {
+			j.Repositories.Delete(name)
+			count++
+		}
+	}
+
+	if count == 0 {
+		return 0, nil
+	}
+
+	return count, j.save(&walEntry{Op: walOpDeleteRepositories, Names: names})
 }
@@ -0,0 +1,194 @@
+package jsondb
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJsonConfigWalReplay(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "db.json")
+
+	crashed, err := InitJsondb(path, Options{WriteDelay: time.Hour, WAL: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = crashed.AddRepository([]string{"linux"}, &Repository{Name: "linux01", Tags: []string{"linux"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = crashed.UpdateAndMoveRepository(&Repository{Name: "linux01", Note: "moved"}, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = crashed.UpdateGithubToken("ghp_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate crash before delayed write happens
+	crashed.wal.Close()
+
+	j, err := InitJsondb(path, Options{WriteDelay: time.Hour, WAL: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	if path, _, repo := j.GetAllRepositoryByName("linux01"); repo == nil || repo.Note != "moved" || len(path) != 0 {
+		t.Errorf("repository replayed from wal is %+v at %v", repo, path)
+	}
+
+	if token := j.GetGithubToken(); token != "ghp_token" {
+		t.Errorf("token = %q, expected ghp_token", token)
+	}
+
+	info, err := os.Stat(walPath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Size() != 0 {
+		t.Errorf("wal is not truncated after replay, size %d", info.Size())
+	}
+}
+
+func TestJsonConfigWriteDelay(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "db.json")
+
+	j, err := InitJsondb(path, Options{WriteDelay: time.Hour, WAL: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"repo01", "repo02", "repo03"} {
+		err = j.AddRepository([]string{}, &Repository{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(before) != string(after) {
+		t.Error("db file is written before write delay")
+	}
+
+	// acknowledged changes survive crash
+	if info, err := os.Stat(walPath(path)); err != nil || info.Size() == 0 {
+		t.Errorf("changes waiting for write delay are not in wal: %v", err)
+	}
+
+	err = j.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = j.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := InitJsondb(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if repos := reopened.GetAllRepositoryByPath([]string{}); len(repos) != 3 {
+		t.Errorf("got %d repositories after flush, expected 3", len(repos))
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 || entries[0].Name() != "db.json" || entries[1].Name() != "db.json.wal" {
+		t.Errorf("temp files are left in db dir: %v", entries)
+	}
+}
+
+func TestJsonConfigWalWithoutDelay(t *testing.T) {
+	_, err := InitJsondb(filepath.Join(t.TempDir(), "db.json"), Options{WAL: true})
+	if !errors.Is(err, ErrWALWithoutDelay) {
+		t.Errorf("wal without write delay returns %v", err)
+	}
+}
+
+func TestJsonConfigWalReplaySkipsWritten(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+
+	j, err := InitJsondb(path, Options{WriteDelay: time.Hour, WAL: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = j.AddRepository([]string{"linux", "ebpf"}, &Repository{Name: "linux01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = j.MoveFolder([]string{"linux", "ebpf"}, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = j.AddSyncHistory(&SyncHistory{ID: "sync", User: "fs714"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logged, err := os.ReadFile(walPath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = j.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate crash after db file is written but before wal is truncated
+	err = os.WriteFile(walPath(path), logged, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := InitJsondb(path, Options{WriteDelay: time.Hour, WAL: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+
+	if h := reopened.GetSyncHistory("", 0); len(h) != 1 {
+		t.Errorf("got %d sync histories after replay, expected 1", len(h))
+	}
+
+	if path, _, repo := reopened.GetAllRepositoryByName("linux01"); repo == nil || !equalPath(path, []string{"ebpf"}) {
+		t.Errorf("repository is %+v at %v after replay", repo, path)
+	}
+
+	err = reopened.AddRepository([]string{}, &Repository{Name: "linux02"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reopened.wal.seq != 4 {
+		t.Errorf("seq of wal is %d after reopen, expected 4", reopened.wal.seq)
+	}
+}
@@ -0,0 +1,121 @@
+package jsondb
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	ScopeRepoRead  = "repo:read"
+	ScopeRepoWrite = "repo:write"
+	ScopeSync      = "sync"
+	ScopeAdmin     = "admin"
+)
+
+var (
+	Scopes = []string{ScopeRepoRead, ScopeRepoWrite, ScopeSync, ScopeAdmin}
+
+	ErrAPITokenNotFound = errors.New("api token not found")
+	ErrInvalidScope     = errors.New("invalid scope")
+)
+
+// APIToken is a personal api token, only hash of the token is kept. Times are unix seconds,
+// ExpiresAt 0 means the token never expires.
+type APIToken struct {
+	ID         string
+	Name       string
+	Hash       string
+	Scopes     []string
+	CreatedAt  int64
+	ExpiresAt  int64
+	LastUsedAt int64
+}
+
+// HasScope reports whether token grants scope, admin grants every scope and repo:write grants
+// repo:read.
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope || s == ScopeAdmin || (s == ScopeRepoWrite && scope == ScopeRepoRead) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t *APIToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != 0 && now.Unix() >= t.ExpiresAt
+}
+
+// ValidateScopes returns ErrInvalidScope if scopes is empty or has unknown scope.
+func ValidateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return errors.WithMessage(ErrInvalidScope, "token should have at least one scope")
+	}
+
+	for _, scope := range scopes {
+		known := false
+		for _, s := range Scopes {
+			if scope == s {
+				known = true
+				break
+			}
+		}
+
+		if !known {
+			return errors.WithMessagef(ErrInvalidScope, "unknown scope %q", scope)
+		}
+	}
+
+	return nil
+}
+
+// GetAPITokens returns copies of tokens in created order.
+func (c *Common) GetAPITokens() []*APIToken {
+	tokens := make([]*APIToken, 0, len(c.APITokens))
+	for _, t := range c.APITokens {
+		token := *t
+		tokens = append(tokens, &token)
+	}
+
+	return tokens
+}
+
+func (c *Common) GetAPITokenByHash(hash string) *APIToken {
+	for _, t := range c.APITokens {
+		if t.Hash == hash {
+			token := *t
+			return &token
+		}
+	}
+
+	return nil
+}
+
+func (c *Common) AddAPIToken(token *APIToken) {
+	t := *token
+	c.APITokens = append(c.APITokens, &t)
+}
+
+func (c *Common) DeleteAPIToken(id string) error {
+	for i, t := range c.APITokens {
+		if t.ID == id {
+			c.APITokens = append(c.APITokens[:i:i], c.APITokens[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrAPITokenNotFound
+}
+
+func (c *Common) UpdateAPITokenLastUsed(id string, at int64) error {
+	for _, t := range c.APITokens {
+		if t.ID == id {
+			t.LastUsedAt = at
+			return nil
+		}
+	}
+
+	return ErrAPITokenNotFound
+}
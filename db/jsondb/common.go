@@ -2,4 +2,38 @@ package jsondb
 
 type Common struct {
 	GithubToken string
+	SyncStates  map[string]*SyncState `json:",omitempty"`
+	APITokens   []*APIToken           `json:",omitempty"`
+	Shares      []*Share              `json:",omitempty"`
+}
+
+// SyncState records progress of syncing stars of one github user, times are unix seconds.
+type SyncState struct {
+	LastSyncAt     int64
+	LastFullSyncAt int64
+	// LastStarredAt is the newest starred time seen, incremental sync stops paging at it
+	LastStarredAt int64
+	// Account is login of synced user recorded in StarredBy, it is empty if the user is synced
+	// before accounts are recorded
+	Account string `json:",omitempty"`
+}
+
+func (c *Common) GetSyncState(user string) *SyncState {
+	state, ok := c.SyncStates[user]
+	if !ok {
+		return nil
+	}
+
+	s := *state
+
+	return &s
+}
+
+func (c *Common) UpdateSyncState(user string, state *SyncState) {
+	if c.SyncStates == nil {
+		c.SyncStates = make(map[string]*SyncState)
+	}
+
+	s := *state
+	c.SyncStates[user] = &s
 }
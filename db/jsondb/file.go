@@ -0,0 +1,62 @@
+package jsondb
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// writeFileAtomic writes data into a temp file in the same directory, syncs it to disk
+// and renames it to path, so path always points to a complete file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return errors.Wrap(err, "create temp file error")
+	}
+	tmpPath := f.Name()
+
+	defer func() {
+		if err != nil {
+			os.Remove(tmpPath)
+		}
+	}()
+
+	_, err = f.Write(data)
+	if err != nil {
+		f.Close()
+		return errors.Wrap(err, "write temp file error")
+	}
+
+	err = f.Sync()
+	if err != nil {
+		f.Close()
+		return errors.Wrap(err, "sync temp file error")
+	}
+
+	err = f.Close()
+	if err != nil {
+		return errors.Wrap(err, "close temp file error")
+	}
+
+	err = os.Chmod(tmpPath, perm)
+	if err != nil {
+		return errors.Wrap(err, "chmod temp file error")
+	}
+
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		return errors.Wrap(err, "rename temp file error")
+	}
+
+	// make the rename itself durable
+	d, derr := os.Open(dir)
+	if derr == nil {
+		d.Sync()
+		d.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,251 @@
+package jsondb
+
+import (
+	"errors"
+	"sort"
+)
+
+var (
+	ErrFolderExists = errors.New("folder already exists")
+	ErrInvalidPath  = errors.New("invalid path")
+)
+
+type Folder struct {
+	Name string
+	Path []string
+	// Count is the number of repositories directly in this folder
+	Count int
+	// TotalCount includes repositories in all sub folders
+	TotalCount int
+	SubFolders []*Folder
+}
+
+func (rs *Repositories) GetFolderTree() *Folder {
+	rs.RLock()
+	defer rs.RUnlock()
+
+	return rs.folderTree("", []string{})
+}
+
+// Find returns the sub folder at path relative to f, nil if it does not exist.
+func (f *Folder) Find(path []string) *Folder {
+	if len(path) == 0 {
+		return f
+	}
+
+	for _, sub := range f.SubFolders {
+		if sub.Name == path[0] {
+			return sub.Find(path[1:])
+		}
+	}
+
+	return nil
+}
+
+func (rs *Repositories) folderTree(name string, path []string) *Folder {
+	f := &Folder{
+		Name:       name,
+		Path:       path,
+		Count:      len(rs.Repositories),
+		TotalCount: len(rs.Repositories),
+		SubFolders: make([]*Folder, 0, len(rs.SubRepositories)),
+	}
+
+	for k, v := range rs.SubRepositories {
+		sub := v.folderTree(k, joinPath(path, k))
+		f.TotalCount += sub.TotalCount
+		f.SubFolders = append(f.SubFolders, sub)
+	}
+
+	sort.Slice(f.SubFolders, func(i, j int) bool {
+		return f.SubFolders[i].Name < f.SubFolders[j].Name
+	})
+
+	return f
+}
+
+// CreateFolder creates an empty folder at path, missing parent folders are created too.
+func (rs *Repositories) CreateFolder(path []string) error {
+	if len(path) == 0 {
+		return ErrInvalidPath
+	}
+
+	rs.Lock()
+	defer rs.Unlock()
+
+	if rs.get(path) != nil {
+		return ErrFolderExists
+	}
+
+	rs.mkdir(path)
+
+	return nil
+}
+
+func (rs *Repositories) mkdir(path []string) *Repositories {
+	current := rs
+	for _, p := range path {
+		sub, ok := current.SubRepositories[p]
+		if !ok {
+			sub = NewRepositories()
+			if current.SubRepositories == nil {
+				current.SubRepositories = make(map[string]*Repositories)
+			}
+			current.SubRepositories[p] = sub
+		}
+		current = sub
+	}
+
+	return current
+}
+
+func (rs *Repositories) RenameFolder(path []string, name string) error {
+	if len(path) == 0 || name == "" {
+		return ErrInvalidPath
+	}
+
+	return rs.MoveFolder(path, path[:len(path)-1], name)
+}
+
+// MoveFolder moves folder at path with all its content into parent folder, the folder is
+// renamed to name if it is not empty.
+func (rs *Repositories) MoveFolder(path []string, parent []string, name string) error {
+	if len(path) == 0 {
+		return ErrInvalidPath
+	}
+
+	if name == "" {
+		name = path[len(path)-1]
+	}
+
+	// a folder could not be moved into itself or its descendant
+	if HasPathPrefix(parent, path) {
+		return ErrInvalidPath
+	}
+
+	rs.Lock()
+	defer rs.Unlock()
+
+	src := rs.get(path)
+	if src == nil {
+		return ErrPathNotFound
+	}
+
+	dst := rs.get(parent)
+	if dst == nil {
+		return ErrPathNotFound
+	}
+
+	if _, ok := dst.SubRepositories[name]; ok {
+		return ErrFolderExists
+	}
+
+	srcParent := rs.get(path[:len(path)-1])
+	delete(srcParent.SubRepositories, path[len(path)-1])
+	if dst.SubRepositories == nil {
+		dst.SubRepositories = make(map[string]*Repositories)
+	}
+	dst.SubRepositories[name] = src
+
+	rs.replaceUnstarredFrom(path, joinPath(parent, name))
+	rs.rebuildIndexes()
+
+	return nil
+}
+
+// DeleteFolder deletes folder at path. All repositories and sub folders are deleted if
+// cascade is true, otherwise they are moved into parent folder and merged with existing ones.
+func (rs *Repositories) DeleteFolder(path []string, cascade bool) error {
+	if len(path) == 0 {
+		return ErrInvalidPath
+	}
+
+	rs.Lock()
+	defer rs.Unlock()
+
+	src := rs.get(path)
+	if src == nil {
+		return ErrPathNotFound
+	}
+
+	parent := rs.get(path[:len(path)-1])
+	delete(parent.SubRepositories, path[len(path)-1])
+
+	if !cascade {
+		mergeRepositories(parent, src)
+		rs.replaceUnstarredFrom(path, path[:len(path)-1])
+	}
+
+	rs.rebuildIndexes()
+
+	return nil
+}
+
+// replaceUnstarredFrom rewrites UnstarredFrom in folder from to folder to, so archived
+// repositories are restored into the folder after it is moved. Repositories are replaced with
+// copies, so repositories already handed out are never modified.
+func (rs *Repositories) replaceUnstarredFrom(from []string, to []string) {
+	for i, r := range rs.Repositories {
+		if r.UnstarredFrom != nil && HasPathPrefix(r.UnstarredFrom, from) {
+			repo := *r
+			repo.UnstarredFrom = ReplacePathPrefix(r.UnstarredFrom, from, to)
+			rs.Repositories[i] = &repo
+		}
+	}
+
+	for _, v := range rs.SubRepositories {
+		v.replaceUnstarredFrom(from, to)
+	}
+}
+
+// HasPathPrefix returns true if path is folder prefix or in it.
+func HasPathPrefix(path []string, prefix []string) bool {
+	return len(path) >= len(prefix) && equalPath(path[:len(prefix)], prefix)
+}
+
+// ReplacePathPrefix returns a copy of path with folder prefix replaced by to, path should have
+// the prefix.
+func ReplacePathPrefix(path []string, prefix []string, to []string) []string {
+	return joinPath(to, path[len(prefix):]...)
+}
+
+func mergeRepositories(dst *Repositories, src *Repositories) {
+	dst.Repositories = append(dst.Repositories, src.Repositories...)
+
+	if dst.SubRepositories == nil {
+		dst.SubRepositories = make(map[string]*Repositories)
+	}
+
+	for k, v := range src.SubRepositories {
+		if exist, ok := dst.SubRepositories[k]; ok {
+			mergeRepositories(exist, v)
+		} else {
+			dst.SubRepositories[k] = v
+		}
+	}
+}
+
+// FolderPaths returns paths of all folders except root, parents are always before children.
+func (rs *Repositories) FolderPaths() [][]string {
+	rs.RLock()
+	defer rs.RUnlock()
+
+	paths := make([][]string, 0)
+	rs.folderPaths([]string{}, &paths)
+
+	return paths
+}
+
+func (rs *Repositories) folderPaths(path []string, paths *[][]string) {
+	keys := make([]string, 0, len(rs.SubRepositories))
+	for k := range rs.SubRepositories {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		p := joinPath(path, k)
+		*paths = append(*paths, p)
+		rs.SubRepositories[k].folderPaths(p, paths)
+	}
+}
@@ -0,0 +1,138 @@
+package jsondb
+
+import (
+	"errors"
+	"testing"
+)
+
+func checkNoIndexDrift(t *testing.T, repos *Repositories) {
+	t.Helper()
+
+	if problems, _ := repos.CheckIndexes(false); len(problems) > 0 {
+		t.Fatalf("indexes drift: %v", problems)
+	}
+}
+
+func TestRepositoriesFolderTree(t *testing.T) {
+	repos := GenerateRepositories()
+
+	tree := repos.GetFolderTree()
+	if tree.TotalCount != 11 || tree.Count != 1 {
+		t.Errorf("root folder count = %d, total = %d, expected 1 and 11", tree.Count, tree.TotalCount)
+	}
+
+	for _, f := range tree.SubFolders {
+		if f.Name == "linux" && (f.Count != 2 || f.TotalCount != 6 || len(f.SubFolders) != 2) {
+			t.Errorf("linux folder = %+v", f)
+		}
+	}
+}
+
+func TestRepositoriesCreateFolder(t *testing.T) {
+	repos := GenerateRepositories()
+
+	err := repos.CreateFolder([]string{"linux", "kernel", "mm"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if repos.Get([]string{"linux", "kernel", "mm"}) == nil {
+		t.Error("folder is not created")
+	}
+
+	err = repos.CreateFolder([]string{"linux", "kernel"})
+	if !errors.Is(err, ErrFolderExists) {
+		t.Errorf("create existing folder returns %v", err)
+	}
+}
+
+func TestRepositoriesRenameFolder(t *testing.T) {
+	repos := GenerateRepositories()
+	repos.Add([]string{"unstarred"}, &Repository{Name: "archived", UnstarredFrom: []string{"linux", "ebpf"}})
+
+	err := repos.RenameFolder([]string{"linux", "ebpf"}, "bpf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNoIndexDrift(t, repos)
+
+	path, _, repo := repos.GetRepositoryByName("linux_ebpf_01")
+	if repo == nil || !equalPath(path, []string{"linux", "bpf"}) {
+		t.Errorf("linux_ebpf_01 is at %v", path)
+	}
+
+	if _, _, repo := repos.GetRepositoryByName("archived"); !equalPath(repo.UnstarredFrom, []string{"linux", "bpf"}) {
+		t.Errorf("archived is unstarred from %v", repo.UnstarredFrom)
+	}
+
+	err = repos.RenameFolder([]string{"linux", "bpf"}, "proxy")
+	if !errors.Is(err, ErrFolderExists) {
+		t.Errorf("rename to existing folder returns %v", err)
+	}
+}
+
+func TestRepositoriesMoveFolder(t *testing.T) {
+	repos := GenerateRepositories()
+	repos.Add([]string{"unstarred"}, &Repository{Name: "archived", UnstarredFrom: []string{"linux", "proxy", "sub"}})
+
+	err := repos.MoveFolder([]string{"linux", "proxy"}, []string{"tool"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNoIndexDrift(t, repos)
+
+	if path, _, _ := repos.GetRepositoryByName("linux_proxy_02"); !equalPath(path, []string{"tool", "proxy"}) {
+		t.Errorf("linux_proxy_02 is at %v", path)
+	}
+
+	if _, _, repo := repos.GetRepositoryByName("archived"); !equalPath(repo.UnstarredFrom, []string{"tool", "proxy", "sub"}) {
+		t.Errorf("archived is unstarred from %v", repo.UnstarredFrom)
+	}
+
+	err = repos.MoveFolder([]string{"linux"}, []string{"linux", "ebpf"}, "")
+	if !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("move folder into its descendant returns %v", err)
+	}
+
+	err = repos.MoveFolder([]string{"missing"}, []string{}, "")
+	if !errors.Is(err, ErrPathNotFound) {
+		t.Errorf("move missing folder returns %v", err)
+	}
+}
+
+func TestRepositoriesDeleteFolder(t *testing.T) {
+	repos := GenerateRepositories()
+
+	err := repos.CreateFolder([]string{"ai", "linux", "extra"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = repos.DeleteFolder([]string{"linux"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNoIndexDrift(t, repos)
+
+	if path, _, _ := repos.GetRepositoryByName("linux01"); len(path) != 0 {
+		t.Errorf("linux01 is at %v after re-parent", path)
+	}
+
+	if path, _, _ := repos.GetRepositoryByName("linux_ebpf_01"); !equalPath(path, []string{"ebpf"}) {
+		t.Errorf("linux_ebpf_01 is at %v after re-parent", path)
+	}
+
+	err = repos.DeleteFolder([]string{"ai"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNoIndexDrift(t, repos)
+
+	if _, _, repo := repos.GetRepositoryByName("ai_picture_01"); repo != nil {
+		t.Error("repository is not deleted with cascade")
+	}
+
+	if len(repos.GetAllRepositoryByTag("ai")) != 0 {
+		t.Error("tag map still contains deleted repositories")
+	}
+}
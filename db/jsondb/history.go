@@ -0,0 +1,97 @@
+package jsondb
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var ErrSyncHistoryNotFound = errors.New("sync history not found")
+
+// only the newest sync histories are kept
+const MaxSyncHistory = 200
+
+type SyncRename struct {
+	From string
+	To   string
+}
+
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+type SyncChange struct {
+	Name   string
+	Fields []FieldChange
+}
+
+// SyncDiff is what a sync changes in db. Removed is only found by full sync, it contains
+// repositories unstarred since the last sync whether they are dropped or kept, and Restarred
+// contains kept ones which are starred again.
+type SyncDiff struct {
+	Added     []string
+	Removed   []string
+	Restarred []string
+	Renamed   []SyncRename
+	Changed   []SyncChange
+}
+
+func NewSyncDiff() *SyncDiff {
+	return &SyncDiff{
+		Added:     make([]string, 0),
+		Removed:   make([]string, 0),
+		Restarred: make([]string, 0),
+		Renamed:   make([]SyncRename, 0),
+		Changed:   make([]SyncChange, 0),
+	}
+}
+
+type SyncHistory struct {
+	ID         string
+	User       string
+	Full       bool
+	StartedAt  int64
+	FinishedAt int64
+	Fetched    int
+	Diff       *SyncDiff
+}
+
+// AppendSyncHistory returns histories with h added, oldest ones exceeding MaxSyncHistory are dropped.
+func AppendSyncHistory(histories []*SyncHistory, h *SyncHistory) []*SyncHistory {
+	for _, e := range histories {
+		if e.ID == h.ID {
+			return histories
+		}
+	}
+
+	histories = append(histories, h)
+	if len(histories) > MaxSyncHistory {
+		histories = histories[len(histories)-MaxSyncHistory:]
+	}
+
+	return histories
+}
+
+// FilterSyncHistory returns histories of user newest first, histories of all users are returned if
+// user is empty. limit <= 0 means no limit.
+func FilterSyncHistory(histories []*SyncHistory, user string, limit int) []*SyncHistory {
+	result := make([]*SyncHistory, 0)
+	for _, h := range histories {
+		if user == "" || strings.EqualFold(h.User, user) {
+			result = append(result, h)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].StartedAt > result[j].StartedAt
+	})
+
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+
+	return result
+}
@@ -0,0 +1,216 @@
+package jsondb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RebuildIndexes drops and rebuilds all in-memory indexes from the repositories tree, it should
+// be called whenever the tree is replaced or loaded from disk.
+func (rs *Repositories) RebuildIndexes() {
+	rs.Lock()
+	defer rs.Unlock()
+
+	rs.rebuildIndexes()
+}
+
+func (rs *Repositories) rebuildIndexes() {
+	rs.NameIndexes = make(map[string]*RepositoryNameIndex)
+	rs.TagMap = make(map[string][]*Repository)
+	rs.IDIndexes = make(map[int64]string)
+	rs.AliasIndexes = make(map[string]string)
+
+	rs.updateNameIndexes([]string{}, rs.NameIndexes)
+	rs.updateTagMap(rs.TagMap)
+	rs.updateIDIndexes(rs.IDIndexes, rs.AliasIndexes)
+
+	rs.SearchIndex = NewSearchIndex()
+	rs.updateSearchIndex(rs.SearchIndex)
+}
+
+// CheckIndexes compares in-memory indexes with the repositories tree and returns all found
+// problems, and problems remaining after repair. Indexes are rebuilt if repair is true, which
+// fixes their drift but not repositories duplicated in the tree, those should be fixed by user.
+// Remaining problems are all problems if repair is false.
+func (rs *Repositories) CheckIndexes(repair bool) ([]string, []string) {
+	rs.Lock()
+	defer rs.Unlock()
+
+	problems := rs.checkIndexes()
+	if !repair || len(problems) == 0 {
+		return problems, problems
+	}
+
+	rs.rebuildIndexes()
+
+	return problems, rs.checkIndexes()
+}
+
+func (rs *Repositories) checkIndexes() []string {
+	problems := make([]string, 0)
+
+	locations := make(map[string][]string)
+	rs.walk([]string{}, func(path []string, idx int, r *Repository) {
+		locations[r.Name] = append(locations[r.Name], fmt.Sprintf("%s[%d]", strings.Join(path, "/"), idx))
+	})
+
+	for name, locs := range locations {
+		if len(locs) > 1 {
+			sort.Strings(locs)
+			problems = append(problems, fmt.Sprintf("repository %s is duplicated at %s", name, strings.Join(locs, ", ")))
+		}
+	}
+
+	nameIndexes := make(map[string]*RepositoryNameIndex)
+	rs.updateNameIndexes([]string{}, nameIndexes)
+
+	for name, expected := range nameIndexes {
+		// index of duplicated repository points to any of them
+		if len(locations[name]) > 1 {
+			continue
+		}
+
+		actual, ok := rs.NameIndexes[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("name index of %s is missing", name))
+		} else if actual.Index != expected.Index || !equalPath(actual.Path, expected.Path) {
+			problems = append(problems, fmt.Sprintf("name index of %s points to %s[%d], expected %s[%d]", name,
+				strings.Join(actual.Path, "/"), actual.Index, strings.Join(expected.Path, "/"), expected.Index))
+		}
+	}
+
+	for name := range rs.NameIndexes {
+		if _, ok := nameIndexes[name]; !ok {
+			problems = append(problems, fmt.Sprintf("name index of %s points to deleted repository", name))
+		}
+	}
+
+	tagMap := make(map[string][]*Repository)
+	rs.updateTagMap(tagMap)
+
+	for tag, expected := range tagMap {
+		if !equalRepositorySet(rs.TagMap[tag], expected) {
+			problems = append(problems, fmt.Sprintf("tag map of %s is inconsistent", tag))
+		}
+	}
+
+	for tag, repos := range rs.TagMap {
+		if _, ok := tagMap[tag]; !ok && len(repos) > 0 {
+			problems = append(problems, fmt.Sprintf("tag map of %s contains repositories without the tag", tag))
+		}
+	}
+
+	ids := make(map[int64][]string)
+	rs.walk([]string{}, func(path []string, idx int, r *Repository) {
+		if r.ID != 0 {
+			ids[r.ID] = append(ids[r.ID], r.Name)
+		}
+	})
+
+	for id, names := range ids {
+		if len(names) > 1 {
+			sort.Strings(names)
+			problems = append(problems, fmt.Sprintf("repository id %d is shared by %s", id, strings.Join(names, ", ")))
+		}
+	}
+
+	idIndexes := make(map[int64]string)
+	aliasIndexes := make(map[string]string)
+	rs.updateIDIndexes(idIndexes, aliasIndexes)
+
+	for id, name := range idIndexes {
+		if len(ids[id]) > 1 {
+			continue
+		}
+
+		if rs.IDIndexes[id] != name {
+			problems = append(problems, fmt.Sprintf("id index of %d points to %q, expected %s", id, rs.IDIndexes[id], name))
+		}
+	}
+
+	for id, name := range rs.IDIndexes {
+		if _, ok := idIndexes[id]; !ok {
+			problems = append(problems, fmt.Sprintf("id index of %d points to deleted repository %s", id, name))
+		}
+	}
+
+	for alias, name := range aliasIndexes {
+		if rs.AliasIndexes[alias] != name {
+			problems = append(problems, fmt.Sprintf("alias index of %s points to %q, expected %s", alias,
+				rs.AliasIndexes[alias], name))
+		}
+	}
+
+	for alias, name := range rs.AliasIndexes {
+		if _, ok := aliasIndexes[alias]; !ok {
+			problems = append(problems, fmt.Sprintf("alias index of %s points to deleted repository %s", alias, name))
+		}
+	}
+
+	if rs.SearchIndex == nil {
+		if len(locations) > 0 {
+			problems = append(problems, "search index is missing")
+		}
+	} else {
+		for name := range locations {
+			if _, ok := rs.SearchIndex.docs[name]; !ok {
+				problems = append(problems, fmt.Sprintf("search index of %s is missing", name))
+			}
+		}
+
+		for name := range rs.SearchIndex.docs {
+			if _, ok := locations[name]; !ok {
+				problems = append(problems, fmt.Sprintf("search index of %s points to deleted repository", name))
+			}
+		}
+	}
+
+	sort.Strings(problems)
+
+	return problems
+}
+
+func (rs *Repositories) walk(path []string, fn func(path []string, idx int, r *Repository)) {
+	for idx, r := range rs.Repositories {
+		fn(path, idx, r)
+	}
+
+	for k, v := range rs.SubRepositories {
+		v.walk(joinPath(path, k), fn)
+	}
+}
+
+func equalPath(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func equalRepositorySet(a []*Repository, b []*Repository) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	set := make(map[*Repository]int, len(a))
+	for _, r := range a {
+		set[r]++
+	}
+
+	for _, r := range b {
+		if set[r] == 0 {
+			return false
+		}
+		set[r]--
+	}
+
+	return true
+}
@@ -0,0 +1,135 @@
+package jsondb
+
+import (
+	"testing"
+)
+
+func TestRepositoriesIndexesAfterDelete(t *testing.T) {
+	repos := GenerateRepositories()
+
+	repos.Delete("linux01")
+
+	if problems, _ := repos.CheckIndexes(false); len(problems) > 0 {
+		t.Fatalf("indexes drift after delete: %v", problems)
+	}
+
+	path, idx, repo := repos.GetRepositoryByName("linux02")
+	if repo == nil || repo.Name != "linux02" || len(path) != 1 || idx != 0 {
+		t.Errorf("linux02 is at %v[%d]: %v", path, idx, repo)
+	}
+
+	if _, _, repo = repos.GetRepositoryByName("linux01"); repo != nil {
+		t.Errorf("deleted repository is still found: %v", repo)
+	}
+}
+
+func TestRepositoriesIndexesAfterUpdate(t *testing.T) {
+	repos := GenerateRepositories()
+
+	_, _, repo := repos.GetRepositoryByName("linux_proxy_01")
+	newRepo := *repo
+	newRepo.Tags = []string{"network"}
+
+	err := repos.Update(&newRepo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if problems, _ := repos.CheckIndexes(false); len(problems) > 0 {
+		t.Fatalf("indexes drift after update: %v", problems)
+	}
+
+	for _, r := range repos.GetAllRepositoryByTag("proxy") {
+		if r.Name == "linux_proxy_01" {
+			t.Error("removed tag still maps to repository")
+		}
+	}
+
+	if len(repos.GetAllRepositoryByTag("network")) != 1 {
+		t.Error("added tag does not map to repository")
+	}
+}
+
+func TestRepositoriesCheckIndexes(t *testing.T) {
+	repos := GenerateRepositories()
+
+	repos.NameIndexes["linux01"].Index = 5
+	delete(repos.NameIndexes, "ai_01")
+	repos.TagMap["ghost"] = []*Repository{{Name: "ghost"}}
+
+	problems, remaining := repos.CheckIndexes(true)
+	if len(problems) != 3 || len(remaining) != 0 {
+		t.Errorf("got problems %v, remaining %v, expected 3 repaired", problems, remaining)
+	}
+
+	if problems, _ = repos.CheckIndexes(false); len(problems) > 0 {
+		t.Errorf("indexes drift after repair: %v", problems)
+	}
+
+	// duplicated repositories are not repaired by rebuilding indexes
+	repos.Get([]string{"ai"}).Repositories = append(repos.Get([]string{"ai"}).Repositories, &Repository{Name: "linux01"})
+	delete(repos.NameIndexes, "ai_01")
+
+	problems, remaining = repos.CheckIndexes(true)
+	if len(problems) != 2 || len(remaining) != 1 || remaining[0] != "repository linux01 is duplicated at ai[1], linux[0]" {
+		t.Errorf("got problems %v, remaining %v, expected duplicated linux01 to remain", problems, remaining)
+	}
+}
+
+func TestRepositoriesRebuildIndexes(t *testing.T) {
+	repos := GenerateRepositories()
+
+	tree := &Repositories{
+		Repositories:    repos.Repositories,
+		SubRepositories: repos.SubRepositories,
+	}
+
+	tree.RebuildIndexes()
+
+	if problems, _ := tree.CheckIndexes(false); len(problems) > 0 {
+		t.Errorf("indexes drift after rebuild: %v", problems)
+	}
+
+	if len(tree.GetAllRepositoryByTag("linux")) != 6 {
+		t.Errorf("got %d repositories with tag linux, expected 6", len(tree.GetAllRepositoryByTag("linux")))
+	}
+}
+
+func TestRepositoriesIDAndAliasIndexes(t *testing.T) {
+	repos := NewRepositories()
+	repos.Add([]string{"go"}, &Repository{ID: 1, Name: "new/gsm", PreviousNames: []string{"old/gsm"}})
+	repos.Add([]string{}, &Repository{ID: 2, Name: "fs714/other"})
+
+	path, _, repo := repos.GetRepositoryByID(1)
+	if repo == nil || repo.Name != "new/gsm" || !equalPath(path, []string{"go"}) {
+		t.Errorf("repository 1 is %v at %v", repo, path)
+	}
+
+	if _, _, repo = repos.GetRepositoryByName("old/gsm"); repo == nil || repo.Name != "new/gsm" {
+		t.Errorf("previous name resolves to %v", repo)
+	}
+
+	// current name of a repository wins over previous name of another one
+	repos.Add([]string{}, &Repository{ID: 3, Name: "old/gsm"})
+	if _, _, repo = repos.GetRepositoryByName("old/gsm"); repo == nil || repo.ID != 3 {
+		t.Errorf("current name resolves to %v", repo)
+	}
+
+	repos.Delete("new/gsm")
+	if _, _, repo = repos.GetRepositoryByID(1); repo != nil {
+		t.Errorf("deleted repository is still found by id: %v", repo)
+	}
+
+	if problems, _ := repos.CheckIndexes(false); len(problems) > 0 {
+		t.Errorf("indexes drift: %v", problems)
+	}
+
+	repos.AliasIndexes["gone/repo"] = "fs714/other"
+	if problems, _ := repos.CheckIndexes(true); len(problems) != 1 {
+		t.Errorf("got problems %v, expected 1 stale alias", problems)
+	}
+
+	if problems, _ := repos.CheckIndexes(false); len(problems) > 0 {
+		t.Errorf("indexes drift after repair: %v", problems)
+	}
+}
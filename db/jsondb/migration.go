@@ -0,0 +1,151 @@
+package jsondb
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fs714/github-star-manager/pkg/utils/log"
+	"github.com/pkg/errors"
+)
+
+// CurrentSchemaVersion should be increased together with a new migration appended to
+// migrations whenever the stored document changes incompatibly.
+const CurrentSchemaVersion = 1
+
+type Migration struct {
+	Version     int
+	Description string
+	Migrate     func(doc map[string]interface{}) error
+}
+
+// migrations are ordered by version, migration with Version n upgrades document from n-1 to n.
+var migrations = []*Migration{
+	{
+		Version:     1,
+		Description: "add SchemaVersion and drop runtime fields Path and RWMutex",
+		Migrate: func(doc map[string]interface{}) error {
+			delete(doc, "Path")
+			delete(doc, "RWMutex")
+
+			return nil
+		},
+	},
+}
+
+func PendingMigrations(version int) []*Migration {
+	pending := make([]*Migration, 0)
+	for _, m := range migrations {
+		if m.Version > version {
+			pending = append(pending, m)
+		}
+	}
+
+	return pending
+}
+
+func schemaVersion(doc map[string]interface{}) (int, error) {
+	v, ok := doc["SchemaVersion"]
+	if !ok {
+		return 0, nil
+	}
+
+	version, ok := v.(float64)
+	if !ok {
+		return 0, errors.Errorf("invalid schema version: %v", v)
+	}
+
+	return int(version), nil
+}
+
+func backupPath(path string, version int) string {
+	return fmt.Sprintf("%s.v%d.%s.bak", path, version, time.Now().Format("20060102150405"))
+}
+
+// migrate upgrades data of db file at path to current schema version, the document is backed up
+// before every migration. Nothing is written when dryRun is true.
+func migrate(path string, data []byte, dryRun bool) ([]byte, []*Migration, error) {
+	doc := make(map[string]interface{})
+	err := json.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unmarshal error")
+	}
+
+	version, err := schemaVersion(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if version > CurrentSchemaVersion {
+		return nil, nil, errors.Errorf("schema version %d of %s is newer than supported version %d",
+			version, path, CurrentSchemaVersion)
+	}
+
+	pending := PendingMigrations(version)
+	if dryRun || len(pending) == 0 {
+		return data, pending, nil
+	}
+
+	for _, m := range pending {
+		backup := backupPath(path, version)
+		err = writeFileAtomic(backup, data, 0644)
+		if err != nil {
+			return nil, nil, errors.WithMessagef(err, "failed to backup db before migrating to version %d", m.Version)
+		}
+
+		err = m.Migrate(doc)
+		if err != nil {
+			return nil, nil, errors.WithMessagef(err, "failed to migrate db to version %d", m.Version)
+		}
+
+		doc["SchemaVersion"] = m.Version
+		version = m.Version
+
+		data, err = json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "marshal error")
+		}
+
+		log.Infow("migrated db", "path", path, "version", m.Version, "description", m.Description, "backup", backup)
+	}
+
+	return data, pending, nil
+}
+
+// MigrateJsondb upgrades db file at path to current schema version and returns the applied
+// migrations, or the migrations which would be applied if dryRun is true.
+func MigrateJsondb(path string, dryRun bool) (int, []*Migration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "read file error")
+	}
+
+	doc := make(map[string]interface{})
+	err = json.Unmarshal(data, &doc)
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "unmarshal error")
+	}
+
+	version, err := schemaVersion(doc)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if dryRun {
+		_, pending, err := migrate(path, data, true)
+		return version, pending, err
+	}
+
+	j, err := InitJsondb(path, Options{})
+	if err != nil {
+		return version, nil, err
+	}
+
+	err = j.Close()
+	if err != nil {
+		return version, nil, err
+	}
+
+	return version, PendingMigrations(version), nil
+}
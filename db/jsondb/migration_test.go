@@ -0,0 +1,110 @@
+package jsondb
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const schemaV0Document = `{
+  "Path": "/old/path/db.json",
+  "Common": {"GithubToken": "ghp_token"},
+  "Repositories": {
+    "Repositories": [{"Name": "linux01", "Tags": ["linux"]}],
+    "SubRepositories": {}
+  },
+  "RWMutex": {}
+}`
+
+func TestMigrateJsondbDryRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	err := os.WriteFile(path, []byte(schemaV0Document), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version, steps, err := MigrateJsondb(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if version != 0 || len(steps) != CurrentSchemaVersion {
+		t.Errorf("version = %d, steps = %d, expected 0 and %d", version, len(steps), CurrentSchemaVersion)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != schemaV0Document {
+		t.Error("db file is changed by dry run")
+	}
+}
+
+func TestInitJsondbMigrate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "db.json")
+	err := os.WriteFile(path, []byte(schemaV0Document), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := InitJsondb(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if j.SchemaVersion != CurrentSchemaVersion {
+		t.Errorf("schema version = %d, expected %d", j.SchemaVersion, CurrentSchemaVersion)
+	}
+
+	if j.Path != path {
+		t.Errorf("path = %s, expected %s", j.Path, path)
+	}
+
+	if token := j.GetGithubToken(); token != "ghp_token" {
+		t.Errorf("token = %q, expected ghp_token", token)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(data), "RWMutex") || strings.Contains(string(data), "/old/path") {
+		t.Errorf("runtime fields are still persisted:\n%s", string(data))
+	}
+
+	backups, err := filepath.Glob(filepath.Join(dir, "db.json.v0.*.bak"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(backups) != 1 {
+		t.Fatalf("got %d backups, expected 1", len(backups))
+	}
+
+	backup, err := os.ReadFile(backups[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(backup) != schemaV0Document {
+		t.Error("backup is different from original db file")
+	}
+}
+
+func TestInitJsondbNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	err := os.WriteFile(path, []byte(`{"SchemaVersion": 9999}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = InitJsondb(path, Options{})
+	if err == nil {
+		t.Error("expected error when schema version is newer than supported")
+	}
+}
@@ -0,0 +1,362 @@
+package jsondb
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+)
+
+const (
+	SortByName    = "name"
+	SortByStars   = "stars"
+	SortByPushed  = "pushed"
+	SortByCreated = "created"
+	SortByStarred = "starred"
+
+	TagModeAny = "any"
+	TagModeAll = "all"
+
+	UnstarredInclude = "include"
+	UnstarredExclude = "exclude"
+	UnstarredOnly    = "only"
+)
+
+var ErrInvalidQuery = errors.New("invalid query")
+
+type RepositoryQuery struct {
+	// Path limits repositories to folder, repositories in sub folders are included if
+	// IncludeSubFolders is true. nil means the whole tree.
+	Path              []string
+	IncludeSubFolders bool
+	Languages         []string
+	Tags              []string
+	TagMode           string
+	MinStars          *int
+	MaxStars          *int
+	MinForks          *int
+	MaxForks          *int
+	PushedAfter       int64
+	PushedBefore      int64
+	// Accounts matches repositories starred by any or all of them depending on AccountMode,
+	// which is TagModeAny or TagModeAll
+	Accounts    []string
+	AccountMode string
+	// Topics, Licenses and OwnerTypes match repositories having any of them case-insensitively
+	Topics     []string
+	Licenses   []string
+	OwnerTypes []string
+	// Archived, Fork and Disabled match flags of repositories if they are not nil
+	Archived *bool
+	Fork     *bool
+	Disabled *bool
+	MinSize  *int
+	MaxSize  *int
+	// Text is matched against name and description case-insensitively
+	Text string
+	// Unstarred decides whether repositories kept after unstar are returned, empty means include
+	Unstarred string
+
+	Sort string
+	Desc bool
+	// Cursor returned by previous query takes precedence over Offset
+	Cursor string
+	Offset int
+	// Limit 0 means no limit
+	Limit int
+}
+
+type RepositoryQueryResult struct {
+	Total        int
+	Offset       int
+	NextCursor   string
+	Repositories []*Repository
+}
+
+type sortKey struct {
+	Sort string
+	Num  int64
+	Str  string
+	Name string
+}
+
+func (rs *Repositories) Query(q *RepositoryQuery) (*RepositoryQueryResult, error) {
+	if q.Sort == "" {
+		q.Sort = SortByName
+	}
+
+	switch q.Sort {
+	case SortByName, SortByStars, SortByPushed, SortByCreated, SortByStarred:
+	default:
+		return nil, ErrInvalidQuery
+	}
+
+	switch q.Unstarred {
+	case "", UnstarredInclude, UnstarredExclude, UnstarredOnly:
+	default:
+		return nil, ErrInvalidQuery
+	}
+
+	candidates := rs.queryCandidates(q)
+
+	filtered := make([]*Repository, 0, len(candidates))
+	for _, r := range candidates {
+		if q.match(r) {
+			filtered = append(filtered, r)
+		}
+	}
+
+	sort.SliceStable(filtered, func(i, j int) bool {
+		return compareSortKey(q.sortKey(filtered[i]), q.sortKey(filtered[j]), q.Desc) < 0
+	})
+
+	result := &RepositoryQueryResult{
+		Total: len(filtered),
+	}
+
+	start := q.Offset
+	if q.Cursor != "" {
+		key, err := decodeCursor(q.Cursor)
+		if err != nil || key.Sort != q.Sort {
+			return nil, ErrInvalidQuery
+		}
+
+		start = sort.Search(len(filtered), func(i int) bool {
+			return compareSortKey(q.sortKey(filtered[i]), key, q.Desc) > 0
+		})
+	}
+
+	if start < 0 {
+		start = 0
+	}
+	if start > len(filtered) {
+		start = len(filtered)
+	}
+
+	end := len(filtered)
+	if q.Limit > 0 && start+q.Limit < end {
+		end = start + q.Limit
+		result.NextCursor = encodeCursor(q.sortKey(filtered[end-1]))
+	}
+
+	result.Offset = start
+	result.Repositories = filtered[start:end]
+
+	return result, nil
+}
+
+func (rs *Repositories) queryCandidates(q *RepositoryQuery) []*Repository {
+	var candidates []*Repository
+	if q.Path == nil || q.IncludeSubFolders {
+		candidates = rs.GetAllRepositoryByPath(normalizeQueryPath(q.Path))
+	} else {
+		folder := rs.Get(q.Path)
+		if folder == nil {
+			return []*Repository{}
+		}
+
+		folder.RLock()
+		candidates = append(candidates, folder.Repositories...)
+		folder.RUnlock()
+	}
+
+	if len(q.Tags) == 0 {
+		return candidates
+	}
+
+	// narrow down candidates with tag map before matching other conditions
+	counts := make(map[string]int)
+	for _, tag := range dedupTags(q.Tags) {
+		for _, r := range rs.GetAllRepositoryByTag(tag) {
+			counts[r.Name]++
+		}
+	}
+
+	required := 1
+	if q.TagMode == TagModeAll {
+		required = len(dedupTags(q.Tags))
+	}
+
+	tagged := make([]*Repository, 0)
+	for _, r := range candidates {
+		if counts[r.Name] >= required {
+			tagged = append(tagged, r)
+		}
+	}
+
+	return tagged
+}
+
+func normalizeQueryPath(path []string) []string {
+	if path == nil {
+		return []string{}
+	}
+
+	return path
+}
+
+func (q *RepositoryQuery) match(r *Repository) bool {
+	if (q.Unstarred == UnstarredExclude && r.UnstarredAt != 0) || (q.Unstarred == UnstarredOnly && r.UnstarredAt == 0) {
+		return false
+	}
+
+	if len(q.Languages) > 0 && !containsFold(q.Languages, r.Language) {
+		return false
+	}
+
+	if len(q.Accounts) > 0 {
+		count := 0
+		for _, a := range dedupTags(q.Accounts) {
+			if HasAccount(r.StarredBy, a) {
+				count++
+			}
+		}
+
+		if count == 0 || (q.AccountMode == TagModeAll && count < len(dedupTags(q.Accounts))) {
+			return false
+		}
+	}
+
+	if len(q.Topics) > 0 {
+		found := false
+		for _, t := range r.Topics {
+			if containsFold(q.Topics, t) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if len(q.Licenses) > 0 && !containsFold(q.Licenses, r.License) {
+		return false
+	}
+
+	if len(q.OwnerTypes) > 0 && !containsFold(q.OwnerTypes, r.OwnerType) {
+		return false
+	}
+
+	if (q.Archived != nil && *q.Archived != r.Archived) || (q.Fork != nil && *q.Fork != r.Fork) ||
+		(q.Disabled != nil && *q.Disabled != r.Disabled) {
+		return false
+	}
+
+	if q.MinSize != nil && r.Size < *q.MinSize {
+		return false
+	}
+
+	if q.MaxSize != nil && r.Size > *q.MaxSize {
+		return false
+	}
+
+	if q.MinStars != nil && r.StarsCount < *q.MinStars {
+		return false
+	}
+
+	if q.MaxStars != nil && r.StarsCount > *q.MaxStars {
+		return false
+	}
+
+	if q.MinForks != nil && r.ForksCount < *q.MinForks {
+		return false
+	}
+
+	if q.MaxForks != nil && r.ForksCount > *q.MaxForks {
+		return false
+	}
+
+	if q.PushedAfter > 0 && r.PushedAt < q.PushedAfter {
+		return false
+	}
+
+	if q.PushedBefore > 0 && r.PushedAt > q.PushedBefore {
+		return false
+	}
+
+	if q.Text != "" {
+		text := strings.ToLower(q.Text)
+		if !strings.Contains(strings.ToLower(r.Name), text) &&
+			!strings.Contains(strings.ToLower(r.Description), text) &&
+			!strings.Contains(strings.ToLower(r.DescriptionOverride), text) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (q *RepositoryQuery) sortKey(r *Repository) sortKey {
+	key := sortKey{Sort: q.Sort, Name: r.Name}
+
+	switch q.Sort {
+	case SortByName:
+		key.Str = strings.ToLower(r.Name)
+	case SortByStars:
+		key.Num = int64(r.StarsCount)
+	case SortByPushed:
+		key.Num = r.PushedAt
+	case SortByCreated:
+		key.Num = r.CreatedAt
+	case SortByStarred:
+		key.Num = r.StarredAt
+	}
+
+	return key
+}
+
+// compareSortKey orders by sort value in requested direction, and by name in ascending order
+// for equal values, so every repository has a stable position for cursor.
+func compareSortKey(a sortKey, b sortKey, desc bool) int {
+	c := 0
+	switch {
+	case a.Num < b.Num:
+		c = -1
+	case a.Num > b.Num:
+		c = 1
+	default:
+		c = strings.Compare(a.Str, b.Str)
+	}
+
+	if desc {
+		c = -c
+	}
+
+	if c == 0 {
+		c = strings.Compare(a.Name, b.Name)
+	}
+
+	return c
+}
+
+func encodeCursor(key sortKey) string {
+	data, _ := json.Marshal(key)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursor string) (sortKey, error) {
+	var key sortKey
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return key, err
+	}
+
+	err = json.Unmarshal(data, &key)
+
+	return key, err
+}
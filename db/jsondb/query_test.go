@@ -0,0 +1,168 @@
+package jsondb
+
+import (
+	"errors"
+	"testing"
+)
+
+func generateQueryRepositories() *Repositories {
+	repos := NewRepositories()
+	repos.Add([]string{}, &Repository{Name: "a/tui", Language: "Rust", StarsCount: 500, PushedAt: 300,
+		Description: "A terminal UI library", Tags: []string{"tui", "rust"}})
+	repos.Add([]string{"go"}, &Repository{Name: "b/gin", Language: "Go", StarsCount: 7000, ForksCount: 900,
+		PushedAt: 200, Tags: []string{"web"}})
+	repos.Add([]string{"go", "cli"}, &Repository{Name: "c/cobra", Language: "Go", StarsCount: 3000, PushedAt: 100,
+		Tags: []string{"cli", "tui"}})
+	repos.Add([]string{"go", "cli"}, &Repository{Name: "d/bubbletea", Language: "Go", StarsCount: 3000, PushedAt: 400,
+		Description: "A powerful little TUI framework", Tags: []string{"cli", "tui"}})
+
+	return repos
+}
+
+func queryNames(t *testing.T, repos *Repositories, q *RepositoryQuery) []string {
+	t.Helper()
+
+	result, err := repos.Query(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make([]string, 0, len(result.Repositories))
+	for _, r := range result.Repositories {
+		names = append(names, r.Name)
+	}
+
+	return names
+}
+
+func TestRepositoriesQueryFilter(t *testing.T) {
+	repos := generateQueryRepositories()
+	minStars := 1000
+
+	cases := []struct {
+		query    *RepositoryQuery
+		expected []string
+	}{
+		{&RepositoryQuery{}, []string{"a/tui", "b/gin", "c/cobra", "d/bubbletea"}},
+		{&RepositoryQuery{Languages: []string{"go"}}, []string{"b/gin", "c/cobra", "d/bubbletea"}},
+		{&RepositoryQuery{Tags: []string{"tui", "web"}}, []string{"a/tui", "b/gin", "c/cobra", "d/bubbletea"}},
+		{&RepositoryQuery{Tags: []string{"tui", "cli"}, TagMode: TagModeAll}, []string{"c/cobra", "d/bubbletea"}},
+		{&RepositoryQuery{Path: []string{"go"}}, []string{"b/gin"}},
+		{&RepositoryQuery{Path: []string{"go"}, IncludeSubFolders: true}, []string{"b/gin", "c/cobra", "d/bubbletea"}},
+		{&RepositoryQuery{MinStars: &minStars, Tags: []string{"tui"}}, []string{"c/cobra", "d/bubbletea"}},
+		{&RepositoryQuery{PushedAfter: 150, PushedBefore: 350}, []string{"a/tui", "b/gin"}},
+		{&RepositoryQuery{Text: "tui"}, []string{"a/tui", "d/bubbletea"}},
+	}
+
+	for i, c := range cases {
+		names := queryNames(t, repos, c.query)
+		if !equalPath(names, c.expected) {
+			t.Errorf("case %d: got %v, expected %v", i, names, c.expected)
+		}
+	}
+}
+
+func TestRepositoriesQueryGithubFields(t *testing.T) {
+	repos := NewRepositories()
+	repos.Add([]string{}, &Repository{Name: "a/cli", Topics: []string{"cli", "go"}, License: "MIT", Size: 100,
+		OwnerType: "User"})
+	repos.Add([]string{}, &Repository{Name: "b/old", Topics: []string{"cli"}, License: "Apache-2.0", Archived: true,
+		Size: 2000, OwnerType: "Organization"})
+	repos.Add([]string{}, &Repository{Name: "c/fork", Fork: true, Disabled: true, Size: 50, OwnerType: "User"})
+
+	yes, no := true, false
+	maxSize := 1000
+
+	cases := []struct {
+		query    *RepositoryQuery
+		expected []string
+	}{
+		{&RepositoryQuery{Topics: []string{"CLI"}}, []string{"a/cli", "b/old"}},
+		{&RepositoryQuery{Topics: []string{"go", "rust"}}, []string{"a/cli"}},
+		{&RepositoryQuery{Licenses: []string{"mit", "apache-2.0"}}, []string{"a/cli", "b/old"}},
+		{&RepositoryQuery{OwnerTypes: []string{"organization"}}, []string{"b/old"}},
+		{&RepositoryQuery{Archived: &no, Fork: &no}, []string{"a/cli"}},
+		{&RepositoryQuery{Archived: &yes}, []string{"b/old"}},
+		{&RepositoryQuery{Disabled: &yes}, []string{"c/fork"}},
+		{&RepositoryQuery{MaxSize: &maxSize, Fork: &no}, []string{"a/cli"}},
+	}
+
+	for i, c := range cases {
+		names := queryNames(t, repos, c.query)
+		if !equalPath(names, c.expected) {
+			t.Errorf("case %d: got %v, expected %v", i, names, c.expected)
+		}
+	}
+}
+
+func TestRepositoriesQueryAccounts(t *testing.T) {
+	repos := NewRepositories()
+	repos.Add([]string{}, &Repository{Name: "a/both", StarredBy: []string{"alice", "bob"}})
+	repos.Add([]string{}, &Repository{Name: "b/alice", StarredBy: []string{"alice"}})
+	repos.Add([]string{}, &Repository{Name: "c/bob", StarredBy: []string{"bob"}})
+	repos.Add([]string{}, &Repository{Name: "d/manual"})
+
+	cases := []struct {
+		query    *RepositoryQuery
+		expected []string
+	}{
+		{&RepositoryQuery{Accounts: []string{"Alice"}}, []string{"a/both", "b/alice"}},
+		{&RepositoryQuery{Accounts: []string{"alice", "bob"}}, []string{"a/both", "b/alice", "c/bob"}},
+		{&RepositoryQuery{Accounts: []string{"alice", "bob"}, AccountMode: TagModeAll}, []string{"a/both"}},
+		{&RepositoryQuery{Accounts: []string{"carol"}}, []string{}},
+	}
+
+	for i, c := range cases {
+		names := queryNames(t, repos, c.query)
+		if !equalPath(names, c.expected) {
+			t.Errorf("case %d: got %v, expected %v", i, names, c.expected)
+		}
+	}
+}
+
+func TestRepositoriesQuerySort(t *testing.T) {
+	repos := generateQueryRepositories()
+
+	names := queryNames(t, repos, &RepositoryQuery{Sort: SortByStars, Desc: true})
+	if !equalPath(names, []string{"b/gin", "c/cobra", "d/bubbletea", "a/tui"}) {
+		t.Errorf("sort by stars desc: %v", names)
+	}
+
+	names = queryNames(t, repos, &RepositoryQuery{Sort: SortByPushed})
+	if !equalPath(names, []string{"c/cobra", "b/gin", "a/tui", "d/bubbletea"}) {
+		t.Errorf("sort by pushed: %v", names)
+	}
+
+	_, err := repos.Query(&RepositoryQuery{Sort: "unknown"})
+	if !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("unknown sort key returns %v", err)
+	}
+}
+
+func TestRepositoriesQueryPagination(t *testing.T) {
+	repos := generateQueryRepositories()
+
+	q := &RepositoryQuery{Sort: SortByStars, Desc: true, Limit: 3}
+	result, err := repos.Query(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Total != 4 || len(result.Repositories) != 3 || result.NextCursor == "" {
+		t.Fatalf("first page: total %d, got %d, cursor %q", result.Total, len(result.Repositories), result.NextCursor)
+	}
+
+	// an inserted repository before cursor does not shift next page
+	repos.Add([]string{}, &Repository{Name: "e/new", StarsCount: 9000})
+
+	q.Cursor = result.NextCursor
+	names := queryNames(t, repos, q)
+	if !equalPath(names, []string{"a/tui"}) {
+		t.Errorf("second page by cursor: %v", names)
+	}
+
+	names = queryNames(t, repos, &RepositoryQuery{Sort: SortByStars, Desc: true, Offset: 1, Limit: 2})
+	if !equalPath(names, []string{"b/gin", "c/cobra"}) {
+		t.Errorf("page by offset: %v", names)
+	}
+}
@@ -5,17 +5,52 @@ import (
 	"sync"
 )
 
+var (
+	ErrRepositoryNotFound = errors.New("repository not found")
+	ErrRepositoryExists   = errors.New("repository already exists")
+	ErrPathNotFound       = errors.New("path not found")
+)
+
 type Repository struct {
+	// ID and NodeID are assigned by github and never change, Name changes when repository is
+	// renamed or transferred and old names are kept in PreviousNames
+	ID          int64
+	NodeID      string
 	Name        string
 	Url         string
 	Language    string
 	StarsCount  int
 	ForksCount  int
 	Description string
-	CreatedAt   int64
-	UpdatedAt   int64
-	PushedAt    int64
-	Tags        []string
+	Archived    bool
+	// Topics, License and following fields are imported from github by sync. License is SPDX
+	// ID like MIT, Size is in KB and OwnerType is User or Organization.
+	Topics        []string
+	License       string
+	Homepage      string
+	Fork          bool
+	Disabled      bool
+	DefaultBranch string
+	Size          int
+	OwnerType     string
+	CreatedAt     int64
+	UpdatedAt     int64
+	PushedAt      int64
+	StarredAt     int64
+	Tags          []string
+	// DescriptionOverride is set by user and shown instead of Description from github
+	DescriptionOverride string
+	// Note is free text written by user, it is only used for search
+	Note          string
+	PreviousNames []string
+	// UnstarredAt is set when repository is no longer starred on github but kept by sync, 0
+	// means it is starred. UnstarredFrom is the folder it is moved from into unstarred path,
+	// nil means it is not moved.
+	UnstarredAt   int64
+	UnstarredFrom []string
+	// StarredBy is lower-cased logins of synced github accounts starring the repository, it is
+	// empty for repositories added by user or synced before accounts are recorded
+	StarredBy []string
 }
 
 type RepositoryNameIndex struct {
@@ -28,6 +63,9 @@ type Repositories struct {
 	SubRepositories map[string]*Repositories
 	NameIndexes     map[string]*RepositoryNameIndex `json:"-"`
 	TagMap          map[string][]*Repository        `json:"-"`
+	IDIndexes       map[int64]string                `json:"-"`
+	AliasIndexes    map[string]string               `json:"-"`
+	SearchIndex     *SearchIndex                    `json:"-"`
 	sync.RWMutex    `json:"-"`
 }
 
@@ -37,12 +75,14 @@ func NewRepositories() *Repositories {
 		SubRepositories: make(map[string]*Repositories),
 		NameIndexes:     make(map[string]*RepositoryNameIndex),
 		TagMap:          make(map[string][]*Repository),
+		IDIndexes:       make(map[int64]string),
+		AliasIndexes:    make(map[string]string),
 	}
 }
 
 func (rs *Repositories) UpdateNameIndexes() {
-	rs.RLock()
-	defer rs.RUnlock()
+	rs.Lock()
+	defer rs.Unlock()
 
 	rs.updateNameIndexes([]string{}, rs.NameIndexes)
 }
@@ -57,25 +97,76 @@ func (rs *Repositories) updateNameIndexes(prePath []string, nameIndexes map[stri
 
 	if len(rs.SubRepositories) > 0 {
 		for k, v := range rs.SubRepositories {
-			v.updateNameIndexes(append(prePath, k), nameIndexes)
+			v.updateNameIndexes(joinPath(prePath, k), nameIndexes)
 		}
 	}
 }
 
 func (rs *Repositories) addRepoToNameIndexes(path []string, index int, repo *Repository) {
 	rs.NameIndexes[repo.Name] = &RepositoryNameIndex{
-		Path:  path,
+		Path:  joinPath(path),
 		Index: index,
 	}
 }
 
-func (rs *Repositories) delRepoFromNameIndexes(name string) {
+func (rs *Repositories) delRepoFromNameIndexes(path []string, index int, name string) {
 	delete(rs.NameIndexes, name)
+
+	// repositories after the deleted one are shifted forward in the same folder
+	repos := rs.get(path)
+	if repos == nil {
+		return
+	}
+
+	for idx := index; idx < len(repos.Repositories); idx++ {
+		rs.NameIndexes[repos.Repositories[idx].Name] = &RepositoryNameIndex{
+			Path:  joinPath(path),
+			Index: idx,
+		}
+	}
+}
+
+func (rs *Repositories) updateIDIndexes(idIndexes map[int64]string, aliasIndexes map[string]string) {
+	for _, r := range rs.Repositories {
+		if r.ID != 0 {
+			idIndexes[r.ID] = r.Name
+		}
+
+		for _, n := range r.PreviousNames {
+			aliasIndexes[n] = r.Name
+		}
+	}
+
+	for _, v := range rs.SubRepositories {
+		v.updateIDIndexes(idIndexes, aliasIndexes)
+	}
+}
+
+func (rs *Repositories) addRepoToIDIndexes(repo *Repository) {
+	if repo.ID != 0 {
+		rs.IDIndexes[repo.ID] = repo.Name
+	}
+
+	for _, n := range repo.PreviousNames {
+		rs.AliasIndexes[n] = repo.Name
+	}
+}
+
+func (rs *Repositories) delRepoFromIDIndexes(repo *Repository) {
+	if name, ok := rs.IDIndexes[repo.ID]; ok && name == repo.Name {
+		delete(rs.IDIndexes, repo.ID)
+	}
+
+	for _, n := range repo.PreviousNames {
+		if name, ok := rs.AliasIndexes[n]; ok && name == repo.Name {
+			delete(rs.AliasIndexes, n)
+		}
+	}
 }
 
 func (rs *Repositories) UpdateTagMap() {
-	rs.RLock()
-	defer rs.RUnlock()
+	rs.Lock()
+	defer rs.Unlock()
 
 	rs.updateTagMap(rs.TagMap)
 }
@@ -108,7 +199,12 @@ func (rs *Repositories) delRepoFromTagMap(repo *Repository) {
 				newRepoList = append(newRepoList, r)
 			}
 		}
-		rs.TagMap[t] = newRepoList
+
+		if len(newRepoList) == 0 {
+			delete(rs.TagMap, t)
+		} else {
+			rs.TagMap[t] = newRepoList
+		}
 	}
 }
 
@@ -120,6 +216,17 @@ func (rs *Repositories) Add(path []string, repo *Repository) {
 
 	rs.addRepoToNameIndexes(path, index, repo)
 	rs.addRepoToTagMap(repo)
+	rs.addRepoToIDIndexes(repo)
+	rs.addRepoToSearchIndex(repo)
+}
+
+// AddUnindexed adds repository to the tree without updating indexes, it is used to build trees in
+// bulk which are passed to LoadRepositories or followed by RebuildIndexes.
+func (rs *Repositories) AddUnindexed(path []string, repo *Repository) {
+	rs.Lock()
+	defer rs.Unlock()
+
+	rs.add(path, repo)
 }
 
 func (rs *Repositories) add(path []string, repo *Repository) int {
@@ -144,11 +251,15 @@ func (rs *Repositories) Get(path []string) *Repositories {
 	rs.RLock()
 	defer rs.RUnlock()
 
+	return rs.get(path)
+}
+
+func (rs *Repositories) get(path []string) *Repositories {
 	if len(path) == 0 {
 		return rs
 	} else {
 		if _, ok := rs.SubRepositories[path[0]]; ok {
-			return rs.SubRepositories[path[0]].Get(path[1:])
+			return rs.SubRepositories[path[0]].get(path[1:])
 		} else {
 			return nil
 		}
@@ -195,23 +306,68 @@ func (rs *Repositories) GetAllTag() []string {
 	return tags
 }
 
+// GetRepositoryByName finds repository by its current name, or by a previous name if the
+// repository is renamed or transferred.
 func (rs *Repositories) GetRepositoryByName(name string) ([]string, int, *Repository) {
 	rs.RLock()
 	defer rs.RUnlock()
 
+	path, idx, repo := rs.getRepositoryByName(name)
+	if repo != nil {
+		return path, idx, repo
+	}
+
+	if current, ok := rs.AliasIndexes[name]; ok {
+		return rs.getRepositoryByName(current)
+	}
+
+	return path, idx, repo
+}
+
+func (rs *Repositories) GetRepositoryByID(id int64) ([]string, int, *Repository) {
+	rs.RLock()
+	defer rs.RUnlock()
+
+	if name, ok := rs.IDIndexes[id]; ok {
+		path, idx, repo := rs.getRepositoryByName(name)
+		if repo != nil && repo.ID == id {
+			return path, idx, repo
+		}
+	}
+
+	var path []string
+	var index int
+	var repo *Repository
+	rs.walk([]string{}, func(p []string, i int, r *Repository) {
+		if repo == nil && id != 0 && r.ID == id {
+			path, index, repo = p, i, r
+		}
+	})
+
+	return path, index, repo
+}
+
+func (rs *Repositories) getRepositoryByName(name string) ([]string, int, *Repository) {
 	if nameIndex, ok := rs.NameIndexes[name]; ok {
-		return nameIndex.Path, nameIndex.Index, rs.getRepositoryByNameWithIndexes(nameIndex)
-	} else {
-		return rs.getRepositoryByNameWithoutIndexes(name)
+		repo := rs.getRepositoryByNameWithIndexes(nameIndex)
+		if repo != nil && repo.Name == name {
+			return nameIndex.Path, nameIndex.Index, repo
+		}
 	}
+
+	return rs.getRepositoryByNameWithoutIndexes(name)
 }
 
 func (rs *Repositories) getRepositoryByNameWithIndexes(indexes *RepositoryNameIndex) *Repository {
-	repos := rs.Get(indexes.Path)
+	repos := rs.get(indexes.Path)
 	if repos == nil {
 		return nil
 	}
 
+	if indexes.Index < 0 || indexes.Index >= len(repos.Repositories) {
+		return nil
+	}
+
 	return repos.Repositories[indexes.Index]
 }
 
@@ -254,41 +410,53 @@ func (rs *Repositories) getRepositoryByNameWithoutIndexes(name string) ([]string
 }
 
 func (rs *Repositories) Update(repo *Repository) error {
-	path, idx, existRepo := rs.GetRepositoryByName(repo.Name)
+	rs.Lock()
+	defer rs.Unlock()
+
+	return rs.update(repo)
+}
+
+func (rs *Repositories) update(repo *Repository) error {
+	path, idx, existRepo := rs.getRepositoryByName(repo.Name)
 	if existRepo == nil {
-		return errors.New("repository not found")
+		return ErrRepositoryNotFound
 	}
 
-	repos := rs.Get(path)
+	repos := rs.get(path)
 	if repos == nil {
-		return errors.New("path not found")
+		return ErrPathNotFound
 	}
 
-	rs.Lock()
-	defer rs.Unlock()
-
 	repos.Repositories[idx] = repo
 
-	rs.delRepoFromTagMap(repo)
+	rs.delRepoFromTagMap(existRepo)
 	rs.addRepoToTagMap(repo)
+	rs.addRepoToNameIndexes(path, idx, repo)
+	rs.delRepoFromIDIndexes(existRepo)
+	rs.addRepoToIDIndexes(repo)
+	rs.addRepoToSearchIndex(repo)
 
 	return nil
 }
 
 func (rs *Repositories) Delete(name string) {
-	path, _, repo := rs.GetRepositoryByName(name)
+	rs.Lock()
+	defer rs.Unlock()
+
+	rs.delete(name)
+}
+
+func (rs *Repositories) delete(name string) {
+	path, idx, repo := rs.getRepositoryByName(name)
 	if repo == nil {
 		return
 	}
 
-	repos := rs.Get(path)
+	repos := rs.get(path)
 	if repos == nil {
 		return
 	}
 
-	rs.Lock()
-	defer rs.Unlock()
-
 	UpdatedRepoList := make([]*Repository, 0)
 	for _, r := range repos.Repositories {
 		if r.Name != name {
@@ -298,6 +466,142 @@ func (rs *Repositories) Delete(name string) {
 
 	repos.Repositories = UpdatedRepoList
 
-	rs.delRepoFromNameIndexes(name)
+	rs.delRepoFromNameIndexes(path, idx, name)
 	rs.delRepoFromTagMap(repo)
+	rs.delRepoFromIDIndexes(repo)
+	rs.delRepoFromSearchIndex(name)
+}
+
+// Move moves repository to folder path, folders in path are created if they do not exist.
+func (rs *Repositories) Move(name string, path []string) error {
+	rs.Lock()
+	defer rs.Unlock()
+
+	return rs.move(name, path)
+}
+
+// UpdateAndMove updates repository and moves it to folder path at once, so nothing is changed
+// if the repository is not found.
+func (rs *Repositories) UpdateAndMove(repo *Repository, path []string) error {
+	rs.Lock()
+	defer rs.Unlock()
+
+	err := rs.update(repo)
+	if err != nil {
+		return err
+	}
+
+	return rs.move(repo.Name, path)
+}
+
+// SyncItem is one repository saved by sync, see ApplySync.
+type SyncItem struct {
+	// Name is current name of the stored repository when sync is planned, it is empty if the
+	// repository is newly starred
+	Name string
+	// ExistPath is folder of the stored repository when sync is planned and Path is where sync
+	// puts it
+	ExistPath []string
+	Path      []string
+	// Repository has fields managed by sync, fields set by user are kept from the stored one
+	Repository *Repository
+	// Delete deletes the stored repository
+	Delete bool
+}
+
+// ApplySync merges items into the tree in one step. Items are planned from a snapshot, so they
+// are merged into repositories as they are now: tags, description override and note set by
+// user are kept, a repository moved by user since then is not moved, and one deleted by user is
+// not added back. Names of items conflicting with another repository by name or id are returned
+// and they are not saved.
+func (rs *Repositories) ApplySync(items []*SyncItem) []string {
+	rs.Lock()
+	defer rs.Unlock()
+
+	skipped := make([]string, 0)
+	for _, item := range items {
+		if !rs.applySyncItem(item) {
+			skipped = append(skipped, item.Repository.Name)
+		}
+	}
+
+	return skipped
+}
+
+func (rs *Repositories) applySyncItem(item *SyncItem) bool {
+	name := item.Name
+	if name == "" {
+		// added by user since sync is planned
+		name = item.Repository.Name
+	}
+
+	path, _, exist := rs.getRepositoryByName(name)
+	if item.Delete || (exist == nil && item.Name != "") {
+		if exist != nil {
+			rs.delete(exist.Name)
+		}
+
+		return true
+	}
+
+	repo := *item.Repository
+	target := item.Path
+	if exist != nil {
+		repo.Tags = exist.Tags
+		repo.DescriptionOverride = exist.DescriptionOverride
+		repo.Note = exist.Note
+
+		if !equalPath(path, item.ExistPath) {
+			target = path
+		}
+
+		if repo.Name == exist.Name && equalPath(target, path) {
+			return rs.update(&repo) == nil
+		}
+	}
+
+	if _, _, r := rs.getRepositoryByName(repo.Name); r != nil && r != exist {
+		return false
+	}
+
+	if other, ok := rs.IDIndexes[repo.ID]; ok && repo.ID != 0 && (exist == nil || other != exist.Name) {
+		return false
+	}
+
+	if exist != nil {
+		rs.delete(exist.Name)
+	}
+
+	index := rs.add(target, &repo)
+	rs.addRepoToNameIndexes(target, index, &repo)
+	rs.addRepoToTagMap(&repo)
+	rs.addRepoToIDIndexes(&repo)
+	rs.addRepoToSearchIndex(&repo)
+
+	return true
+}
+
+func (rs *Repositories) move(name string, path []string) error {
+	_, _, repo := rs.getRepositoryByName(name)
+	if repo == nil {
+		return ErrRepositoryNotFound
+	}
+
+	rs.delete(name)
+
+	index := rs.add(path, repo)
+	rs.addRepoToNameIndexes(path, index, repo)
+	rs.addRepoToTagMap(repo)
+	rs.addRepoToIDIndexes(repo)
+	rs.addRepoToSearchIndex(repo)
+
+	return nil
+}
+
+func joinPath(path []string, names ...string) []string {
+	p := make([]string, 0, len(path)+len(names))
+	p = append(p, path...)
+	p = append(p, names...)
+
+	return p
 }
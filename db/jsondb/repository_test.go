@@ -206,7 +206,7 @@ func TestRepositoryDelete(t *testing.T) {
 	fmt.Println(string(tagMapJson))
 }
 
-func GenerateRepositories() Repositories {
+func GenerateRepositories() *Repositories {
 	repoLinux01 := Repository{
 		Name:       "linux01",
 		Url:        "http://linux01.com",
@@ -352,5 +352,5 @@ func GenerateRepositories() Repositories {
 	repos.Add(repoTool01.Tags, &repoTool01)
 	repos.Add(repoUnclassified01.Tags, &repoUnclassified01)
 
-	return *repos
+	return repos
 }
@@ -0,0 +1,455 @@
+package jsondb
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	searchFieldName        = "Name"
+	searchFieldDescription = "Description"
+	searchFieldOverride    = "DescriptionOverride"
+	searchFieldLanguage    = "Language"
+	searchFieldTags        = "Tags"
+	searchFieldTopics      = "Topics"
+	searchFieldNote        = "Note"
+
+	exactMatchWeight  = 1.0
+	prefixMatchWeight = 0.7
+	fuzzyMatchWeight  = 0.4
+
+	snippetRadius = 60
+)
+
+var searchFieldWeights = map[string]float64{
+	searchFieldName:        3.0,
+	searchFieldTags:        2.5,
+	searchFieldTopics:      2.0,
+	searchFieldLanguage:    2.0,
+	searchFieldNote:        1.5,
+	searchFieldOverride:    1.2,
+	searchFieldDescription: 1.0,
+}
+
+type SearchResult struct {
+	Path       []string
+	Repository *Repository
+	Score      float64
+	// Highlights contains html escaped snippets of matched fields with matched words wrapped in
+	// <em></em>
+	Highlights map[string]string
+}
+
+// SearchIndex is an inverted index from terms to repositories, it is maintained together
+// with NameIndexes and TagMap under the lock of root Repositories.
+type SearchIndex struct {
+	// postings maps term to weighted term frequency in each repository
+	postings map[string]map[string]float64
+	docTerms map[string][]string
+	docs     map[string]*Repository
+	// terms are sorted for prefix matching on the first search after they change, so adding
+	// repositories in bulk does not sort them every time
+	terms    []string
+	unsorted bool
+	// sortLock serializes sorting terms by concurrent searches under read lock
+	sortLock sync.Mutex
+}
+
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		postings: make(map[string]map[string]float64),
+		docTerms: make(map[string][]string),
+		docs:     make(map[string]*Repository),
+		terms:    make([]string, 0),
+	}
+}
+
+func searchFields(repo *Repository) map[string]string {
+	return map[string]string{
+		searchFieldName:        repo.Name,
+		searchFieldDescription: repo.Description,
+		searchFieldOverride:    repo.DescriptionOverride,
+		searchFieldLanguage:    repo.Language,
+		searchFieldTags:        strings.Join(repo.Tags, " "),
+		searchFieldTopics:      strings.Join(repo.Topics, " "),
+		searchFieldNote:        repo.Note,
+	}
+}
+
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func (si *SearchIndex) Len() int {
+	return len(si.docs)
+}
+
+func (si *SearchIndex) Add(repo *Repository) {
+	si.Delete(repo.Name)
+
+	weights := make(map[string]float64)
+	for field, text := range searchFields(repo) {
+		for _, term := range tokenize(text) {
+			weights[term] += searchFieldWeights[field]
+		}
+	}
+
+	terms := make([]string, 0, len(weights))
+	for term, w := range weights {
+		posting, ok := si.postings[term]
+		if !ok {
+			posting = make(map[string]float64)
+			si.postings[term] = posting
+			si.terms = append(si.terms, term)
+			si.unsorted = true
+		}
+		posting[repo.Name] = w
+		terms = append(terms, term)
+	}
+
+	si.docTerms[repo.Name] = terms
+	si.docs[repo.Name] = repo
+}
+
+// Delete removes repository, order of the remaining terms is kept.
+func (si *SearchIndex) Delete(name string) {
+	terms, ok := si.docTerms[name]
+	if !ok {
+		return
+	}
+
+	removed := make(map[string]bool)
+	for _, term := range terms {
+		posting := si.postings[term]
+		delete(posting, name)
+		if len(posting) == 0 {
+			delete(si.postings, term)
+			removed[term] = true
+		}
+	}
+
+	delete(si.docTerms, name)
+	delete(si.docs, name)
+
+	if len(removed) == 0 {
+		return
+	}
+
+	kept := si.terms[:0]
+	for _, term := range si.terms {
+		if !removed[term] {
+			kept = append(kept, term)
+		}
+	}
+	si.terms = kept
+}
+
+// sortTerms sorts terms changed since the last search. Searches hold read lock of the
+// repositories, so they sort under sortLock, and terms are not changed while they run.
+func (si *SearchIndex) sortTerms() {
+	si.sortLock.Lock()
+	defer si.sortLock.Unlock()
+
+	if si.unsorted {
+		sort.Strings(si.terms)
+		si.unsorted = false
+	}
+}
+
+// Search returns repositories matching any word of query ordered by relevance. Every word is
+// matched exactly, as prefix of indexed terms and with small typos, exact match ranks highest.
+// Repositories are skipped if match is not nil and returns false.
+func (si *SearchIndex) Search(query string, match func(*Repository) bool, limit int) []*SearchResult {
+	queryTerms := dedupTags(tokenize(query))
+	if len(queryTerms) == 0 {
+		return []*SearchResult{}
+	}
+
+	si.sortTerms()
+
+	scores := make(map[string]float64)
+	matchedWords := make(map[string]int)
+	matchedTerms := make(map[string]bool)
+	total := float64(len(si.docs))
+
+	for _, qt := range queryTerms {
+		best := make(map[string]float64)
+		for term, weight := range si.matchTerms(qt) {
+			matchedTerms[term] = true
+			posting := si.postings[term]
+			idf := math.Log(1 + total/float64(len(posting)))
+			for name, tf := range posting {
+				score := weight * tf * idf
+				if score > best[name] {
+					best[name] = score
+				}
+			}
+		}
+
+		for name, score := range best {
+			scores[name] += score
+			matchedWords[name]++
+		}
+	}
+
+	results := make([]*SearchResult, 0, len(scores))
+	for name, score := range scores {
+		if match != nil && !match(si.docs[name]) {
+			continue
+		}
+
+		// repositories matching more words of query are preferred
+		coord := float64(matchedWords[name]) / float64(len(queryTerms))
+		results = append(results, &SearchResult{
+			Repository: si.docs[name],
+			Score:      score * coord,
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Repository.Name < results[j].Repository.Name
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	for _, r := range results {
+		r.Highlights = highlight(r.Repository, matchedTerms)
+	}
+
+	return results
+}
+
+// matchTerms returns indexed terms matching query term with match weight.
+func (si *SearchIndex) matchTerms(qt string) map[string]float64 {
+	matched := make(map[string]float64)
+
+	if _, ok := si.postings[qt]; ok {
+		matched[qt] = exactMatchWeight
+	}
+
+	if utf8.RuneCountInString(qt) >= 2 {
+		start := sort.SearchStrings(si.terms, qt)
+		for i := start; i < len(si.terms) && strings.HasPrefix(si.terms[i], qt); i++ {
+			if _, ok := matched[si.terms[i]]; !ok {
+				matched[si.terms[i]] = prefixMatchWeight
+			}
+		}
+	}
+
+	maxDistance := fuzzyDistance(qt)
+	if maxDistance == 0 {
+		return matched
+	}
+
+	qLen := utf8.RuneCountInString(qt)
+	for _, term := range si.terms {
+		if _, ok := matched[term]; ok {
+			continue
+		}
+
+		tLen := utf8.RuneCountInString(term)
+		if tLen < qLen-maxDistance || tLen > qLen+maxDistance {
+			continue
+		}
+
+		if d := levenshtein(qt, term); d <= maxDistance {
+			matched[term] = fuzzyMatchWeight / float64(d)
+		}
+	}
+
+	return matched
+}
+
+func fuzzyDistance(term string) int {
+	n := utf8.RuneCountInString(term)
+	switch {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func levenshtein(a string, b string) int {
+	ra := []rune(a)
+	rb := []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+func min3(a int, b int, c int) int {
+	m := a
+	if b < m {
+		m = b
+	}
+	if c < m {
+		m = c
+	}
+
+	return m
+}
+
+func highlight(repo *Repository, terms map[string]bool) map[string]string {
+	highlights := make(map[string]string)
+	for field, text := range searchFields(repo) {
+		if snippet, ok := snippetOf(text, terms); ok {
+			highlights[field] = snippet
+		}
+	}
+
+	return highlights
+}
+
+// snippetOf wraps words of text in terms with <em></em>, and cuts text around the first
+// matched word if it is long.
+func snippetOf(text string, terms map[string]bool) (string, bool) {
+	type span struct {
+		start int
+		end   int
+	}
+
+	spans := make([]span, 0)
+	start := -1
+	for i, r := range text + " " {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start < 0 {
+			start = i
+		} else if !isWord && start >= 0 {
+			if terms[strings.ToLower(text[start:i])] {
+				spans = append(spans, span{start, i})
+			}
+			start = -1
+		}
+	}
+
+	if len(spans) == 0 {
+		return "", false
+	}
+
+	from, to := 0, len(text)
+	if spans[0].start > snippetRadius {
+		from = spans[0].start - snippetRadius
+		for from < len(text) && !utf8.RuneStart(text[from]) {
+			from++
+		}
+	}
+	if spans[0].end+snippetRadius < len(text) {
+		to = spans[0].end + snippetRadius
+		for to > from && to < len(text) && !utf8.RuneStart(text[to]) {
+			to--
+		}
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("...")
+	}
+
+	// text comes from github, so it is escaped for clients rendering snippet as html
+	pos := from
+	for _, s := range spans {
+		if s.start < from || s.end > to {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:s.start]))
+		b.WriteString("<em>")
+		b.WriteString(html.EscapeString(text[s.start:s.end]))
+		b.WriteString("</em>")
+		pos = s.end
+	}
+	b.WriteString(html.EscapeString(text[pos:to]))
+
+	if to < len(text) {
+		b.WriteString("...")
+	}
+
+	return b.String(), true
+}
+
+// Search returns repositories matching query, and matching filter as well if it is not nil.
+// Sort and paging of filter are ignored.
+func (rs *Repositories) Search(query string, filter *RepositoryQuery, limit int) []*SearchResult {
+	var match func(*Repository) bool
+	if filter != nil {
+		// candidates are collected before lock is held since collecting them locks rs
+		matched := make(map[string]bool)
+		for _, r := range rs.queryCandidates(filter) {
+			if filter.match(r) {
+				matched[r.Name] = true
+			}
+		}
+		match = func(r *Repository) bool { return matched[r.Name] }
+	}
+
+	rs.RLock()
+	defer rs.RUnlock()
+
+	if rs.SearchIndex == nil {
+		return []*SearchResult{}
+	}
+
+	results := rs.SearchIndex.Search(query, match, limit)
+	for _, r := range results {
+		r.Path, _, _ = rs.getRepositoryByName(r.Repository.Name)
+	}
+
+	return results
+}
+
+func (rs *Repositories) addRepoToSearchIndex(repo *Repository) {
+	if rs.SearchIndex == nil {
+		rs.SearchIndex = NewSearchIndex()
+	}
+
+	rs.SearchIndex.Add(repo)
+}
+
+func (rs *Repositories) delRepoFromSearchIndex(name string) {
+	if rs.SearchIndex == nil {
+		return
+	}
+
+	rs.SearchIndex.Delete(name)
+}
+
+func (rs *Repositories) updateSearchIndex(si *SearchIndex) {
+	for _, r := range rs.Repositories {
+		si.Add(r)
+	}
+
+	for _, v := range rs.SubRepositories {
+		v.updateSearchIndex(si)
+	}
+}
@@ -0,0 +1,211 @@
+package jsondb
+
+import (
+	"strings"
+	"testing"
+)
+
+func searchNames(repos *Repositories, query string) []string {
+	names := make([]string, 0)
+	for _, r := range repos.Search(query, nil, 0) {
+		names = append(names, r.Repository.Name)
+	}
+
+	return names
+}
+
+func TestRepositoriesSearch(t *testing.T) {
+	repos := generateQueryRepositories()
+
+	cases := []struct {
+		query    string
+		expected []string
+	}{
+		{"", []string{}},
+		{"gin", []string{"b/gin"}},
+		{"GIN", []string{"b/gin"}},
+		{"bubble", []string{"d/bubbletea"}},
+		{"framwork", []string{"d/bubbletea"}},
+		{"rust terminal", []string{"a/tui"}},
+		{"missing", []string{}},
+	}
+
+	for _, c := range cases {
+		names := searchNames(repos, c.query)
+		if !equalPath(names, c.expected) {
+			t.Errorf("search %q: got %v, expected %v", c.query, names, c.expected)
+		}
+	}
+}
+
+func TestRepositoriesSearchRanking(t *testing.T) {
+	repos := generateQueryRepositories()
+
+	// name and tag matches rank higher than description matches, and more matched words rank higher
+	names := searchNames(repos, "tui")
+	if len(names) != 3 || names[0] != "a/tui" {
+		t.Errorf("search tui: got %v, expected a/tui first of 3", names)
+	}
+
+	names = searchNames(repos, "tui framework")
+	if len(names) == 0 || names[0] != "d/bubbletea" {
+		t.Errorf("search tui framework: got %v, expected d/bubbletea first", names)
+	}
+
+	results := repos.Search("terminal", nil, 1)
+	if len(results) != 1 {
+		t.Fatalf("got %d results, expected 1", len(results))
+	}
+
+	if h := results[0].Highlights[searchFieldDescription]; !strings.Contains(h, "<em>terminal</em>") {
+		t.Errorf("description highlight is %q", h)
+	}
+
+	if !equalPath(results[0].Path, []string{}) {
+		t.Errorf("path of a/tui is %v", results[0].Path)
+	}
+}
+
+func TestRepositoriesSearchFilter(t *testing.T) {
+	repos := generateQueryRepositories()
+
+	repo := *repos.Repositories[0]
+	repo.Topics = []string{"terminal-ui"}
+	repo.Archived = true
+	err := repos.Update(&repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := repos.Search("terminal", nil, 0)
+	if len(results) != 1 || !strings.Contains(results[0].Highlights[searchFieldTopics], "<em>terminal</em>") {
+		t.Fatalf("search topics: got %+v", results)
+	}
+
+	archived := false
+	cases := []struct {
+		filter   *RepositoryQuery
+		expected []string
+	}{
+		{&RepositoryQuery{Archived: &archived}, []string{"d/bubbletea", "c/cobra"}},
+		{&RepositoryQuery{Path: []string{"go", "cli"}, Tags: []string{"cli"}}, []string{"d/bubbletea", "c/cobra"}},
+		{&RepositoryQuery{Languages: []string{"rust"}}, []string{"a/tui"}},
+	}
+
+	for i, c := range cases {
+		names := make([]string, 0)
+		for _, r := range repos.Search("tui", c.filter, 0) {
+			names = append(names, r.Repository.Name)
+		}
+
+		if !equalPath(names, c.expected) {
+			t.Errorf("case %d: got %v, expected %v", i, names, c.expected)
+		}
+	}
+}
+
+func TestRepositoriesSearchIndexMaintenance(t *testing.T) {
+	repos := generateQueryRepositories()
+
+	repo := *repos.Repositories[0]
+	repo.Note = "keyboard driven"
+	err := repos.Update(&repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if names := searchNames(repos, "keyboard"); !equalPath(names, []string{"a/tui"}) {
+		t.Errorf("search updated note: got %v", names)
+	}
+
+	err = repos.Move("b/gin", []string{"web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := repos.Search("gin", nil, 0)
+	if len(results) != 1 || !equalPath(results[0].Path, []string{"web"}) {
+		t.Errorf("search moved repository: got %+v", results)
+	}
+
+	repos.Delete("d/bubbletea")
+	if names := searchNames(repos, "bubbletea"); len(names) != 0 {
+		t.Errorf("search deleted repository: got %v", names)
+	}
+
+	repos.RenameTag("tui", "console")
+	if names := searchNames(repos, "console"); !equalPath(names, []string{"a/tui", "c/cobra"}) {
+		t.Errorf("search renamed tag: got %v", names)
+	}
+
+	if problems, _ := repos.CheckIndexes(false); len(problems) != 0 {
+		t.Errorf("indexes drift: %v", problems)
+	}
+}
+
+func TestRepositoriesSearchEscapesHighlights(t *testing.T) {
+	repos := NewRepositories()
+	repos.Add([]string{}, &Repository{Name: "evil/xss", Description: `<script>alert(1)</script> evil`})
+
+	results := repos.Search("evil", nil, 0)
+	if len(results) != 1 {
+		t.Fatalf("got %d results, expected 1", len(results))
+	}
+
+	if h := results[0].Highlights[searchFieldDescription]; h != "&lt;script&gt;alert(1)&lt;/script&gt; <em>evil</em>" {
+		t.Errorf("description highlight is %q", h)
+	}
+}
+
+func TestSnippetOf(t *testing.T) {
+	text := strings.Repeat("x ", 50) + "match here" + strings.Repeat(" y", 50)
+	snippet, ok := snippetOf(text, map[string]bool{"match": true})
+	if !ok {
+		t.Fatal("expected match")
+	}
+
+	if !strings.HasPrefix(snippet, "...") || !strings.HasSuffix(snippet, "...") ||
+		!strings.Contains(snippet, "<em>match</em> here") {
+		t.Errorf("snippet is %q", snippet)
+	}
+
+	if _, ok = snippetOf("nothing", map[string]bool{"match": true}); ok {
+		t.Error("expected no match")
+	}
+
+	snippet, _ = snippetOf(`<script>alert("match")</script> & match`, map[string]bool{"match": true})
+	expected := `&lt;script&gt;alert(&#34;<em>match</em>&#34;)&lt;/script&gt; &amp; <em>match</em>`
+	if snippet != expected {
+		t.Errorf("snippet is %q, expected %q", snippet, expected)
+	}
+}
+
+func TestRepositoriesSearchAfterBulkAdd(t *testing.T) {
+	repos := generateQueryRepositories()
+	if names := searchNames(repos, "zeb"); len(names) != 0 {
+		t.Errorf("search before add: got %v", names)
+	}
+
+	// terms added after a search are sorted on the next search
+	repos.Add([]string{}, &Repository{Name: "z/zebra"})
+	repos.Add([]string{}, &Repository{Name: "a/aardvark"})
+	if names := searchNames(repos, "zeb"); !equalPath(names, []string{"z/zebra"}) {
+		t.Errorf("search prefix after add: got %v", names)
+	}
+	if names := searchNames(repos, "aard"); !equalPath(names, []string{"a/aardvark"}) {
+		t.Errorf("search prefix after add: got %v", names)
+	}
+
+	bulk := NewRepositories()
+	bulk.AddUnindexed([]string{"web"}, &Repository{Name: "y/yak"})
+	bulk.AddUnindexed([]string{}, &Repository{Name: "b/badger"})
+	if bulk.SearchIndex != nil || len(bulk.NameIndexes) != 0 {
+		t.Fatal("indexes are built by AddUnindexed")
+	}
+
+	bulk.RebuildIndexes()
+	results := bulk.Search("yak", nil, 0)
+	if len(results) != 1 || !equalPath(results[0].Path, []string{"web"}) {
+		t.Errorf("search rebuilt index: got %+v", results)
+	}
+}
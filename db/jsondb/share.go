@@ -0,0 +1,92 @@
+package jsondb
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var ErrShareNotFound = errors.New("share not found")
+
+// Share publishes repositories of a folder including sub folders, or of a tag, by a read-only
+// link. Only hash of the token in the link is kept. Times are unix seconds, ExpiresAt 0 means
+// the share never expires.
+type Share struct {
+	ID        string
+	Name      string
+	Path      []string `json:",omitempty"`
+	Tag       string   `json:",omitempty"`
+	Hash      string
+	CreatedAt int64
+	ExpiresAt int64
+}
+
+func (s *Share) Expired(now time.Time) bool {
+	return s.ExpiresAt != 0 && now.Unix() >= s.ExpiresAt
+}
+
+// GetShares returns copies of shares in created order.
+func (c *Common) GetShares() []*Share {
+	shares := make([]*Share, 0, len(c.Shares))
+	for _, s := range c.Shares {
+		share := *s
+		shares = append(shares, &share)
+	}
+
+	return shares
+}
+
+func (c *Common) GetShareByHash(hash string) *Share {
+	for _, s := range c.Shares {
+		if s.Hash == hash {
+			share := *s
+			return &share
+		}
+	}
+
+	return nil
+}
+
+func (c *Common) AddShare(share *Share) {
+	s := *share
+	c.Shares = append(c.Shares, &s)
+}
+
+func (c *Common) DeleteShare(id string) error {
+	for i, s := range c.Shares {
+		if s.ID == id {
+			c.Shares = append(c.Shares[:i:i], c.Shares[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrShareNotFound
+}
+
+// MoveSharedFolder updates path of shares in folder at path after it is moved into parent
+// folder, the folder is renamed to name if it is not empty like Repositories.MoveFolder.
+func (c *Common) MoveSharedFolder(path []string, parent []string, name string) {
+	if name == "" {
+		name = path[len(path)-1]
+	}
+
+	to := joinPath(parent, name)
+	for i, s := range c.Shares {
+		if len(s.Path) > 0 && HasPathPrefix(s.Path, path) {
+			share := *s
+			share.Path = ReplacePathPrefix(s.Path, path, to)
+			c.Shares[i] = &share
+		}
+	}
+}
+
+// DeleteSharedFolder deletes shares of folder at path and its sub folders after it is deleted.
+func (c *Common) DeleteSharedFolder(path []string) {
+	shares := make([]*Share, 0, len(c.Shares))
+	for _, s := range c.Shares {
+		if len(s.Path) == 0 || !HasPathPrefix(s.Path, path) {
+			shares = append(shares, s)
+		}
+	}
+	c.Shares = shares
+}
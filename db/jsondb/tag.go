@@ -0,0 +1,169 @@
+package jsondb
+
+import (
+	"errors"
+	"sort"
+)
+
+var ErrTagNotFound = errors.New("tag not found")
+
+type TagCount struct {
+	Name  string
+	Count int
+}
+
+// GetTagCounts returns all tags ordered by usage count.
+func (rs *Repositories) GetTagCounts() []*TagCount {
+	rs.RLock()
+	defer rs.RUnlock()
+
+	counts := make([]*TagCount, 0, len(rs.TagMap))
+	for k, v := range rs.TagMap {
+		counts = append(counts, &TagCount{Name: k, Count: len(v)})
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Name < counts[j].Name
+	})
+
+	return counts
+}
+
+// HasTag returns true if any repository has tag.
+func (rs *Repositories) HasTag(tag string) bool {
+	rs.RLock()
+	defer rs.RUnlock()
+
+	return len(rs.TagMap[tag]) > 0
+}
+
+func (rs *Repositories) RenameTag(tag string, name string) []string {
+	return rs.MergeTags([]string{tag}, name)
+}
+
+// MergeTags replaces all tags in tags with target, and returns names of changed repositories.
+func (rs *Repositories) MergeTags(tags []string, target string) []string {
+	set := stringSet(tags)
+
+	rs.Lock()
+	defer rs.Unlock()
+
+	return rs.replaceTags(func(r *Repository) ([]string, bool) {
+		newTags := make([]string, 0, len(r.Tags))
+		changed := false
+		for _, t := range r.Tags {
+			if set[t] {
+				t = target
+				changed = true
+			}
+			newTags = append(newTags, t)
+		}
+
+		return dedupTags(newTags), changed
+	})
+}
+
+// DeleteTag removes tag from all repositories, and returns names of changed repositories.
+func (rs *Repositories) DeleteTag(tag string) []string {
+	rs.Lock()
+	defer rs.Unlock()
+
+	return rs.replaceTags(func(r *Repository) ([]string, bool) {
+		newTags := make([]string, 0, len(r.Tags))
+		for _, t := range r.Tags {
+			if t != tag {
+				newTags = append(newTags, t)
+			}
+		}
+
+		return newTags, len(newTags) != len(r.Tags)
+	})
+}
+
+// UpdateTags adds and removes tags on repositories with names, nothing is changed if any
+// repository is not found.
+func (rs *Repositories) UpdateTags(names []string, add []string, remove []string) ([]string, error) {
+	nameSet := stringSet(names)
+	removeSet := stringSet(remove)
+
+	rs.Lock()
+	defer rs.Unlock()
+
+	for name := range nameSet {
+		if _, _, r := rs.getRepositoryByName(name); r == nil {
+			return nil, ErrRepositoryNotFound
+		}
+	}
+
+	return rs.replaceTags(func(r *Repository) ([]string, bool) {
+		if !nameSet[r.Name] {
+			return nil, false
+		}
+
+		newTags := make([]string, 0, len(r.Tags)+len(add))
+		for _, t := range r.Tags {
+			if !removeSet[t] {
+				newTags = append(newTags, t)
+			}
+		}
+		newTags = dedupTags(append(newTags, add...))
+
+		return newTags, !equalPath(newTags, r.Tags)
+	}), nil
+}
+
+// replaceTags replaces every repository whose tags are changed by fn with a copy carrying new
+// tags, so repositories already handed out are never modified.
+func (rs *Repositories) replaceTags(fn func(r *Repository) ([]string, bool)) []string {
+	changed := make([]string, 0)
+	rs.replaceTagsInFolder(fn, &changed)
+
+	if len(changed) > 0 {
+		rs.rebuildIndexes()
+	}
+
+	return changed
+}
+
+func (rs *Repositories) replaceTagsInFolder(fn func(r *Repository) ([]string, bool), changed *[]string) {
+	for idx, r := range rs.Repositories {
+		tags, ok := fn(r)
+		if !ok {
+			continue
+		}
+
+		newRepo := *r
+		newRepo.Tags = tags
+		rs.Repositories[idx] = &newRepo
+		*changed = append(*changed, r.Name)
+	}
+
+	for _, v := range rs.SubRepositories {
+		v.replaceTagsInFolder(fn, changed)
+	}
+}
+
+func stringSet(list []string) map[string]bool {
+	set := make(map[string]bool, len(list))
+	for _, s := range list {
+		set[s] = true
+	}
+
+	return set
+}
+
+func dedupTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	deduped := make([]string, 0, len(tags))
+	for _, t := range tags {
+		if !seen[t] {
+			seen[t] = true
+			deduped = append(deduped, t)
+		}
+	}
+
+	return deduped
+}
@@ -0,0 +1,94 @@
+package jsondb
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRepositoriesGetTagCounts(t *testing.T) {
+	repos := GenerateRepositories()
+
+	counts := repos.GetTagCounts()
+	if len(counts) == 0 || counts[0].Name != "linux" || counts[0].Count != 6 {
+		t.Errorf("most used tag = %+v, expected linux with 6", counts[0])
+	}
+}
+
+func TestRepositoriesRenameTag(t *testing.T) {
+	repos := GenerateRepositories()
+
+	_, _, before := repos.GetRepositoryByName("linux_ebpf_01")
+
+	changed := repos.RenameTag("ebpf", "bpf")
+	if len(changed) != 2 {
+		t.Errorf("renamed %d repositories, expected 2", len(changed))
+	}
+	checkNoIndexDrift(t, repos)
+
+	if len(repos.GetAllRepositoryByTag("ebpf")) != 0 || len(repos.GetAllRepositoryByTag("bpf")) != 2 {
+		t.Error("tag map is not updated after rename")
+	}
+
+	if before.Tags[1] != "ebpf" {
+		t.Error("repository handed out before rename is modified")
+	}
+}
+
+func TestRepositoriesMergeTags(t *testing.T) {
+	repos := GenerateRepositories()
+
+	changed := repos.MergeTags([]string{"linux", "ebpf", "proxy"}, "kernel")
+	if len(changed) != 6 {
+		t.Errorf("merged %d repositories, expected 6", len(changed))
+	}
+	checkNoIndexDrift(t, repos)
+
+	_, _, repo := repos.GetRepositoryByName("linux_ebpf_01")
+	if len(repo.Tags) != 1 || repo.Tags[0] != "kernel" {
+		t.Errorf("tags after merge = %v, expected [kernel]", repo.Tags)
+	}
+}
+
+func TestRepositoriesDeleteTag(t *testing.T) {
+	repos := GenerateRepositories()
+
+	changed := repos.DeleteTag("ai")
+	if len(changed) != 3 {
+		t.Errorf("deleted tag from %d repositories, expected 3", len(changed))
+	}
+	checkNoIndexDrift(t, repos)
+
+	for _, tag := range repos.GetAllTag() {
+		if tag == "ai" {
+			t.Error("deleted tag is still listed")
+		}
+	}
+}
+
+func TestRepositoriesUpdateTags(t *testing.T) {
+	repos := GenerateRepositories()
+
+	changed, err := repos.UpdateTags([]string{"tool_01", "ai_01"}, []string{"favorite", "tool"}, []string{"ai"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changed) != 2 {
+		t.Errorf("updated %d repositories, expected 2", len(changed))
+	}
+	checkNoIndexDrift(t, repos)
+
+	_, _, repo := repos.GetRepositoryByName("ai_01")
+	if !equalPath(repo.Tags, []string{"favorite", "tool"}) {
+		t.Errorf("tags of ai_01 = %v", repo.Tags)
+	}
+
+	_, err = repos.UpdateTags([]string{"tool_01", "missing"}, []string{"x"}, nil)
+	if !errors.Is(err, ErrRepositoryNotFound) {
+		t.Errorf("update tags of missing repository returns %v", err)
+	}
+
+	if len(repos.GetAllRepositoryByTag("x")) != 0 {
+		t.Error("tags are partially updated")
+	}
+}
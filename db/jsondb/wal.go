@@ -0,0 +1,238 @@
+package jsondb
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/fs714/github-star-manager/pkg/utils/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	walOpToken     = "token"
+	walOpSyncState = "sync_state"
+	walOpHistory   = "sync_history"
+	walOpAPITokens = "api_tokens"
+	walOpShares    = "shares"
+	walOpLoad      = "load"
+	walOpAdd       = "add"
+	walOpUpdate    = "update"
+	walOpDelete    = "delete"
+	walOpMove      = "move"
+	// walOpUpdateMove updates Repository and moves it to Path, nil Path is the root folder
+	walOpUpdateMove = "update_move"
+	// walOpSync applies SyncItems, see Repositories.ApplySync
+	walOpSync = "sync"
+
+	walOpDeleteRepositories = "delete_repositories"
+
+	walOpCreateFolder = "create_folder"
+	walOpRenameFolder = "rename_folder"
+	walOpMoveFolder   = "move_folder"
+	walOpDeleteFolder = "delete_folder"
+
+	walOpMergeTags  = "merge_tags"
+	walOpDeleteTag  = "delete_tag"
+	walOpUpdateTags = "update_tags"
+)
+
+type walEntry struct {
+	// Seq increases with every entry and never restarts after wal is truncated, entries not
+	// newer than WalSeq of db file are already in it
+	Seq          int64
+	Op           string
+	Token        string        `json:",omitempty"`
+	Name         string        `json:",omitempty"`
+	Path         []string      `json:",omitempty"`
+	Parent       []string      `json:",omitempty"`
+	Cascade      bool          `json:",omitempty"`
+	Names        []string      `json:",omitempty"`
+	Tags         []string      `json:",omitempty"`
+	RemoveTags   []string      `json:",omitempty"`
+	Repository   *Repository   `json:",omitempty"`
+	Repositories *Repositories `json:",omitempty"`
+	SyncItems    []*SyncItem   `json:",omitempty"`
+	SyncState    *SyncState    `json:",omitempty"`
+	SyncHistory  *SyncHistory  `json:",omitempty"`
+	APITokens    []*APIToken   `json:",omitempty"`
+	Shares       []*Share      `json:",omitempty"`
+}
+
+func walPath(dbPath string) string {
+	return dbPath + ".wal"
+}
+
+// wal is an append only log with one json encoded entry per line, it is truncated after
+// every successful write of db file. seq is of the last appended or replayed entry.
+type wal struct {
+	f   *os.File
+	seq int64
+}
+
+func openWal(path string) (*wal, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, errors.Wrap(err, "open wal error")
+	}
+
+	return &wal{f: f}, nil
+}
+
+func (w *wal) Append(entry *walEntry) error {
+	entry.Seq = w.seq + 1
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "marshal wal entry error")
+	}
+
+	_, err = w.f.Write(append(data, '\n'))
+	if err != nil {
+		return errors.Wrap(err, "write wal error")
+	}
+
+	err = w.f.Sync()
+	if err != nil {
+		return errors.Wrap(err, "sync wal error")
+	}
+	w.seq = entry.Seq
+
+	return nil
+}
+
+// Replay applies entries in wal which are newer than db file to j and returns the number of
+// applied entries. Entries already in db file are left by crash between writing db file and
+// truncating wal, they are skipped since replaying them again duplicates history and moves
+// folders twice. An incomplete last line left by crash is ignored.
+func (w *wal) Replay(j *JsonConfig) (int, error) {
+	w.seq = j.WalSeq
+
+	_, err := w.f.Seek(0, io.SeekStart)
+	if err != nil {
+		return 0, errors.Wrap(err, "seek wal error")
+	}
+
+	count := 0
+	reader := bufio.NewReader(w.f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				if len(line) > 0 {
+					log.Warnw("ignore incomplete wal entry", "path", w.f.Name())
+				}
+				break
+			}
+
+			return count, errors.Wrap(err, "read wal error")
+		}
+
+		entry := &walEntry{Repositories: NewRepositories()}
+		err = json.Unmarshal(line, entry)
+		if err != nil {
+			return count, errors.Wrap(err, "unmarshal wal entry error")
+		}
+
+		if entry.Seq <= j.WalSeq {
+			continue
+		}
+
+		j.apply(entry)
+		count++
+		if entry.Seq > w.seq {
+			w.seq = entry.Seq
+		}
+	}
+
+	if count > 0 {
+		log.Infow("replayed wal entries", "path", w.f.Name(), "count", count)
+	}
+
+	return count, nil
+}
+
+func (w *wal) Truncate() error {
+	err := w.f.Truncate(0)
+	if err != nil {
+		return errors.Wrap(err, "truncate wal error")
+	}
+
+	err = w.f.Sync()
+	if err != nil {
+		return errors.Wrap(err, "sync wal error")
+	}
+
+	return nil
+}
+
+func (w *wal) Close() error {
+	return w.f.Close()
+}
+
+func (j *JsonConfig) apply(entry *walEntry) {
+	switch entry.Op {
+	case walOpToken:
+		j.Common.GithubToken = entry.Token
+	case walOpSyncState:
+		if entry.SyncState != nil {
+			j.Common.UpdateSyncState(entry.Name, entry.SyncState)
+		}
+	case walOpAPITokens:
+		j.Common.APITokens = entry.APITokens
+	case walOpShares:
+		j.Common.Shares = entry.Shares
+	case walOpHistory:
+		if entry.SyncHistory != nil {
+			j.SyncHistory = AppendSyncHistory(j.SyncHistory, entry.SyncHistory)
+		}
+	case walOpLoad:
+		entry.Repositories.RebuildIndexes()
+		j.Repositories = entry.Repositories
+	case walOpAdd:
+		if entry.Repository != nil {
+			j.Repositories.Delete(entry.Repository.Name)
+			j.Repositories.Add(entry.Path, entry.Repository)
+		}
+	case walOpUpdate:
+		if entry.Repository != nil {
+			j.Repositories.Update(entry.Repository)
+		}
+	case walOpDelete:
+		j.Repositories.Delete(entry.Name)
+	case walOpDeleteRepositories:
+		for _, name := range entry.Names {
+			j.Repositories.Delete(name)
+		}
+	case walOpMove:
+		j.Repositories.Move(entry.Name, entry.Path)
+	case walOpUpdateMove:
+		if entry.Repository != nil {
+			j.Repositories.UpdateAndMove(entry.Repository, entry.Path)
+		}
+	case walOpSync:
+		j.Repositories.ApplySync(entry.SyncItems)
+	case walOpCreateFolder:
+		j.Repositories.CreateFolder(entry.Path)
+	case walOpRenameFolder:
+		if j.Repositories.RenameFolder(entry.Path, entry.Name) == nil {
+			j.Common.MoveSharedFolder(entry.Path, entry.Path[:len(entry.Path)-1], entry.Name)
+		}
+	case walOpMoveFolder:
+		if j.Repositories.MoveFolder(entry.Path, entry.Parent, "") == nil {
+			j.Common.MoveSharedFolder(entry.Path, entry.Parent, "")
+		}
+	case walOpDeleteFolder:
+		if j.Repositories.DeleteFolder(entry.Path, entry.Cascade) == nil {
+			j.Common.DeleteSharedFolder(entry.Path)
+		}
+	case walOpMergeTags:
+		j.Repositories.MergeTags(entry.Tags, entry.Name)
+	case walOpDeleteTag:
+		j.Repositories.DeleteTag(entry.Name)
+	case walOpUpdateTags:
+		j.Repositories.UpdateTags(entry.Names, entry.Tags, entry.RemoveTags)
+	default:
+		log.Warnw("ignore unknown wal entry", "op", entry.Op)
+	}
+}
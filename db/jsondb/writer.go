@@ -0,0 +1,130 @@
+package jsondb
+
+import (
+	"sync"
+	"time"
+
+	"github.com/fs714/github-star-manager/pkg/utils/log"
+)
+
+// writer is the only goroutine writing db file when write delay is enabled, changes
+// notified within delay are coalesced into one write.
+type writer struct {
+	j       *JsonConfig
+	delay   time.Duration
+	notify  chan struct{}
+	flush   chan chan error
+	closing chan struct{}
+	wg      sync.WaitGroup
+	once    sync.Once
+	err     error
+}
+
+func newWriter(j *JsonConfig, delay time.Duration) *writer {
+	w := &writer{
+		j:       j,
+		delay:   delay,
+		notify:  make(chan struct{}, 1),
+		flush:   make(chan chan error),
+		closing: make(chan struct{}),
+	}
+
+	w.wg.Add(1)
+	go w.run()
+
+	return w
+}
+
+func (w *writer) run() {
+	defer w.wg.Done()
+
+	var timer *time.Timer
+	var timerCh <-chan time.Time
+	dirty := false
+
+	for {
+		select {
+		case <-w.notify:
+			dirty = true
+			if timer == nil {
+				timer = time.NewTimer(w.delay)
+				timerCh = timer.C
+			}
+		case <-timerCh:
+			timer = nil
+			timerCh = nil
+			if dirty {
+				dirty = false
+				err := w.write()
+				if err != nil {
+					log.Errorf("failed to write db file:\n%+v", err)
+					// keep changes dirty and retry on next notify or flush
+					dirty = true
+				}
+			}
+		case ch := <-w.flush:
+			err := w.drain(&dirty)
+			ch <- err
+		case <-w.closing:
+			if timer != nil {
+				timer.Stop()
+			}
+			w.err = w.drain(&dirty)
+			return
+		}
+	}
+}
+
+func (w *writer) drain(dirty *bool) error {
+	select {
+	case <-w.notify:
+		*dirty = true
+	default:
+	}
+
+	if !*dirty {
+		return nil
+	}
+
+	err := w.write()
+	if err != nil {
+		return err
+	}
+
+	*dirty = false
+
+	return nil
+}
+
+func (w *writer) write() error {
+	w.j.RLock()
+	defer w.j.RUnlock()
+
+	return w.j.Write()
+}
+
+func (w *writer) Notify() {
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (w *writer) Flush() error {
+	ch := make(chan error, 1)
+	select {
+	case w.flush <- ch:
+		return <-ch
+	case <-w.closing:
+		return nil
+	}
+}
+
+func (w *writer) Close() error {
+	w.once.Do(func() {
+		close(w.closing)
+	})
+	w.wg.Wait()
+
+	return w.err
+}
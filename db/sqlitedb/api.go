@@ -0,0 +1,809 @@
+package sqlitedb
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/fs714/github-star-manager/db/jsondb"
+	"github.com/fs714/github-star-manager/pkg/utils/log"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS common (
+	id INTEGER PRIMARY KEY CHECK (id = 1),
+	data TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS repositories (
+	name TEXT PRIMARY KEY,
+	path TEXT NOT NULL,
+	position INTEGER NOT NULL,
+	data TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS repositories_path_position ON repositories (path, position);
+CREATE TABLE IF NOT EXISTS folders (
+	path TEXT PRIMARY KEY
+);
+CREATE TABLE IF NOT EXISTS sync_history (
+	id TEXT PRIMARY KEY,
+	user TEXT NOT NULL,
+	started_at INTEGER NOT NULL,
+	data TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS sync_history_started_at ON sync_history (started_at);
+`
+
+func InitSqlitedb(path string) (*SqliteConfig, error) {
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, errors.Wrap(err, "open sqlite error")
+	}
+
+	// sqlite only allows one writer, serialize all access through one connection
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(schema)
+	if err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "create schema error")
+	}
+
+	s := &SqliteConfig{
+		Path:         path,
+		Common:       &jsondb.Common{},
+		Repositories: jsondb.NewRepositories(),
+		db:           db,
+	}
+
+	err = s.Read()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// SqliteConfig keeps the whole repositories tree in memory like jsondb.JsonConfig, and only
+// persists changed rows into sqlite.
+type SqliteConfig struct {
+	Path         string
+	Common       *jsondb.Common
+	Repositories *jsondb.Repositories
+	db           *sql.DB
+	sync.RWMutex
+}
+
+func (s *SqliteConfig) Read() error {
+	var commonData string
+	err := s.db.QueryRow("SELECT data FROM common WHERE id = 1").Scan(&commonData)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return errors.Wrap(err, "query common error")
+	}
+
+	if commonData != "" {
+		err = json.Unmarshal([]byte(commonData), s.Common)
+		if err != nil {
+			return errors.Wrap(err, "unmarshal common error")
+		}
+	}
+
+	folderRows, err := s.db.Query("SELECT path FROM folders ORDER BY path")
+	if err != nil {
+		return errors.Wrap(err, "query folders error")
+	}
+	defer folderRows.Close()
+
+	for folderRows.Next() {
+		var pathData string
+		err = folderRows.Scan(&pathData)
+		if err != nil {
+			return errors.Wrap(err, "scan folder error")
+		}
+
+		var path []string
+		err = json.Unmarshal([]byte(pathData), &path)
+		if err != nil {
+			return errors.Wrap(err, "unmarshal folder path error")
+		}
+
+		s.Repositories.CreateFolder(path)
+	}
+
+	err = folderRows.Err()
+	if err != nil {
+		return errors.Wrap(err, "iterate folders error")
+	}
+
+	rows, err := s.db.Query("SELECT path, data FROM repositories ORDER BY path, position")
+	if err != nil {
+		return errors.Wrap(err, "query repositories error")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pathData, repoData string
+		err = rows.Scan(&pathData, &repoData)
+		if err != nil {
+			return errors.Wrap(err, "scan repository error")
+		}
+
+		var path []string
+		err = json.Unmarshal([]byte(pathData), &path)
+		if err != nil {
+			return errors.Wrap(err, "unmarshal repository path error")
+		}
+
+		repo := &jsondb.Repository{}
+		err = json.Unmarshal([]byte(repoData), repo)
+		if err != nil {
+			return errors.Wrap(err, "unmarshal repository error")
+		}
+
+		s.Repositories.AddUnindexed(path, repo)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return errors.Wrap(err, "iterate repositories error")
+	}
+
+	s.Repositories.RebuildIndexes()
+
+	return nil
+}
+
+// Write replaces all rows with the in-memory data in one transaction.
+func (s *SqliteConfig) Write() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "begin transaction error")
+	}
+	defer tx.Rollback()
+
+	err = writeCommon(tx, s.Common)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM repositories")
+	if err != nil {
+		return errors.Wrap(err, "delete repositories error")
+	}
+
+	err = writeRepositories(tx, []string{}, s.Repositories)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM folders")
+	if err != nil {
+		return errors.Wrap(err, "delete folders error")
+	}
+
+	for _, path := range s.Repositories.FolderPaths() {
+		err = writeFolder(tx, path)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.Wrap(err, "commit transaction error")
+	}
+
+	return nil
+}
+
+func (s *SqliteConfig) Close() error {
+	return s.db.Close()
+}
+
+func (s *SqliteConfig) GetGithubToken() string {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.Common.GithubToken
+}
+
+func (s *SqliteConfig) UpdateGithubToken(token string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.Common.GithubToken = token
+
+	return writeCommon(s.db, s.Common)
+}
+
+func (s *SqliteConfig) GetSyncState(user string) *jsondb.SyncState {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.Common.GetSyncState(user)
+}
+
+func (s *SqliteConfig) UpdateSyncState(user string, state *jsondb.SyncState) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.Common.UpdateSyncState(user, state)
+
+	return writeCommon(s.db, s.Common)
+}
+
+func (s *SqliteConfig) GetAPITokens() []*jsondb.APIToken {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.Common.GetAPITokens()
+}
+
+func (s *SqliteConfig) GetAPITokenByHash(hash string) *jsondb.APIToken {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.Common.GetAPITokenByHash(hash)
+}
+
+func (s *SqliteConfig) AddAPIToken(token *jsondb.APIToken) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.Common.AddAPIToken(token)
+
+	return writeCommon(s.db, s.Common)
+}
+
+func (s *SqliteConfig) DeleteAPIToken(id string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	err := s.Common.DeleteAPIToken(id)
+	if err != nil {
+		return err
+	}
+
+	return writeCommon(s.db, s.Common)
+}
+
+func (s *SqliteConfig) UpdateAPITokenLastUsed(id string, at int64) error {
+	s.Lock()
+	defer s.Unlock()
+
+	err := s.Common.UpdateAPITokenLastUsed(id, at)
+	if err != nil {
+		return err
+	}
+
+	return writeCommon(s.db, s.Common)
+}
+
+func (s *SqliteConfig) GetShares() []*jsondb.Share {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.Common.GetShares()
+}
+
+func (s *SqliteConfig) GetShareByHash(hash string) *jsondb.Share {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.Common.GetShareByHash(hash)
+}
+
+func (s *SqliteConfig) AddShare(share *jsondb.Share) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.Common.AddShare(share)
+
+	return writeCommon(s.db, s.Common)
+}
+
+func (s *SqliteConfig) DeleteShare(id string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	err := s.Common.DeleteShare(id)
+	if err != nil {
+		return err
+	}
+
+	return writeCommon(s.db, s.Common)
+}
+
+// AddSyncHistory inserts history and drops the oldest rows exceeding jsondb.MaxSyncHistory.
+func (s *SqliteConfig) AddSyncHistory(history *jsondb.SyncHistory) error {
+	s.Lock()
+	defer s.Unlock()
+
+	data, err := json.Marshal(history)
+	if err != nil {
+		return errors.Wrap(err, "marshal sync history error")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "begin transaction error")
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("INSERT INTO sync_history (id, user, started_at, data) VALUES (?, ?, ?, ?) ON CONFLICT (id) DO NOTHING",
+		history.ID, history.User, history.StartedAt, string(data))
+	if err != nil {
+		return errors.Wrap(err, "write sync history error")
+	}
+
+	_, err = tx.Exec(`DELETE FROM sync_history WHERE id NOT IN
+		(SELECT id FROM sync_history ORDER BY started_at DESC, rowid DESC LIMIT ?)`, jsondb.MaxSyncHistory)
+	if err != nil {
+		return errors.Wrap(err, "delete old sync history error")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.Wrap(err, "commit transaction error")
+	}
+
+	return nil
+}
+
+func (s *SqliteConfig) GetSyncHistory(user string, limit int) []*jsondb.SyncHistory {
+	s.RLock()
+	defer s.RUnlock()
+
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rows, err := s.db.Query(`SELECT data FROM sync_history WHERE ? = '' OR user = ? COLLATE NOCASE
+		ORDER BY started_at DESC, rowid DESC LIMIT ?`, user, user, limit)
+	if err != nil {
+		log.Errorf("query sync history error:\n%+v", errors.WithStack(err))
+		return []*jsondb.SyncHistory{}
+	}
+	defer rows.Close()
+
+	histories := make([]*jsondb.SyncHistory, 0)
+	for rows.Next() {
+		h, err := scanSyncHistory(rows)
+		if err != nil {
+			log.Errorf("scan sync history error:\n%+v", err)
+			continue
+		}
+
+		histories = append(histories, h)
+	}
+
+	return histories
+}
+
+func (s *SqliteConfig) GetSyncHistoryByID(id string) *jsondb.SyncHistory {
+	s.RLock()
+	defer s.RUnlock()
+
+	h, err := scanSyncHistory(s.db.QueryRow("SELECT data FROM sync_history WHERE id = ?", id))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Errorf("query sync history error:\n%+v", err)
+		}
+		return nil
+	}
+
+	return h
+}
+
+func (s *SqliteConfig) LoadRepositories(repos *jsondb.Repositories) error {
+	s.Lock()
+	defer s.Unlock()
+
+	repos.RebuildIndexes()
+	s.Repositories = repos
+
+	return s.Write()
+}
+
+// AddRepository writes rows in one transaction before the tree is changed, so a failed write
+// changes neither of them.
+func (s *SqliteConfig) AddRepository(path []string, repo *jsondb.Repository) error {
+	s.Lock()
+	defer s.Unlock()
+
+	// a previous name of another repository can be reused, so only current name and id conflict
+	if _, _, r := s.Repositories.GetRepositoryByName(repo.Name); r != nil && r.Name == repo.Name {
+		return jsondb.ErrRepositoryExists
+	}
+
+	if repo.ID != 0 {
+		if _, _, r := s.Repositories.GetRepositoryByID(repo.ID); r != nil {
+			return jsondb.ErrRepositoryExists
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "begin transaction error")
+	}
+	defer tx.Rollback()
+
+	err = writeFolder(tx, path)
+	if err != nil {
+		return err
+	}
+
+	err = writeRepository(tx, path, s.nextPosition(path), repo)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.Wrap(err, "commit transaction error")
+	}
+
+	s.Repositories.Add(path, repo)
+
+	return nil
+}
+
+// nextPosition returns position of repository added to folder path.
+func (s *SqliteConfig) nextPosition(path []string) int {
+	folder := s.Repositories.Get(path)
+	if folder == nil {
+		return 0
+	}
+
+	return len(folder.Repositories)
+}
+
+func (s *SqliteConfig) GetRepositories(path []string) *jsondb.Repositories {
+	return s.Repositories.Get(path)
+}
+
+func (s *SqliteConfig) GetAllRepositoryByPath(path []string) []*jsondb.Repository {
+	return s.Repositories.GetAllRepositoryByPath(path)
+}
+
+func (s *SqliteConfig) GetAllRepositoryByTag(tag string) []*jsondb.Repository {
+	return s.Repositories.GetAllRepositoryByTag(tag)
+}
+
+func (s *SqliteConfig) QueryRepositories(q *jsondb.RepositoryQuery) (*jsondb.RepositoryQueryResult, error) {
+	return s.Repositories.Query(q)
+}
+
+func (s *SqliteConfig) Search(query string, filter *jsondb.RepositoryQuery, limit int) []*jsondb.SearchResult {
+	return s.Repositories.Search(query, filter, limit)
+}
+
+func (s *SqliteConfig) GetAllTag() []string {
+	return s.Repositories.GetAllTag()
+}
+
+func (s *SqliteConfig) GetAllRepositoryByName(name string) ([]string, int, *jsondb.Repository) {
+	return s.Repositories.GetRepositoryByName(name)
+}
+
+func (s *SqliteConfig) GetRepositoryByID(id int64) ([]string, int, *jsondb.Repository) {
+	return s.Repositories.GetRepositoryByID(id)
+}
+
+func (s *SqliteConfig) UpdateRepository(repo *jsondb.Repository) error {
+	s.Lock()
+	defer s.Unlock()
+
+	err := s.Repositories.Update(repo)
+	if err != nil {
+		return err
+	}
+
+	path, idx, _ := s.Repositories.GetRepositoryByName(repo.Name)
+
+	return writeRepository(s.db, path, idx, repo)
+}
+
+func (s *SqliteConfig) MoveRepository(name string, path []string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	oldPath, oldIdx, _ := s.Repositories.GetRepositoryByName(name)
+
+	err := s.Repositories.Move(name, path)
+	if err != nil {
+		return err
+	}
+
+	return s.writeMovedRepository(oldPath, oldIdx, name)
+}
+
+func (s *SqliteConfig) UpdateAndMoveRepository(repo *jsondb.Repository, path []string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	oldPath, oldIdx, _ := s.Repositories.GetRepositoryByName(repo.Name)
+
+	err := s.Repositories.UpdateAndMove(repo, path)
+	if err != nil {
+		return err
+	}
+
+	return s.writeMovedRepository(oldPath, oldIdx, repo.Name)
+}
+
+// ApplySync changes path of many repositories, so it is persisted by rewriting all rows like
+// folder operations.
+func (s *SqliteConfig) ApplySync(items []*jsondb.SyncItem) ([]string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	skipped := s.Repositories.ApplySync(items)
+
+	return skipped, s.Write()
+}
+
+// writeMovedRepository replaces row of repository at old position with its new position and
+// data in one transaction.
+func (s *SqliteConfig) writeMovedRepository(oldPath []string, oldIdx int, name string) error {
+	newPath, newIdx, repo := s.Repositories.GetRepositoryByName(name)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "begin transaction error")
+	}
+	defer tx.Rollback()
+
+	err = deleteRepository(tx, oldPath, oldIdx, name)
+	if err != nil {
+		return err
+	}
+
+	err = writeFolder(tx, newPath)
+	if err != nil {
+		return err
+	}
+
+	err = writeRepository(tx, newPath, newIdx, repo)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.Wrap(err, "commit transaction error")
+	}
+
+	return nil
+}
+
+func (s *SqliteConfig) GetFolderTree() *jsondb.Folder {
+	return s.Repositories.GetFolderTree()
+}
+
+func (s *SqliteConfig) CreateFolder(path []string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	err := s.Repositories.CreateFolder(path)
+	if err != nil {
+		return err
+	}
+
+	return writeFolder(s.db, path)
+}
+
+// folder operations change path of many repositories, so they are persisted by rewriting all rows
+
+func (s *SqliteConfig) RenameFolder(path []string, name string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	err := s.Repositories.RenameFolder(path, name)
+	if err != nil {
+		return err
+	}
+	s.Common.MoveSharedFolder(path, path[:len(path)-1], name)
+
+	return s.Write()
+}
+
+func (s *SqliteConfig) MoveFolder(path []string, parent []string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	err := s.Repositories.MoveFolder(path, parent, "")
+	if err != nil {
+		return err
+	}
+	s.Common.MoveSharedFolder(path, parent, "")
+
+	return s.Write()
+}
+
+func (s *SqliteConfig) DeleteFolder(path []string, cascade bool) error {
+	s.Lock()
+	defer s.Unlock()
+
+	err := s.Repositories.DeleteFolder(path, cascade)
+	if err != nil {
+		return err
+	}
+	s.Common.DeleteSharedFolder(path)
+
+	return s.Write()
+}
+
+func (s *SqliteConfig) GetTagCounts() []*jsondb.TagCount {
+	return s.Repositories.GetTagCounts()
+}
+
+func (s *SqliteConfig) GetAccountCounts() []*jsondb.AccountCount {
+	return s.Repositories.GetAccountCounts()
+}
+
+func (s *SqliteConfig) RenameTag(tag string, name string) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.Repositories.HasTag(tag) {
+		return 0, jsondb.ErrTagNotFound
+	}
+
+	changed := s.Repositories.RenameTag(tag, name)
+
+	return len(changed), s.writeChanged(changed)
+}
+
+func (s *SqliteConfig) MergeTags(tags []string, target string) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	changed := s.Repositories.MergeTags(tags, target)
+
+	return len(changed), s.writeChanged(changed)
+}
+
+func (s *SqliteConfig) DeleteTag(tag string) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.Repositories.HasTag(tag) {
+		return 0, jsondb.ErrTagNotFound
+	}
+
+	changed := s.Repositories.DeleteTag(tag)
+
+	return len(changed), s.writeChanged(changed)
+}
+
+func (s *SqliteConfig) UpdateRepositoriesTags(names []string, add []string, remove []string) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	changed, err := s.Repositories.UpdateTags(names, add, remove)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(changed), s.writeChanged(changed)
+}
+
+// writeChanged writes repositories with names in one transaction.
+func (s *SqliteConfig) writeChanged(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "begin transaction error")
+	}
+	defer tx.Rollback()
+
+	for _, name := range names {
+		path, idx, repo := s.Repositories.GetRepositoryByName(name)
+		if repo == nil {
+			continue
+		}
+
+		err = writeRepository(tx, path, idx, repo)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.Wrap(err, "commit transaction error")
+	}
+
+	return nil
+}
+
+func (s *SqliteConfig) CheckIntegrity(repair bool) ([]string, []string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	problems, remaining := s.Repositories.CheckIndexes(repair)
+
+	return problems, remaining, nil
+}
+
+func (s *SqliteConfig) DeleteRepository(name string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	path, idx, repo := s.Repositories.GetRepositoryByName(name)
+	if repo == nil || repo.Name != name {
+		return nil
+	}
+
+	err := deleteRepository(s.db, path, idx, name)
+	if err != nil {
+		return err
+	}
+
+	s.Repositories.Delete(name)
+
+	return nil
+}
+
+func (s *SqliteConfig) DeleteRepositories(names []string) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, errors.Wrap(err, "begin transaction error")
+	}
+	defer tx.Rollback()
+
+	type target struct {
+		path []string
+		idx  int
+		name string
+	}
+
+	targets := make([]target, 0, len(names))
+	seen := make(map[string]bool)
+	for _, name := range names {
+		path, idx, repo := s.Repositories.GetRepositoryByName(name)
+		if repo == nil || repo.Name != name || seen[name] {
+			continue
+		}
+
+		seen[name] = true
+		targets = append(targets, target{path: path, idx: idx, name: name})
+	}
+
+	// rows are deleted before the tree, and every deletion shifts positions after it in the
+	// folder, so they are deleted from the last position to keep positions of others valid
+	sort.SliceStable(targets, func(i, j int) bool {
+		return targets[i].idx > targets[j].idx
+	})
+
+	deleted := make([]string, 0, len(targets))
+	for _, t := range targets {
+		err = deleteRepository(tx, t.path, t.idx, t.name)
+		if err != nil {
+			return 0, err
+		}
+
+		deleted = append(deleted, t.name)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, errors.Wrap(err, "commit transaction error")
+	}
+
+	for _, name := range deleted {
+		s.Repositories.Delete(name)
+	}
+
+	return len(deleted), nil
+}
@@ -0,0 +1,143 @@
+package sqlitedb
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/fs714/github-star-manager/db/jsondb"
+	"github.com/pkg/errors"
+)
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func writeCommon(e execer, common *jsondb.Common) error {
+	data, err := json.Marshal(common)
+	if err != nil {
+		return errors.Wrap(err, "marshal common error")
+	}
+
+	_, err = e.Exec("INSERT INTO common (id, data) VALUES (1, ?) ON CONFLICT (id) DO UPDATE SET data = excluded.data",
+		string(data))
+	if err != nil {
+		return errors.Wrap(err, "write common error")
+	}
+
+	return nil
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSyncHistory(row scanner) (*jsondb.SyncHistory, error) {
+	var data string
+	err := row.Scan(&data)
+	if err != nil {
+		return nil, errors.Wrap(err, "scan sync history error")
+	}
+
+	h := &jsondb.SyncHistory{}
+	err = json.Unmarshal([]byte(data), h)
+	if err != nil {
+		return nil, errors.Wrap(err, "unmarshal sync history error")
+	}
+
+	return h, nil
+}
+
+func marshalPath(path []string) (string, error) {
+	if path == nil {
+		path = []string{}
+	}
+
+	data, err := json.Marshal(path)
+	if err != nil {
+		return "", errors.Wrap(err, "marshal repository path error")
+	}
+
+	return string(data), nil
+}
+
+func writeRepository(e execer, path []string, position int, repo *jsondb.Repository) error {
+	pathData, err := marshalPath(path)
+	if err != nil {
+		return err
+	}
+
+	repoData, err := json.Marshal(repo)
+	if err != nil {
+		return errors.Wrap(err, "marshal repository error")
+	}
+
+	_, err = e.Exec(`INSERT INTO repositories (name, path, position, data) VALUES (?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET path = excluded.path, position = excluded.position, data = excluded.data`,
+		repo.Name, pathData, position, string(repoData))
+	if err != nil {
+		return errors.Wrap(err, "write repository error")
+	}
+
+	return nil
+}
+
+func writeRepositories(e execer, path []string, repos *jsondb.Repositories) error {
+	for idx, r := range repos.Repositories {
+		err := writeRepository(e, path, idx, r)
+		if err != nil {
+			return err
+		}
+	}
+
+	for k, v := range repos.SubRepositories {
+		subPath := make([]string, 0, len(path)+1)
+		subPath = append(subPath, path...)
+		subPath = append(subPath, k)
+
+		err := writeRepositories(e, subPath, v)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func deleteRepository(e execer, path []string, position int, name string) error {
+	pathData, err := marshalPath(path)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.Exec("DELETE FROM repositories WHERE name = ?", name)
+	if err != nil {
+		return errors.Wrap(err, "delete repository error")
+	}
+
+	_, err = e.Exec("UPDATE repositories SET position = position - 1 WHERE path = ? AND position > ?",
+		pathData, position)
+	if err != nil {
+		return errors.Wrap(err, "update repository position error")
+	}
+
+	return nil
+}
+
+// writeFolder records path and all its parents, so empty folders survive reload.
+func writeFolder(e execer, path []string) error {
+	for i := 1; i <= len(path); i++ {
+		pathData, err := marshalPath(path[:i])
+		if err != nil {
+			return err
+		}
+
+		_, err = e.Exec("INSERT INTO folders (path) VALUES (?) ON CONFLICT (path) DO NOTHING", pathData)
+		if err != nil {
+			return errors.Wrap(err, "write folder error")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package db
+
+import (
+	"time"
+
+	"github.com/fs714/github-star-manager/db/jsondb"
+	"github.com/fs714/github-star-manager/db/sqlitedb"
+	"github.com/fs714/github-star-manager/pkg/config"
+	"github.com/pkg/errors"
+)
+
+const (
+	DriverJson   = "json"
+	DriverSqlite = "sqlite"
+)
+
+var Storage Store
+
+// Store is implemented by every storage backend, repositories are always organized as
+// jsondb.Repositories tree no matter how they are persisted.
+type Store interface {
+	GetGithubToken() string
+	UpdateGithubToken(token string) error
+	GetSyncState(user string) *jsondb.SyncState
+	UpdateSyncState(user string, state *jsondb.SyncState) error
+	// api tokens are kept hashed, tokens returned are copies
+	GetAPITokens() []*jsondb.APIToken
+	GetAPITokenByHash(hash string) *jsondb.APIToken
+	AddAPIToken(token *jsondb.APIToken) error
+	DeleteAPIToken(id string) error
+	UpdateAPITokenLastUsed(id string, at int64) error
+	// shares are kept hashed like api tokens
+	GetShares() []*jsondb.Share
+	GetShareByHash(hash string) *jsondb.Share
+	AddShare(share *jsondb.Share) error
+	DeleteShare(id string) error
+	// sync histories are returned newest first, only jsondb.MaxSyncHistory newest ones are kept
+	AddSyncHistory(history *jsondb.SyncHistory) error
+	GetSyncHistory(user string, limit int) []*jsondb.SyncHistory
+	GetSyncHistoryByID(id string) *jsondb.SyncHistory
+	LoadRepositories(repos *jsondb.Repositories) error
+	AddRepository(path []string, repo *jsondb.Repository) error
+	GetRepositories(path []string) *jsondb.Repositories
+	GetAllRepositoryByPath(path []string) []*jsondb.Repository
+	GetAllRepositoryByTag(tag string) []*jsondb.Repository
+	GetAllTag() []string
+	QueryRepositories(q *jsondb.RepositoryQuery) (*jsondb.RepositoryQueryResult, error)
+	// Search ignores sort and paging of filter, nil filter matches all repositories
+	Search(query string, filter *jsondb.RepositoryQuery, limit int) []*jsondb.SearchResult
+	GetAllRepositoryByName(name string) ([]string, int, *jsondb.Repository)
+	GetRepositoryByID(id int64) ([]string, int, *jsondb.Repository)
+	UpdateRepository(repo *jsondb.Repository) error
+	DeleteRepository(name string) error
+	// DeleteRepositories returns the number of deleted repositories, which are persisted in one write
+	DeleteRepositories(names []string) (int, error)
+	MoveRepository(name string, path []string) error
+	// UpdateAndMoveRepository updates repository and moves it to folder path in one write
+	UpdateAndMoveRepository(repo *jsondb.Repository, path []string) error
+	// ApplySync merges repositories saved by sync in one write and returns names of skipped ones,
+	// see jsondb.Repositories.ApplySync
+	ApplySync(items []*jsondb.SyncItem) ([]string, error)
+	GetFolderTree() *jsondb.Folder
+	CreateFolder(path []string) error
+	RenameFolder(path []string, name string) error
+	MoveFolder(path []string, parent []string) error
+	DeleteFolder(path []string, cascade bool) error
+	GetTagCounts() []*jsondb.TagCount
+	GetAccountCounts() []*jsondb.AccountCount
+	// tag operations return the number of changed repositories, which are persisted in one write.
+	// RenameTag and DeleteTag return jsondb.ErrTagNotFound if no repository has the tag.
+	RenameTag(tag string, name string) (int, error)
+	MergeTags(tags []string, target string) (int, error)
+	DeleteTag(tag string) (int, error)
+	UpdateRepositoriesTags(names []string, add []string, remove []string) (int, error)
+	// CheckIntegrity returns drift between in-memory indexes and stored repositories, and problems
+	// remaining after indexes are rebuilt if repair is true, see jsondb.Repositories.CheckIndexes
+	CheckIntegrity(repair bool) ([]string, []string, error)
+	Close() error
+}
+
+func InitStoreFromConfig() (err error) {
+	Storage, err = InitStore(config.Config.Database)
+	if err != nil {
+		return errors.WithMessage(err, "failed to init db from config")
+	}
+
+	return
+}
+
+func InitStore(cfg config.Database) (Store, error) {
+	switch cfg.Driver {
+	case DriverJson, "":
+		j, err := jsondb.InitJsondb(cfg.Path, jsondb.Options{
+			WriteDelay: time.Duration(cfg.WriteDelay) * time.Millisecond,
+			WAL:        cfg.WAL,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return j, nil
+	case DriverSqlite:
+		s, err := sqlitedb.InitSqlitedb(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		return s, nil
+	default:
+		return nil, errors.Errorf("unsupported database driver: %s", cfg.Driver)
+	}
+}
@@ -0,0 +1,448 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/fs714/github-star-manager/db/jsondb"
+	"github.com/fs714/github-star-manager/pkg/config"
+)
+
+func TestJsonStore(t *testing.T) {
+	runStoreTests(t, config.Database{Driver: DriverJson, Path: filepath.Join(t.TempDir(), "db.json")})
+}
+
+func TestJsonStoreWithWriteDelay(t *testing.T) {
+	runStoreTests(t, config.Database{Driver: DriverJson, Path: filepath.Join(t.TempDir(), "db.json"),
+		WriteDelay: 100, WAL: true})
+}
+
+func TestSqliteStore(t *testing.T) {
+	runStoreTests(t, config.Database{Driver: DriverSqlite, Path: filepath.Join(t.TempDir(), "db.sqlite")})
+}
+
+func runStoreTests(t *testing.T, cfg config.Database) {
+	s, err := InitStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Token", func(t *testing.T) {
+		testStoreToken(t, s)
+	})
+
+	t.Run("Repository", func(t *testing.T) {
+		testStoreRepository(t, s)
+	})
+
+	t.Run("Folder", func(t *testing.T) {
+		testStoreFolder(t, s)
+	})
+
+	t.Run("Tag", func(t *testing.T) {
+		testStoreTag(t, s)
+	})
+
+	err = s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = InitStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	t.Run("Reopen", func(t *testing.T) {
+		testStoreReopen(t, s)
+	})
+}
+
+func newTestRepository(name string, tags ...string) *jsondb.Repository {
+	return &jsondb.Repository{
+		Name:       name,
+		Url:        "https://github.com/" + name,
+		Language:   "go",
+		StarsCount: 100,
+		ForksCount: 10,
+		Tags:       tags,
+	}
+}
+
+func repositoryNames(repos []*jsondb.Repository) []string {
+	names := make([]string, 0, len(repos))
+	for _, r := range repos {
+		names = append(names, r.Name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func testStoreToken(t *testing.T, s Store) {
+	if token := s.GetGithubToken(); token != "" {
+		t.Fatalf("token = %q, expected empty", token)
+	}
+
+	err := s.UpdateGithubToken("ghp_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token := s.GetGithubToken(); token != "ghp_token" {
+		t.Fatalf("token = %q, expected ghp_token", token)
+	}
+
+	if state := s.GetSyncState("fs714"); state != nil {
+		t.Fatalf("sync state = %+v, expected nil", state)
+	}
+
+	err = s.UpdateSyncState("fs714", &jsondb.SyncState{LastSyncAt: 200, LastFullSyncAt: 100, LastStarredAt: 150})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, user := range []string{"fs714", "other", "FS714"} {
+		diff := jsondb.NewSyncDiff()
+		diff.Added = append(diff.Added, user+"/repo")
+		err = s.AddSyncHistory(&jsondb.SyncHistory{ID: user + "-sync", User: user, StartedAt: int64(100 + i), Diff: diff})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	histories := s.GetSyncHistory("fs714", 0)
+	if len(histories) != 2 || histories[0].ID != "FS714-sync" || histories[1].ID != "fs714-sync" {
+		t.Fatalf("sync histories of fs714 = %+v", histories)
+	}
+
+	if histories = s.GetSyncHistory("", 1); len(histories) != 1 || histories[0].ID != "FS714-sync" {
+		t.Errorf("latest sync history = %+v", histories)
+	}
+}
+
+func testStoreRepository(t *testing.T, s Store) {
+	repos := jsondb.NewRepositories()
+	repos.Add([]string{}, newTestRepository("a/unclassified"))
+	repos.Add([]string{"linux"}, newTestRepository("a/linux", "linux"))
+	err := s.LoadRepositories(repos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.AddRepository([]string{"linux", "ebpf"}, newTestRepository("a/ebpf", "linux", "ebpf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.AddRepository([]string{"ai"}, newTestRepository("a/ai", "ai"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := repositoryNames(s.GetAllRepositoryByPath([]string{"linux"}))
+	if !equalStrings(names, []string{"a/ebpf", "a/linux"}) {
+		t.Errorf("repositories by path = %v", names)
+	}
+
+	names = repositoryNames(s.GetAllRepositoryByTag("linux"))
+	if !equalStrings(names, []string{"a/ebpf", "a/linux"}) {
+		t.Errorf("repositories by tag = %v", names)
+	}
+
+	if rs := s.GetRepositories([]string{"linux", "ebpf"}); rs == nil || len(rs.Repositories) != 1 {
+		t.Errorf("repositories of linux/ebpf = %v", rs)
+	}
+
+	path, _, repo := s.GetAllRepositoryByName("a/ebpf")
+	if repo == nil || !equalStrings(path, []string{"linux", "ebpf"}) {
+		t.Fatalf("repository by name = %v, path = %v", repo, path)
+	}
+
+	updated := *repo
+	updated.Tags = []string{"linux", "ebpf", "network"}
+	updated.StarsCount = 200
+	err = s.UpdateRepository(&updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, repo = s.GetAllRepositoryByName("a/ebpf"); repo == nil || repo.StarsCount != 200 {
+		t.Errorf("updated repository = %v", repo)
+	}
+
+	err = s.UpdateRepository(newTestRepository("a/missing"))
+	if err == nil {
+		t.Error("expected error when updating missing repository")
+	}
+
+	err = s.AddRepository([]string{}, newTestRepository("a/ebpf"))
+	if !errors.Is(err, jsondb.ErrRepositoryExists) {
+		t.Errorf("add existing repository returns %v, expected ErrRepositoryExists", err)
+	}
+
+	err = s.MoveRepository("a/unclassified", []string{"misc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if path, _, _ := s.GetAllRepositoryByName("a/unclassified"); !equalStrings(path, []string{"misc"}) {
+		t.Errorf("moved repository is at %v", path)
+	}
+
+	_, _, repo = s.GetAllRepositoryByName("a/unclassified")
+	moved := *repo
+	moved.Note = "moved"
+	err = s.UpdateAndMoveRepository(&moved, []string{"misc", "sub"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if path, _, repo := s.GetAllRepositoryByName("a/unclassified"); repo.Note != "moved" ||
+		!equalStrings(path, []string{"misc", "sub"}) {
+		t.Errorf("updated and moved repository = %v at %v", repo, path)
+	}
+
+	err = s.UpdateAndMoveRepository(newTestRepository("a/missing"), []string{"misc"})
+	if !errors.Is(err, jsondb.ErrRepositoryNotFound) {
+		t.Errorf("update and move missing repository returns %v, expected ErrRepositoryNotFound", err)
+	}
+
+	err = s.DeleteRepository("a/ai")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, repo = s.GetAllRepositoryByName("a/ai"); repo != nil {
+		t.Errorf("deleted repository still exists: %v", repo)
+	}
+
+	for _, name := range []string{"a/x", "a/y", "a/z"} {
+		err = s.AddRepository([]string{"bulk"}, newTestRepository(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count, err := s.DeleteRepositories([]string{"a/x", "a/missing", "a/z"})
+	if err != nil || count != 2 {
+		t.Fatalf("bulk delete returns %d, %v", count, err)
+	}
+
+	if names = repositoryNames(s.GetAllRepositoryByPath([]string{"bulk"})); !equalStrings(names, []string{"a/y"}) {
+		t.Errorf("repositories after bulk delete = %v", names)
+	}
+
+	_, _, repo = s.GetAllRepositoryByName("a/y")
+	noted := *repo
+	noted.Note = "kept"
+	err = s.UpdateRepository(&noted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renamed := newTestRepository("a/renamed")
+	renamed.PreviousNames = []string{"a/y"}
+	skipped, err := s.ApplySync([]*jsondb.SyncItem{
+		{Name: "a/y", ExistPath: []string{"bulk"}, Path: []string{"bulk"}, Repository: renamed},
+		{Name: "a/renamed", Repository: newTestRepository("a/ebpf")},
+		{Repository: newTestRepository("a/new")},
+	})
+	if err != nil || !equalStrings(skipped, []string{"a/ebpf"}) {
+		t.Fatalf("apply sync returns %v, %v", skipped, err)
+	}
+
+	if path, _, repo := s.GetAllRepositoryByName("a/y"); repo == nil || repo.Name != "a/renamed" ||
+		repo.Note != "kept" || !equalStrings(path, []string{"bulk"}) {
+		t.Errorf("renamed repository = %v at %v", repo, path)
+	}
+
+	skipped, err = s.ApplySync([]*jsondb.SyncItem{
+		{Name: "a/renamed", Delete: true},
+		{Name: "a/new", Delete: true},
+	})
+	if err != nil || len(skipped) != 0 {
+		t.Fatalf("apply sync returns %v, %v", skipped, err)
+	}
+
+	if names = repositoryNames(s.GetAllRepositoryByPath([]string{"bulk"})); len(names) != 0 {
+		t.Errorf("repositories after sync deletes = %v", names)
+	}
+
+	err = s.DeleteFolder([]string{"bulk"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testStoreFolder(t *testing.T, s Store) {
+	err := s.CreateFolder([]string{"empty", "child"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.AddRepository([]string{"tmp", "sub"}, newTestRepository("a/tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, share := range []*jsondb.Share{
+		{ID: "moved", Hash: "moved", Path: []string{"tmp", "sub"}},
+		{ID: "kept", Hash: "kept", Path: []string{"empty", "child"}},
+	} {
+		err = s.AddShare(share)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = s.MoveFolder([]string{"tmp", "sub"}, []string{"linux"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.RenameFolder([]string{"linux", "sub"}, "renamed")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if path, _, _ := s.GetAllRepositoryByName("a/tmp"); !equalStrings(path, []string{"linux", "renamed"}) {
+		t.Errorf("repository in moved folder is at %v", path)
+	}
+
+	if share := s.GetShareByHash("moved"); share == nil || !equalStrings(share.Path, []string{"linux", "renamed"}) {
+		t.Errorf("share of moved folder is %+v", share)
+	}
+
+	err = s.DeleteFolder([]string{"linux", "renamed"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.DeleteFolder([]string{"tmp"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testStoreTag(t *testing.T, s Store) {
+	count, err := s.UpdateRepositoriesTags([]string{"a/unclassified", "a/linux"}, []string{"tmp"}, nil)
+	if err != nil || count != 2 {
+		t.Fatalf("bulk update tags returns %d, %v", count, err)
+	}
+
+	count, err = s.RenameTag("tmp", "temp")
+	if err != nil || count != 2 {
+		t.Fatalf("rename tag returns %d, %v", count, err)
+	}
+
+	count, err = s.MergeTags([]string{"temp"}, "misc")
+	if err != nil || count != 2 {
+		t.Fatalf("merge tags returns %d, %v", count, err)
+	}
+
+	count, err = s.DeleteTag("misc")
+	if err != nil || count != 2 {
+		t.Fatalf("delete tag returns %d, %v", count, err)
+	}
+
+	if _, err = s.RenameTag("misc", "other"); !errors.Is(err, jsondb.ErrTagNotFound) {
+		t.Errorf("rename missing tag returns %v, expected ErrTagNotFound", err)
+	}
+
+	if _, err = s.DeleteTag("misc"); !errors.Is(err, jsondb.ErrTagNotFound) {
+		t.Errorf("delete missing tag returns %v, expected ErrTagNotFound", err)
+	}
+
+	for _, tc := range s.GetTagCounts() {
+		if tc.Name == "misc" || tc.Name == "temp" || tc.Name == "tmp" {
+			t.Errorf("tag %s still exists", tc.Name)
+		}
+	}
+}
+
+func testStoreReopen(t *testing.T, s Store) {
+	if token := s.GetGithubToken(); token != "ghp_token" {
+		t.Errorf("token after reopen = %q, expected ghp_token", token)
+	}
+
+	if state := s.GetSyncState("fs714"); state == nil || state.LastStarredAt != 150 || state.LastFullSyncAt != 100 {
+		t.Errorf("sync state after reopen = %+v", state)
+	}
+
+	if h := s.GetSyncHistoryByID("other-sync"); h == nil || h.User != "other" || len(h.Diff.Added) != 1 {
+		t.Errorf("sync history after reopen = %+v", h)
+	}
+
+	if h := s.GetSyncHistoryByID("missing"); h != nil {
+		t.Errorf("missing sync history = %+v", h)
+	}
+
+	names := repositoryNames(s.GetAllRepositoryByPath([]string{}))
+	if !equalStrings(names, []string{"a/ebpf", "a/linux", "a/unclassified"}) {
+		t.Errorf("repositories after reopen = %v", names)
+	}
+
+	path, _, repo := s.GetAllRepositoryByName("a/ebpf")
+	if repo == nil || repo.StarsCount != 200 || !equalStrings(path, []string{"linux", "ebpf"}) {
+		t.Errorf("repository after reopen = %v, path = %v", repo, path)
+	}
+
+	names = repositoryNames(s.GetAllRepositoryByTag("linux"))
+	if !equalStrings(names, []string{"a/ebpf", "a/linux"}) {
+		t.Errorf("repositories by tag after reopen = %v", names)
+	}
+
+	if path, _, repo := s.GetAllRepositoryByName("a/unclassified"); repo.Note != "moved" ||
+		!equalStrings(path, []string{"misc", "sub"}) {
+		t.Errorf("moved repository = %v at %v after reopen", repo, path)
+	}
+
+	tags := s.GetAllTag()
+	sort.Strings(tags)
+	if !equalStrings(tags, []string{"ebpf", "linux", "network"}) {
+		t.Errorf("tags after reopen = %v", tags)
+	}
+
+	if s.GetRepositories([]string{"empty", "child"}) == nil {
+		t.Error("empty folder is lost after reopen")
+	}
+
+	if s.GetRepositories([]string{"tmp"}) != nil {
+		t.Error("deleted folder exists after reopen")
+	}
+
+	if _, _, repo := s.GetAllRepositoryByName("a/tmp"); repo != nil {
+		t.Error("repository deleted with folder exists after reopen")
+	}
+
+	if s.GetShareByHash("moved") != nil || s.GetShareByHash("kept") == nil {
+		t.Errorf("shares after reopen = %v", s.GetShares())
+	}
+
+	problems, _, err := s.CheckIntegrity(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(problems) > 0 {
+		t.Errorf("integrity problems after reopen: %v", problems)
+	}
+}
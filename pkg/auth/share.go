@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"strings"
+	"time"
+
+	"github.com/fs714/github-star-manager/db"
+	"github.com/fs714/github-star-manager/db/jsondb"
+	"github.com/pkg/errors"
+)
+
+// ParseShareToken returns user of token of share link, see NewToken.
+func ParseShareToken(token string) (string, error) {
+	return parseToken(sharePrefix, token)
+}
+
+// CreateShare adds share of a folder at path or of tag into store, exactly one of them should
+// be set. The token of share link is only returned here, user is the same as NewToken.
+func CreateShare(store db.Store, user string, name string, path []string, tag string,
+	expiresAt int64) (string, *jsondb.Share, error) {
+	tag = strings.TrimSpace(tag)
+	switch {
+	case len(path) == 0 && tag == "":
+		return "", nil, errors.WithMessage(jsondb.ErrInvalidPath, "folder or tag should be shared")
+	case len(path) != 0 && tag != "":
+		return "", nil, errors.WithMessage(jsondb.ErrInvalidPath, "only one of folder and tag could be shared")
+	case len(path) != 0 && store.GetRepositories(path) == nil:
+		return "", nil, jsondb.ErrPathNotFound
+	}
+
+	token, err := newToken(sharePrefix, user)
+	if err != nil {
+		return "", nil, err
+	}
+
+	id, err := randomString(8)
+	if err != nil {
+		return "", nil, err
+	}
+
+	s := &jsondb.Share{
+		ID:        id,
+		Name:      strings.TrimSpace(name),
+		Path:      path,
+		Tag:       tag,
+		Hash:      HashToken(token),
+		CreatedAt: time.Now().Unix(),
+		ExpiresAt: expiresAt,
+	}
+
+	err = store.AddShare(s)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, s, nil
+}
+
+// AuthenticateShare returns the unexpired share of token in store.
+func AuthenticateShare(store db.Store, token string, now time.Time) (*jsondb.Share, error) {
+	s := store.GetShareByHash(HashToken(token))
+	if s == nil || s.Expired(now) {
+		return nil, ErrUnauthorized
+	}
+
+	return s, nil
+}
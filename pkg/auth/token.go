@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/fs714/github-star-manager/db"
+	"github.com/fs714/github-star-manager/db/jsondb"
+	"github.com/pkg/errors"
+)
+
+const (
+	// prefixes make tokens easy to recognize by secret scanners
+	tokenPrefix = "gsm_"
+	sharePrefix = "gss_"
+
+	tokenSecretLength = 32
+
+	// last used time of token is saved at most once in this interval, so requests do not
+	// rewrite database every time
+	lastUsedInterval = 60
+)
+
+// NewToken returns a random api token of user, the user is empty for the server database which
+// belongs to admin user. Token of other users carries the user name to find their database.
+func NewToken(user string) (string, error) {
+	return newToken(tokenPrefix, user)
+}
+
+// ParseToken returns user of token created by NewToken.
+func ParseToken(token string) (string, error) {
+	return parseToken(tokenPrefix, token)
+}
+
+func newToken(prefix string, user string) (string, error) {
+	secret, err := randomString(tokenSecretLength)
+	if err != nil {
+		return "", err
+	}
+
+	if user == "" {
+		return prefix + secret, nil
+	}
+
+	return prefix + NormalizeName(user) + "_" + secret, nil
+}
+
+func parseToken(prefix string, token string) (string, error) {
+	if !strings.HasPrefix(token, prefix) {
+		return "", ErrUnauthorized
+	}
+
+	rest := strings.TrimPrefix(token, prefix)
+	user, secret := "", rest
+	if i := strings.LastIndex(rest, "_"); i >= 0 {
+		user, secret = rest[:i], rest[i+1:]
+	}
+
+	if len(secret) != tokenSecretLength*2 || (user != "" && !userNameRegexp.MatchString(user)) {
+		return "", ErrUnauthorized
+	}
+
+	return user, nil
+}
+
+// HashToken returns the hash of token which is stored instead of the token, tokens are random
+// enough that a fast hash is fine.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIToken adds a token of user with scopes into store, the token is only returned here.
+// expiresAt is unix seconds, 0 means the token never expires.
+func CreateAPIToken(store db.Store, user string, name string, scopes []string,
+	expiresAt int64) (string, *jsondb.APIToken, error) {
+	err := jsondb.ValidateScopes(scopes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	token, err := NewToken(user)
+	if err != nil {
+		return "", nil, err
+	}
+
+	id, err := randomString(8)
+	if err != nil {
+		return "", nil, err
+	}
+
+	t := &jsondb.APIToken{
+		ID:        id,
+		Name:      strings.TrimSpace(name),
+		Hash:      HashToken(token),
+		Scopes:    scopes,
+		CreatedAt: time.Now().Unix(),
+		ExpiresAt: expiresAt,
+	}
+
+	err = store.AddAPIToken(t)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, t, nil
+}
+
+// AuthenticateAPIToken returns the unexpired token in store and records it is used at now.
+func AuthenticateAPIToken(store db.Store, token string, now time.Time) (*jsondb.APIToken, error) {
+	t := store.GetAPITokenByHash(HashToken(token))
+	if t == nil || t.Expired(now) {
+		return nil, ErrUnauthorized
+	}
+
+	if now.Unix()-t.LastUsedAt >= lastUsedInterval {
+		t.LastUsedAt = now.Unix()
+		err := store.UpdateAPITokenLastUsed(t.ID, t.LastUsedAt)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to update last used time of api token")
+		}
+	}
+
+	return t, nil
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fs714/github-star-manager/db"
+	"github.com/fs714/github-star-manager/db/jsondb"
+	"github.com/fs714/github-star-manager/pkg/config"
+	"github.com/pkg/errors"
+)
+
+func TestParseToken(t *testing.T) {
+	for _, user := range []string{"", "alice", "team_ci"} {
+		token, err := NewToken(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if parsed, err := ParseToken(token); err != nil || parsed != user {
+			t.Errorf("parse token of %q returns %q, %v", user, parsed, err)
+		}
+	}
+
+	for _, token := range []string{"", "ghp_abc", "gsm_short", "gsm_../x_" + strings.Repeat("0", 64)} {
+		if _, err := ParseToken(token); !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("parse token %q returns %v", token, err)
+		}
+	}
+}
+
+func TestAPIToken(t *testing.T) {
+	store, err := db.InitStore(config.Database{Driver: db.DriverJson, Path: filepath.Join(t.TempDir(), "db.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if _, _, err = CreateAPIToken(store, "", "ci", []string{"repo:delete"}, 0); !errors.Is(err, jsondb.ErrInvalidScope) {
+		t.Errorf("create token with unknown scope returns %v", err)
+	}
+
+	now := time.Now()
+	token, created, err := CreateAPIToken(store, "", "ci", []string{jsondb.ScopeRepoWrite}, now.Add(time.Hour).Unix())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if created.Hash == token || !created.HasScope(jsondb.ScopeRepoRead) || created.HasScope(jsondb.ScopeSync) {
+		t.Errorf("created token is %+v", created)
+	}
+
+	got, err := AuthenticateAPIToken(store, token, now)
+	if err != nil || got.ID != created.ID {
+		t.Fatalf("authenticate token returns %+v, %v", got, err)
+	}
+
+	if tokens := store.GetAPITokens(); len(tokens) != 1 || tokens[0].LastUsedAt != now.Unix() {
+		t.Errorf("last used time is not recorded: %+v", tokens)
+	}
+
+	if _, err = AuthenticateAPIToken(store, token, now.Add(2*time.Hour)); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("authenticate expired token returns %v", err)
+	}
+
+	if err = store.DeleteAPIToken(created.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = AuthenticateAPIToken(store, token, now); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("authenticate deleted token returns %v", err)
+	}
+}
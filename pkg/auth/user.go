@@ -0,0 +1,368 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fs714/github-star-manager/pkg/config"
+	"github.com/fs714/github-star-manager/pkg/utils/log"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const minPasswordLength = 8
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrUserExists   = errors.New("user exists")
+	ErrInvalidUser  = errors.New("invalid user")
+	ErrUnauthorized = errors.New("unauthorized")
+)
+
+var (
+	userNameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+	currentUserStore *UserStore
+)
+
+// User is a local account, password is only kept as hash.
+type User struct {
+	Name         string
+	PasswordHash string
+	Admin        bool
+	CreatedAt    int64
+	UpdatedAt    int64
+}
+
+// UserStore keeps users in a json file, every change rewrites the whole file.
+type UserStore struct {
+	path string
+
+	mu    sync.RWMutex
+	users map[string]*User
+}
+
+// NewUserStore loads users from path, a missing file means no users.
+func NewUserStore(path string) (*UserStore, error) {
+	s := &UserStore{
+		path:  path,
+		users: make(map[string]*User),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to read users file")
+	}
+
+	users := make([]*User, 0)
+	err = json.Unmarshal(data, &users)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse users file")
+	}
+
+	for _, u := range users {
+		s.users[u.Name] = u
+	}
+
+	return s, nil
+}
+
+// InitUserStoreFromConfig loads users and creates the admin user if it does not exist.
+func InitUserStoreFromConfig() error {
+	s, err := NewUserStore(config.Config.Auth.UsersPath)
+	if err != nil {
+		return errors.WithMessage(err, "failed to init users from config")
+	}
+
+	name := NormalizeName(config.Config.Auth.AdminUser)
+	if _, err = s.Get(name); errors.Is(err, ErrUserNotFound) {
+		password := config.Config.Auth.AdminPassword
+		generated := password == ""
+		if generated {
+			password, err = randomString(12)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = s.Create(name, password, true)
+		if err != nil {
+			return errors.WithMessage(err, "failed to create admin user")
+		}
+
+		if generated {
+			log.Warnw("admin user is created with random password, change it after login", "user", name,
+				"password", password)
+		} else {
+			log.Infow("admin user is created", "user", name)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	SetUserStore(s)
+
+	return nil
+}
+
+func SetUserStore(s *UserStore) {
+	currentUserStore = s
+}
+
+func CurrentUserStore() *UserStore {
+	return currentUserStore
+}
+
+// NormalizeName returns user name in lower case, names are case insensitive.
+func NormalizeName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// IsServerAdmin reports whether name is the admin user of config, whose data is the server
+// database.
+func IsServerAdmin(name string) bool {
+	return NormalizeName(name) == NormalizeName(config.Config.Auth.AdminUser)
+}
+
+// List returns users sorted by name.
+func (s *UserStore) List() []*User {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]*User, 0, len(s.users))
+	for _, u := range s.users {
+		c := *u
+		users = append(users, &c)
+	}
+
+	sort.Slice(users, func(a, b int) bool {
+		return users[a].Name < users[b].Name
+	})
+
+	return users
+}
+
+func (s *UserStore) Get(name string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[NormalizeName(name)]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+
+	c := *u
+	return &c, nil
+}
+
+func (s *UserStore) Create(name string, password string, admin bool) (*User, error) {
+	name = NormalizeName(name)
+	if !userNameRegexp.MatchString(name) {
+		return nil, errors.WithMessagef(ErrInvalidUser, "invalid user name %q", name)
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[name]; ok {
+		return nil, ErrUserExists
+	}
+
+	now := time.Now().Unix()
+	u := &User{Name: name, PasswordHash: hash, Admin: admin, CreatedAt: now, UpdatedAt: now}
+	s.users[name] = u
+
+	err = s.save()
+	if err != nil {
+		delete(s.users, name)
+		return nil, err
+	}
+
+	c := *u
+	return &c, nil
+}
+
+// Update changes password if it is not nil and admin flag if it is not nil, the last admin can
+// not lose the flag.
+func (s *UserStore) Update(name string, password *string, admin *bool) (*User, error) {
+	hash := ""
+	if password != nil {
+		var err error
+		hash, err = hashPassword(*password)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[NormalizeName(name)]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+
+	if admin != nil && !*admin && u.Admin && s.adminCount() == 1 {
+		return nil, errors.WithMessage(ErrInvalidUser, "the last admin can not be demoted")
+	}
+
+	old := *u
+	if password != nil {
+		u.PasswordHash = hash
+	}
+	if admin != nil {
+		u.Admin = *admin
+	}
+	u.UpdatedAt = time.Now().Unix()
+
+	err := s.save()
+	if err != nil {
+		*u = old
+		return nil, err
+	}
+
+	c := *u
+	return &c, nil
+}
+
+// Delete removes user, the last admin can not be deleted.
+func (s *UserStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name = NormalizeName(name)
+	u, ok := s.users[name]
+	if !ok {
+		return ErrUserNotFound
+	}
+
+	if u.Admin && s.adminCount() == 1 {
+		return errors.WithMessage(ErrInvalidUser, "the last admin can not be deleted")
+	}
+
+	delete(s.users, name)
+
+	err := s.save()
+	if err != nil {
+		s.users[name] = u
+		return err
+	}
+
+	return nil
+}
+
+// Authenticate returns the user if password matches, ErrUnauthorized is returned for both
+// unknown user and wrong password.
+func (s *UserStore) Authenticate(name string, password string) (*User, error) {
+	u, err := s.Get(name)
+	if err != nil {
+		// compare anyway so unknown users take the same time
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, ErrUnauthorized
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password))
+	if err != nil {
+		return nil, ErrUnauthorized
+	}
+
+	return u, nil
+}
+
+// adminCount should be called with lock held.
+func (s *UserStore) adminCount() int {
+	n := 0
+	for _, u := range s.users {
+		if u.Admin {
+			n++
+		}
+	}
+
+	return n
+}
+
+// save writes users into a temp file and renames it, it should be called with lock held.
+func (s *UserStore) save() error {
+	users := make([]*User, 0, len(s.users))
+	for _, u := range s.users {
+		users = append(users, u)
+	}
+
+	sort.Slice(users, func(a, b int) bool {
+		return users[a].Name < users[b].Name
+	})
+
+	data, err := json.MarshalIndent(users, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal users")
+	}
+
+	dir := filepath.Dir(s.path)
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return errors.Wrap(err, "failed to create dir of users file")
+	}
+
+	f, err := os.CreateTemp(dir, filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temp users file")
+	}
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return errors.Wrap(err, "failed to write users file")
+	}
+
+	return nil
+}
+
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", errors.WithMessagef(ErrInvalidUser, "password should have at least %d characters",
+			minPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", errors.WithMessage(ErrInvalidUser, err.Error())
+	}
+
+	return string(hash), nil
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to generate random string")
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestUserStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	s, err := NewUserStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = s.Create("Admin", "password1", true); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name     string
+		password string
+		err      error
+	}{
+		{"admin", "password2", ErrUserExists},
+		{"../alice", "password2", ErrInvalidUser},
+		{"alice", "short", ErrInvalidUser},
+	} {
+		if _, err = s.Create(c.name, c.password, false); !errors.Is(err, c.err) {
+			t.Errorf("create %s returns %v, expected %v", c.name, err, c.err)
+		}
+	}
+
+	if _, err = s.Create("alice", "password2", false); err != nil {
+		t.Fatal(err)
+	}
+
+	if u, err := s.Authenticate("ADMIN", "password1"); err != nil || !u.Admin {
+		t.Errorf("authenticate admin returns %+v, %v", u, err)
+	}
+
+	for _, c := range [][2]string{{"admin", "password2"}, {"bob", "password1"}} {
+		if _, err = s.Authenticate(c[0], c[1]); !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("authenticate %s with %s returns %v", c[0], c[1], err)
+		}
+	}
+
+	// the last admin is protected
+	demote := false
+	if _, err = s.Update("admin", nil, &demote); !errors.Is(err, ErrInvalidUser) {
+		t.Errorf("demote last admin returns %v", err)
+	}
+
+	if err = s.Delete("admin"); !errors.Is(err, ErrInvalidUser) {
+		t.Errorf("delete last admin returns %v", err)
+	}
+
+	password := "password3"
+	if _, err = s.Update("alice", &password, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// users are persisted with password hashes
+	s, err = NewUserStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := s.List()
+	if len(users) != 2 || users[0].Name != "admin" || users[1].PasswordHash == "password3" {
+		t.Fatalf("loaded users are %+v", users)
+	}
+
+	if _, err = s.Authenticate("alice", "password3"); err != nil {
+		t.Errorf("authenticate with new password returns %v", err)
+	}
+
+	if err = s.Delete("alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = s.Authenticate("alice", "password3"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("authenticate deleted user returns %v", err)
+	}
+}
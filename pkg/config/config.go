@@ -15,7 +15,10 @@ func GetDefaultConfig() Configuration {
 			Profiling: false,
 		},
 		Database: Database{
-			Path: "./db.json",
+			Driver:     "json",
+			Path:       "./db.json",
+			WriteDelay: 500,
+			WAL:        true,
 		},
 		Logging: Logging{
 			File:       "",
@@ -27,10 +30,41 @@ func GetDefaultConfig() Configuration {
 			Compress:   true,
 		},
 		HttpServer: HttpServer{
-			Host:         "0.0.0.0",
-			Port:         "9500",
-			ReadTimeout:  60,
-			WriteTimeout: 60,
+			Host:             "0.0.0.0",
+			Port:             "9500",
+			ReadTimeout:      60,
+			WriteTimeout:     60,
+			CorsAllowOrigins: []string{},
+		},
+		Auth: Auth{
+			Enabled:       false,
+			UsersPath:     "./users.json",
+			DataDir:       "./data",
+			AdminUser:     "admin",
+			AdminPassword: "",
+		},
+		Github: Github{
+			TokenSource:      "db",
+			TokenFile:        "",
+			TokenEnv:         "GITHUB_TOKEN",
+			BaseURL:          "",
+			UploadURL:        "",
+			CAFile:           "",
+			Proxy:            "",
+			Timeout:          30,
+			FullSyncInterval: 24,
+			CacheDir:         "./github-cache",
+			CheckpointDir:    "./github-checkpoint",
+			MaxRetries:       3,
+			MaxRateLimitWait: 900,
+		},
+		Sync: Sync{
+			Users:           []string{},
+			Cron:            "",
+			Interval:        0,
+			Jitter:          60,
+			UnstarredPolicy: "keep",
+			UnstarredPath:   "unstarred",
 		},
 	}
 }
@@ -41,7 +75,10 @@ type Common struct {
 }
 
 type Database struct {
-	Path string `mapstructure:"path"`
+	Driver     string `mapstructure:"driver"`
+	Path       string `mapstructure:"path"`
+	WriteDelay int    `mapstructure:"write_delay"`
+	WAL        bool   `mapstructure:"wal"`
 }
 
 type Logging struct {
@@ -59,6 +96,65 @@ type HttpServer struct {
 	Port         string `mapstructure:"port"`
 	ReadTimeout  int    `mapstructure:"read_timeout"`
 	WriteTimeout int    `mapstructure:"write_timeout"`
+	// CorsAllowOrigins are origins allowed by cors, * allows all and empty disables cors. * is
+	// refused if auth is enabled since browsers would send credentials from any site.
+	CorsAllowOrigins []string `mapstructure:"cors_allow_origins"`
+}
+
+// Auth enables local users authenticated by password or api token. The admin user is created
+// with AdminPassword on first start, a random password is logged if it is empty. The admin
+// uses the database and github token configured for the server, other users have their own
+// database of the same driver in DataDir/<user>.
+type Auth struct {
+	Enabled       bool   `mapstructure:"enabled"`
+	UsersPath     string `mapstructure:"users_path"`
+	DataDir       string `mapstructure:"data_dir"`
+	AdminUser     string `mapstructure:"admin_user"`
+	AdminPassword string `mapstructure:"admin_password"`
+}
+
+type Github struct {
+	TokenSource string `mapstructure:"token_source"`
+	TokenFile   string `mapstructure:"token_file"`
+	TokenEnv    string `mapstructure:"token_env"`
+	// BaseURL of api of GitHub Enterprise Server like https://github.example.com/api/v3/, /api/v3/
+	// is appended if it is missing. Empty means github.com. UploadURL defaults to BaseURL.
+	BaseURL   string `mapstructure:"base_url"`
+	UploadURL string `mapstructure:"upload_url"`
+	// CAFile is a PEM bundle trusted in addition to system certificates
+	CAFile string `mapstructure:"ca_file"`
+	// Proxy is url of http proxy, empty means HTTPS_PROXY and related environment variables
+	Proxy string `mapstructure:"proxy"`
+	// Timeout is seconds to wait for connection and response header of one request, 0 means
+	// no timeout. Retries and rate limit waits are not limited by it.
+	Timeout int `mapstructure:"timeout"`
+	// FullSyncInterval is hours between full syncs, which catch unstarred and renamed
+	// repositories, 0 means every sync is full
+	FullSyncInterval int `mapstructure:"full_sync_interval"`
+	// CacheDir keeps etag of github responses for conditional requests, empty means no cache
+	CacheDir string `mapstructure:"cache_dir"`
+	// CheckpointDir keeps pages fetched by an interrupted sync, empty means no checkpoint
+	CheckpointDir string `mapstructure:"checkpoint_dir"`
+	// MaxRetries of a request failed with server error
+	MaxRetries int `mapstructure:"max_retries"`
+	// MaxRateLimitWait is seconds to wait for rate limit reset, longer waits fail the sync
+	MaxRateLimitWait int `mapstructure:"max_rate_limit_wait"`
+}
+
+// Sync schedules periodic sync of users, Cron takes precedence over Interval, scheduled sync is
+// disabled if both of them are empty. UnstarredPolicy applies to every sync.
+type Sync struct {
+	Users []string `mapstructure:"users"`
+	// Cron is a standard cron expression with 5 fields or descriptor like @hourly
+	Cron string `mapstructure:"cron"`
+	// Interval is minutes between syncs
+	Interval int `mapstructure:"interval"`
+	// Jitter is max seconds of random delay added to every scheduled sync
+	Jitter int `mapstructure:"jitter"`
+	// UnstarredPolicy decides what full sync does to repositories no longer starred, could be
+	// drop, keep or archive. Archived ones are moved to UnstarredPath, folders are split by /.
+	UnstarredPolicy string `mapstructure:"unstarred_policy"`
+	UnstarredPath   string `mapstructure:"unstarred_path"`
 }
 
 type Configuration struct {
@@ -66,4 +162,7 @@ type Configuration struct {
 	Database   Database   `mapstructure:"database"`
 	Logging    Logging    `mapstructure:"logging"`
 	HttpServer HttpServer `mapstructure:"http_server"`
+	Auth       Auth       `mapstructure:"auth"`
+	Github     Github     `mapstructure:"github"`
+	Sync       Sync       `mapstructure:"sync"`
 }
@@ -0,0 +1,262 @@
+package github_api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/fs714/github-star-manager/pkg/config"
+	"github.com/fs714/github-star-manager/pkg/utils/log"
+	"github.com/pkg/errors"
+)
+
+var currentCache *CacheTransport
+
+// rate limit headers of a 304 response are newer than the cached ones
+var refreshedHeaders = []string{"Date", "X-Ratelimit-Limit", "X-Ratelimit-Remaining", "X-Ratelimit-Reset",
+	"X-Ratelimit-Used", "X-Ratelimit-Resource"}
+
+type cacheEntry struct {
+	Url          string
+	ETag         string
+	LastModified string
+	StatusCode   int
+	Header       http.Header
+	Body         []byte
+}
+
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// CacheTransport keeps ETag and Last-Modified of GET responses on disk and sends conditional
+// requests, a 304 response does not count against github rate limit and is served from the
+// cached body as 200. It should be placed after TokenTransport, so responses of different
+// tokens are cached separately.
+type CacheTransport struct {
+	Dir    string
+	Base   http.RoundTripper
+	hits   uint64
+	misses uint64
+}
+
+func NewCacheTransport(dir string) (*CacheTransport, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create github cache dir")
+	}
+
+	return &CacheTransport{Dir: dir}, nil
+}
+
+// InitCacheFromConfig enables http cache of all clients created by NewClient, cache is disabled
+// if github.cache_dir is empty.
+func InitCacheFromConfig() error {
+	if config.Config.Github.CacheDir == "" {
+		SetCache(nil)
+		return nil
+	}
+
+	cache, err := NewCacheTransport(config.Config.Github.CacheDir)
+	if err != nil {
+		return errors.WithMessage(err, "failed to init github cache from config")
+	}
+
+	SetCache(cache)
+
+	return nil
+}
+
+func SetCache(cache *CacheTransport) {
+	currentCache = cache
+}
+
+func CurrentCache() *CacheTransport {
+	return currentCache
+}
+
+// CurrentCacheStats returns zero stats if cache is disabled.
+func CurrentCacheStats() CacheStats {
+	if currentCache == nil {
+		return CacheStats{}
+	}
+
+	return currentCache.Stats()
+}
+
+func (t *CacheTransport) Stats() CacheStats {
+	return CacheStats{
+		Hits:   atomic.LoadUint64(&t.hits),
+		Misses: atomic.LoadUint64(&t.misses),
+	}
+}
+
+func (t *CacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return base.RoundTrip(req)
+	}
+
+	key := t.key(req)
+	entry, err := t.load(key)
+	if err != nil {
+		log.Warnw("ignore broken github cache entry", "url", req.URL.String(), "err", err.Error())
+		entry = nil
+	}
+
+	r := req
+	if entry != nil {
+		r = req.Clone(req.Context())
+		if entry.ETag != "" {
+			r.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			r.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := base.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry != nil && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		atomic.AddUint64(&t.hits, 1)
+		log.Debugw("github cache hit", "url", req.URL.String())
+
+		return entry.response(req, resp.Header), nil
+	}
+
+	atomic.AddUint64(&t.misses, 1)
+	log.Debugw("github cache miss", "url", req.URL.String(), "status", resp.StatusCode)
+
+	if resp.StatusCode != http.StatusOK || (resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "") {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read github response body")
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	err = t.store(key, &cacheEntry{
+		Url:          req.URL.String(),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		StatusCode:   resp.StatusCode,
+		Header:       resp.Header,
+		Body:         body,
+	})
+	if err != nil {
+		log.Warnw("failed to store github cache entry", "url", req.URL.String(), "err", err.Error())
+	}
+
+	return resp, nil
+}
+
+// key depends on token and media type besides url, since they change the response
+func (t *CacheTransport) key(req *http.Request) string {
+	h := sha256.New()
+	h.Write([]byte(req.URL.String()))
+	h.Write([]byte{0})
+	h.Write([]byte(req.Header.Get("Authorization")))
+	h.Write([]byte{0})
+	h.Write([]byte(req.Header.Get("Accept")))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (t *CacheTransport) path(key string) string {
+	return filepath.Join(t.Dir, key[:2], key+".json")
+}
+
+func (t *CacheTransport) load(key string) (*cacheEntry, error) {
+	data, err := os.ReadFile(t.path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to read github cache entry")
+	}
+
+	entry := &cacheEntry{}
+	err = json.Unmarshal(data, entry)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal github cache entry")
+	}
+
+	return entry, nil
+}
+
+func (t *CacheTransport) store(key string, entry *cacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal github cache entry")
+	}
+
+	path := t.path(key)
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return errors.Wrap(err, "failed to create github cache dir")
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return errors.Wrap(err, "failed to create github cache entry")
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if err != nil {
+		f.Close()
+		return errors.Wrap(err, "failed to write github cache entry")
+	}
+
+	err = f.Close()
+	if err != nil {
+		return errors.Wrap(err, "failed to close github cache entry")
+	}
+
+	err = os.Rename(f.Name(), path)
+	if err != nil {
+		return errors.Wrap(err, "failed to rename github cache entry")
+	}
+
+	return nil
+}
+
+func (e *cacheEntry) response(req *http.Request, notModified http.Header) *http.Response {
+	header := e.Header.Clone()
+	for _, k := range refreshedHeaders {
+		if v := notModified.Get(k); v != "" {
+			header.Set(k, v)
+		}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,71 @@
+package github_api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCacheTransport(t *testing.T) {
+	var requests, conditional int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("X-Ratelimit-Remaining", "10")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			conditional++
+			w.Header().Set("X-Ratelimit-Remaining", "9")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("starred"))
+	}))
+	defer server.Close()
+
+	cache, err := NewCacheTransport(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{Transport: &TokenTransport{Source: &StaticTokenSource{AccessToken: "token"}, Base: cache}}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL + "/user/starred")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK || string(body) != "starred" {
+			t.Errorf("request %d returns %d %q", i, resp.StatusCode, string(body))
+		}
+
+		if i == 1 && resp.Header.Get("X-Ratelimit-Remaining") != "9" {
+			t.Errorf("rate limit header is not refreshed: %v", resp.Header)
+		}
+	}
+
+	if requests != 2 || conditional != 1 {
+		t.Errorf("server got %d requests and %d conditional requests, expected 2 and 1", requests, conditional)
+	}
+
+	if stats := cache.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("cache stats are %+v", stats)
+	}
+
+	// another token does not share cached responses
+	client.Transport = &TokenTransport{Source: &StaticTokenSource{AccessToken: "other"}, Base: cache}
+	resp, err := client.Get(server.URL + "/user/starred")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if conditional != 1 {
+		t.Error("conditional request is sent with cache entry of another token")
+	}
+}
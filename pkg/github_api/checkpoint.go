@@ -0,0 +1,136 @@
+package github_api
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fs714/github-star-manager/pkg/config"
+	"github.com/fs714/github-star-manager/pkg/utils/log"
+	"github.com/google/go-github/v50/github"
+	"github.com/pkg/errors"
+)
+
+// checkpoints older than this are dropped, since unstars in the meantime shift later pages
+const checkpointMaxAge = time.Hour
+
+var checkpointDir string
+
+// starredCheckpoint records stars fetched by an interrupted listing, so next listing with the
+// same user and since resumes from NextPage.
+type starredCheckpoint struct {
+	User      string
+	Since     int64
+	NextPage  int
+	UpdatedAt int64
+	Repos     []*github.StarredRepository
+}
+
+// InitCheckpointFromConfig enables checkpoints of starred repos listing, checkpoints are
+// disabled if github.checkpoint_dir is empty.
+func InitCheckpointFromConfig() error {
+	return SetCheckpointDir(config.Config.Github.CheckpointDir)
+}
+
+func SetCheckpointDir(dir string) error {
+	if dir != "" {
+		err := os.MkdirAll(dir, 0700)
+		if err != nil {
+			return errors.Wrap(err, "failed to create github checkpoint dir")
+		}
+	}
+
+	checkpointDir = dir
+
+	return nil
+}
+
+// checkpointKey returns key of checkpoints of user listed for owner, which is the tenant syncing
+// the stars. Tenants syncing the same user do not share checkpoints.
+func checkpointKey(owner string, user string) string {
+	if owner == "" {
+		return user
+	}
+
+	return owner + ":" + user
+}
+
+func checkpointPath(user string) string {
+	return filepath.Join(checkpointDir, "starred-"+hex.EncodeToString([]byte(strings.ToLower(user)))+".json")
+}
+
+// loadCheckpoint returns nil if there is no usable checkpoint for user and since.
+func loadCheckpoint(user string, since time.Time) *starredCheckpoint {
+	if checkpointDir == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(checkpointPath(user))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnw("failed to read github checkpoint", "user", user, "err", err.Error())
+		}
+		return nil
+	}
+
+	cp := &starredCheckpoint{}
+	err = json.Unmarshal(data, cp)
+	if err != nil {
+		log.Warnw("ignore broken github checkpoint", "user", user, "err", err.Error())
+		return nil
+	}
+
+	if !strings.EqualFold(cp.User, user) || cp.Since != sinceUnix(since) || cp.NextPage <= 0 ||
+		time.Since(time.Unix(cp.UpdatedAt, 0)) > checkpointMaxAge {
+		return nil
+	}
+
+	return cp
+}
+
+func (cp *starredCheckpoint) save() error {
+	if checkpointDir == "" {
+		return nil
+	}
+
+	cp.UpdatedAt = time.Now().Unix()
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal github checkpoint")
+	}
+
+	path := checkpointPath(cp.User)
+	err = os.WriteFile(path+".tmp", data, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to write github checkpoint")
+	}
+
+	err = os.Rename(path+".tmp", path)
+	if err != nil {
+		return errors.Wrap(err, "failed to rename github checkpoint")
+	}
+
+	return nil
+}
+
+func removeCheckpoint(user string) {
+	if checkpointDir == "" {
+		return
+	}
+
+	err := os.Remove(checkpointPath(user))
+	if err != nil && !os.IsNotExist(err) {
+		log.Warnw("failed to remove github checkpoint", "user", user, "err", err.Error())
+	}
+}
+
+func sinceUnix(since time.Time) int64 {
+	if since.IsZero() {
+		return 0
+	}
+
+	return since.Unix()
+}
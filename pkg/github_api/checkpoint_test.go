@@ -0,0 +1,61 @@
+package github_api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v50/github"
+)
+
+func TestStarredCheckpoint(t *testing.T) {
+	defer SetCheckpointDir("")
+	err := SetCheckpointDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	since := time.Unix(1000, 0)
+	cp := &starredCheckpoint{User: "fs714", Since: sinceUnix(since), NextPage: 3,
+		Repos: []*github.StarredRepository{{Repository: &github.Repository{ID: github.Int64(1)}}}}
+	err = cp.save()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := loadCheckpoint("FS714", since)
+	if loaded == nil || loaded.NextPage != 3 || len(loaded.Repos) != 1 {
+		t.Fatalf("loaded checkpoint is %+v", loaded)
+	}
+
+	if loadCheckpoint("fs714", time.Time{}) != nil {
+		t.Error("checkpoint of another since is used")
+	}
+
+	if loadCheckpoint("other", since) != nil {
+		t.Error("checkpoint of another user is used")
+	}
+
+	if loadCheckpoint(checkpointKey("alice", "fs714"), since) != nil {
+		t.Error("checkpoint of another owner is used")
+	}
+
+	cp.User = checkpointKey("alice", "fs714")
+	cp.NextPage = 5
+	err = cp.save()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded = loadCheckpoint(checkpointKey("alice", "fs714"), since); loaded == nil || loaded.NextPage != 5 {
+		t.Errorf("checkpoint of owner is %+v", loaded)
+	}
+
+	if loaded = loadCheckpoint("fs714", since); loaded == nil || loaded.NextPage != 3 {
+		t.Errorf("checkpoint is replaced by checkpoint of owner: %+v", loaded)
+	}
+
+	removeCheckpoint("fs714")
+	if loadCheckpoint("fs714", since) != nil {
+		t.Error("removed checkpoint is used")
+	}
+}
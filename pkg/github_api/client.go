@@ -0,0 +1,136 @@
+package github_api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fs714/github-star-manager/pkg/config"
+	"github.com/google/go-github/v50/github"
+	"github.com/pkg/errors"
+)
+
+var (
+	clientMu      sync.Mutex
+	currentClient *github.Client
+)
+
+// NewClientFromConfig builds a client of github.com or GitHub Enterprise Server with
+// CurrentTokenSource, see NewClientWithTokenSource.
+func NewClientFromConfig(cfg config.Github) (*github.Client, error) {
+	return NewClientWithTokenSource(cfg, CurrentTokenSource())
+}
+
+// NewClientWithTokenSource builds a client whose requests go through TokenTransport with source,
+// RetryTransport and CacheTransport if cache is enabled, so cache should be initialized before.
+// Clients share the cache, which uses transport of the first client since they are built from
+// the same config.
+func NewClientWithTokenSource(cfg config.Github, source TokenSource) (*github.Client, error) {
+	transport, err := newHTTPTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var base http.RoundTripper = transport
+	if cache := CurrentCache(); cache != nil {
+		if cache.Base == nil {
+			cache.Base = transport
+		}
+		base = cache
+	}
+
+	httpClient := &http.Client{
+		Transport: &TokenTransport{Source: source, Base: NewRetryTransportFromConfig(base, cfg)},
+	}
+
+	if cfg.BaseURL == "" {
+		return github.NewClient(httpClient), nil
+	}
+
+	uploadURL := cfg.UploadURL
+	if uploadURL == "" {
+		uploadURL = cfg.BaseURL
+	}
+
+	client, err := github.NewEnterpriseClient(cfg.BaseURL, uploadURL, httpClient)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid github base url or upload url")
+	}
+
+	return client, nil
+}
+
+func newHTTPTransport(cfg config.Github) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.Proxy != "" {
+		proxy, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid github proxy url")
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read github ca file")
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificate is found in github ca file %s", cfg.CAFile)
+		}
+
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	if cfg.Timeout > 0 {
+		timeout := time.Duration(cfg.Timeout) * time.Second
+		transport.DialContext = (&net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}).DialContext
+		transport.TLSHandshakeTimeout = timeout
+		transport.ResponseHeaderTimeout = timeout
+	}
+
+	return transport, nil
+}
+
+func InitClientFromConfig() error {
+	client, err := NewClientFromConfig(config.Config.Github)
+	if err != nil {
+		return errors.WithMessage(err, "failed to init github client from config")
+	}
+
+	SetClient(client)
+
+	return nil
+}
+
+func SetClient(client *github.Client) {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+
+	currentClient = client
+}
+
+// CurrentClient returns the client set by InitClientFromConfig, a client of github.com with
+// default settings is built if it is not initialized.
+func CurrentClient() *github.Client {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+
+	if currentClient == nil {
+		currentClient, _ = NewClientFromConfig(config.DefaultConfig.Github)
+	}
+
+	return currentClient
+}
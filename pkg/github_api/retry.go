@@ -0,0 +1,191 @@
+package github_api
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fs714/github-star-manager/pkg/config"
+	"github.com/fs714/github-star-manager/pkg/utils/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultRetryBaseDelay = time.Second
+	defaultRetryMaxDelay  = 30 * time.Second
+)
+
+// sleep is replaced in tests
+var sleep = func(req *http.Request, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+// RetryTransport retries transient failures. Requests exceeding rate limit wait until limit is
+// reset if the wait is not longer than MaxRateLimitWait, otherwise the response is returned and
+// go-github reports a RateLimitError. Server errors, 202 Accepted and network errors are retried
+// with jittered exponential backoff at most MaxRetries times.
+type RetryTransport struct {
+	Base             http.RoundTripper
+	MaxRetries       int
+	MaxRateLimitWait time.Duration
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+
+	mu sync.Mutex
+	// resetAt is set when primary rate limit is used up, requests are held until then
+	resetAt time.Time
+}
+
+func NewRetryTransportFromConfig(base http.RoundTripper, cfg config.Github) *RetryTransport {
+	return &RetryTransport{
+		Base:             base,
+		MaxRetries:       cfg.MaxRetries,
+		MaxRateLimitWait: time.Duration(cfg.MaxRateLimitWait) * time.Second,
+	}
+}
+
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	// request body can not be sent twice unless it can be recreated
+	retryable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	for attempt := 0; ; attempt++ {
+		err := t.waitRateLimitReset(req)
+		if err != nil {
+			return nil, err
+		}
+
+		r := req
+		if attempt > 0 && req.GetBody != nil {
+			r = req.Clone(req.Context())
+			r.Body, err = req.GetBody()
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to recreate request body")
+			}
+		}
+
+		resp, err := base.RoundTrip(r)
+		if !retryable || attempt >= t.MaxRetries {
+			return resp, err
+		}
+
+		wait, retry := t.retryDelay(resp, err, attempt)
+		if !retry {
+			return resp, err
+		}
+
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		log.Warnw("retry github request", "url", req.URL.String(), "attempt", attempt+1, "wait", wait.String(),
+			"reason", retryReason(resp, err))
+
+		if wait > 0 {
+			err = sleep(req, wait)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (t *RetryTransport) waitRateLimitReset(req *http.Request) error {
+	t.mu.Lock()
+	wait := time.Until(t.resetAt)
+	t.mu.Unlock()
+
+	if wait <= 0 || wait > t.MaxRateLimitWait {
+		return nil
+	}
+
+	log.Infow("wait for github rate limit reset", "wait", wait.String())
+
+	return sleep(req, wait)
+}
+
+// retryDelay returns how long to wait before retrying, and false if the request should not
+// be retried.
+func (t *RetryTransport) retryDelay(resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if err != nil {
+		return t.backoff(attempt), true
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests:
+		// secondary rate limit
+		if s := resp.Header.Get("Retry-After"); s != "" {
+			seconds, e := strconv.Atoi(s)
+			if e != nil {
+				return 0, false
+			}
+
+			wait := time.Duration(seconds) * time.Second
+			return wait, wait <= t.MaxRateLimitWait
+		}
+
+		// primary rate limit
+		if resp.Header.Get("X-Ratelimit-Remaining") == "0" {
+			reset, e := strconv.ParseInt(resp.Header.Get("X-Ratelimit-Reset"), 10, 64)
+			if e != nil {
+				return 0, false
+			}
+
+			resetAt := time.Unix(reset, 0).Add(time.Second)
+			t.mu.Lock()
+			t.resetAt = resetAt
+			t.mu.Unlock()
+
+			// waitRateLimitReset sleeps before next attempt
+			return 0, time.Until(resetAt) <= t.MaxRateLimitWait
+		}
+
+		return 0, false
+	case resp.StatusCode == http.StatusAccepted || resp.StatusCode >= http.StatusInternalServerError:
+		return t.backoff(attempt), true
+	default:
+		return 0, false
+	}
+}
+
+// backoff returns a random delay in [0, min(MaxDelay, BaseDelay * 2^attempt)]
+func (t *RetryTransport) backoff(attempt int) time.Duration {
+	baseDelay := t.BaseDelay
+	if baseDelay <= 0 {
+		baseDelay = defaultRetryBaseDelay
+	}
+
+	maxDelay := t.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultRetryMaxDelay
+	}
+
+	delay := baseDelay << uint(attempt)
+	if delay <= 0 || delay > maxDelay {
+		delay = maxDelay
+	}
+
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+func retryReason(resp *http.Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+
+	return resp.Status
+}
@@ -0,0 +1,94 @@
+package github_api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRetryTransport(t *testing.T) {
+	var slept []time.Duration
+	defer func(f func(*http.Request, time.Duration) error) { sleep = f }(sleep)
+	sleep = func(req *http.Request, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+
+	cases := []struct {
+		name      string
+		responses []func(w http.ResponseWriter)
+		status    int
+		requests  int
+		sleeps    int
+	}{
+		{"server error", []func(w http.ResponseWriter){
+			func(w http.ResponseWriter) { w.WriteHeader(http.StatusBadGateway) },
+			func(w http.ResponseWriter) { w.WriteHeader(http.StatusOK) },
+		}, http.StatusOK, 2, 1},
+		{"too many server errors", []func(w http.ResponseWriter){
+			func(w http.ResponseWriter) { w.WriteHeader(http.StatusInternalServerError) },
+		}, http.StatusInternalServerError, 3, 2},
+		{"secondary rate limit", []func(w http.ResponseWriter){
+			func(w http.ResponseWriter) {
+				w.Header().Set("Retry-After", "2")
+				w.WriteHeader(http.StatusForbidden)
+			},
+			func(w http.ResponseWriter) { w.WriteHeader(http.StatusOK) },
+		}, http.StatusOK, 2, 1},
+		{"primary rate limit resets soon", []func(w http.ResponseWriter){
+			func(w http.ResponseWriter) {
+				w.Header().Set("X-Ratelimit-Remaining", "0")
+				w.Header().Set("X-Ratelimit-Reset", strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+				w.WriteHeader(http.StatusForbidden)
+			},
+			func(w http.ResponseWriter) { w.WriteHeader(http.StatusOK) },
+		}, http.StatusOK, 2, 1},
+		{"primary rate limit resets late", []func(w http.ResponseWriter){
+			func(w http.ResponseWriter) {
+				w.Header().Set("X-Ratelimit-Remaining", "0")
+				w.Header().Set("X-Ratelimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+				w.WriteHeader(http.StatusForbidden)
+			},
+		}, http.StatusForbidden, 1, 0},
+		{"client error", []func(w http.ResponseWriter){
+			func(w http.ResponseWriter) { w.WriteHeader(http.StatusNotFound) },
+		}, http.StatusNotFound, 1, 0},
+	}
+
+	for _, c := range cases {
+		slept = nil
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			idx := requests
+			if idx >= len(c.responses) {
+				idx = len(c.responses) - 1
+			}
+			requests++
+			c.responses[idx](w)
+		}))
+
+		client := &http.Client{Transport: &RetryTransport{MaxRetries: 2, MaxRateLimitWait: 10 * time.Minute}}
+		resp, err := client.Get(server.URL)
+		server.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != c.status || requests != c.requests || len(slept) != c.sleeps {
+			t.Errorf("%s: got status %d after %d requests and %d sleeps, expected %d, %d and %d", c.name,
+				resp.StatusCode, requests, len(slept), c.status, c.requests, c.sleeps)
+		}
+	}
+}
+
+func TestRetryTransportBackoff(t *testing.T) {
+	rt := &RetryTransport{BaseDelay: time.Second, MaxDelay: 4 * time.Second}
+	for attempt := 0; attempt < 10; attempt++ {
+		if d := rt.backoff(attempt); d < 0 || d > 4*time.Second {
+			t.Errorf("backoff of attempt %d is %s", attempt, d)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package github_api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/pkg/errors"
+)
+
+var ErrRepoNotFound = errors.New("github repository not found")
+
+// SplitFullName splits owner/name into owner and name.
+func SplitFullName(fullName string) (string, string, error) {
+	parts := strings.Split(fullName, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", errors.Errorf("invalid repository name %q, expected owner/name", fullName)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// GetRepo returns metadata of repository fullName, ErrRepoNotFound is returned if it does not
+// exist or is not visible to the authenticated user.
+func GetRepo(ctx context.Context, client *github.Client, fullName string) (*github.Repository, error) {
+	owner, name, err := SplitFullName(fullName)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, resp, err := client.Repositories.Get(ctx, owner, name)
+	if err != nil {
+		return nil, wrapRepoError(resp, err, "failed to get github repository "+fullName)
+	}
+
+	return repo, nil
+}
+
+// IsStarred reports whether repository fullName is starred by the authenticated user.
+func IsStarred(ctx context.Context, client *github.Client, fullName string) (bool, error) {
+	owner, name, err := SplitFullName(fullName)
+	if err != nil {
+		return false, err
+	}
+
+	starred, _, err := client.Activity.IsStarred(ctx, owner, name)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to check star of github repository %s", fullName)
+	}
+
+	return starred, nil
+}
+
+// StarRepo stars repository fullName as the authenticated user, starring a starred repository
+// is a no-op on github.
+func StarRepo(ctx context.Context, client *github.Client, fullName string) error {
+	owner, name, err := SplitFullName(fullName)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Activity.Star(ctx, owner, name)
+	if err != nil {
+		return wrapRepoError(resp, err, "failed to star github repository "+fullName)
+	}
+
+	return nil
+}
+
+// UnstarRepo unstars repository fullName as the authenticated user, unstarring a repository
+// which is not starred is a no-op on github.
+func UnstarRepo(ctx context.Context, client *github.Client, fullName string) error {
+	owner, name, err := SplitFullName(fullName)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Activity.Unstar(ctx, owner, name)
+	if err != nil {
+		return wrapRepoError(resp, err, "failed to unstar github repository "+fullName)
+	}
+
+	return nil
+}
+
+func wrapRepoError(resp *github.Response, err error, msg string) error {
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return errors.WithMessage(ErrRepoNotFound, msg)
+	}
+
+	return errors.Wrap(err, msg)
+}
+
+// GetLogin returns login of the authenticated user.
+func GetLogin(ctx context.Context, client *github.Client) (string, error) {
+	user, _, err := client.Users.Get(ctx, "")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get authenticated github user")
+	}
+
+	return user.GetLogin(), nil
+}
@@ -0,0 +1,95 @@
+package github_api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// newFakeGithub serves star endpoints of github api for repositories in repos, starred records
+// star state of every repository
+func newFakeGithub(t *testing.T, repos map[string]bool) (*github.Client, map[string]bool) {
+	starred := make(map[string]bool)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/user/starred/", func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path[len("/user/starred/"):]
+		if !repos[name] {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			if !starred[name] {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+		case http.MethodPut:
+			starred[name] = true
+		case http.MethodDelete:
+			delete(starred, name)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/repos/", func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path[len("/repos/"):]
+		if !repos[name] {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"id": 1, "full_name": "` + name + `", "language": "Go"}`))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	return client, starred
+}
+
+func TestStarRepo(t *testing.T) {
+	client, starred := newFakeGithub(t, map[string]bool{"fs714/gsm": true})
+	ctx := context.Background()
+
+	repo, err := GetRepo(ctx, client, "fs714/gsm")
+	if err != nil || repo.GetFullName() != "fs714/gsm" || repo.GetLanguage() != "Go" {
+		t.Fatalf("get repo returns %v, %v", repo, err)
+	}
+
+	if _, err = GetRepo(ctx, client, "fs714/missing"); !errors.Is(err, ErrRepoNotFound) {
+		t.Errorf("get missing repo returns %v, expected ErrRepoNotFound", err)
+	}
+
+	if _, err = GetRepo(ctx, client, "invalid"); err == nil {
+		t.Error("get repo with invalid name succeeds")
+	}
+
+	err = StarRepo(ctx, client, "fs714/gsm")
+	if err != nil || !starred["fs714/gsm"] {
+		t.Fatalf("star repo returns %v, starred %v", err, starred)
+	}
+
+	if ok, err := IsStarred(ctx, client, "fs714/gsm"); err != nil || !ok {
+		t.Errorf("starred repo is reported as %v, %v", ok, err)
+	}
+
+	if err = StarRepo(ctx, client, "fs714/missing"); !errors.Is(err, ErrRepoNotFound) {
+		t.Errorf("star missing repo returns %v, expected ErrRepoNotFound", err)
+	}
+
+	err = UnstarRepo(ctx, client, "fs714/gsm")
+	if err != nil || starred["fs714/gsm"] {
+		t.Fatalf("unstar repo returns %v, starred %v", err, starred)
+	}
+
+	if ok, err := IsStarred(ctx, client, "fs714/gsm"); err != nil || ok {
+		t.Errorf("unstarred repo is reported as %v, %v", ok, err)
+	}
+}
@@ -2,26 +2,68 @@ package github_api
 
 import (
 	"context"
+	"time"
 
+	"github.com/fs714/github-star-manager/pkg/utils/log"
 	"github.com/google/go-github/v50/github"
 	"github.com/pkg/errors"
 )
 
+// GetStarredRepos returns all repositories starred by user with CurrentClient, newest star first.
 func GetStarredRepos(user string) ([]*github.StarredRepository, error) {
-	ctx := context.Background()
-
-	client := github.NewClient(nil)
+	return GetStarredReposSince(context.Background(), CurrentClient(), "", user, time.Time{}, nil)
+}
 
+// GetStarredReposSince returns repositories starred by user at or after since, newest star
+// first. Stars are listed by starred time, so paging stops at the first older star. Fetched
+// pages are checkpointed, a failed listing is resumed by the next call with same owner, user and
+// since. owner is the tenant listing the stars, it is empty if auth is disabled. onPage is called
+// with number of pages and repositories fetched after every page if not nil.
+func GetStarredReposSince(ctx context.Context, client *github.Client, owner string, user string,
+	since time.Time, onPage func(pages int, fetched int)) ([]*github.StarredRepository, error) {
 	opt := &github.ActivityListStarredOptions{
+		Sort:        "created",
+		Direction:   "desc",
 		ListOptions: github.ListOptions{PerPage: 100},
 	}
 
-	var allRepos []*github.StarredRepository
+	// stars of token owner are checkpointed by login, since clients with tokens of different
+	// users share checkpoint dir
+	key := user
+	if user == "" && checkpointDir != "" {
+		login, err := GetLogin(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+		key = "@" + login
+	}
+	key = checkpointKey(owner, key)
+
+	cp := loadCheckpoint(key, since)
+	if cp != nil {
+		log.Infow("resume listing starred repos from checkpoint", "user", key, "page", cp.NextPage,
+			"fetched", len(cp.Repos))
+		opt.Page = cp.NextPage
+	} else {
+		cp = &starredCheckpoint{User: key, Since: sinceUnix(since)}
+	}
+
+	pages := 0
+	if opt.Page > 1 {
+		pages = opt.Page - 1
+	}
+
+	seen := make(map[int64]bool)
+	for _, repo := range cp.Repos {
+		seen[repo.GetRepository().GetID()] = true
+	}
+
 	for {
 		repos, resp, err := client.Activity.ListStarred(ctx, user, opt)
 		if err != nil {
-			if _, ok := err.(*github.RateLimitError); ok {
-				err = errors.New("failed to get github starred repos: hit github rate limit")
+			if e, ok := err.(*github.RateLimitError); ok {
+				err = errors.Errorf("failed to get github starred repos: hit github rate limit, reset at %s",
+					e.Rate.Reset.Format(time.RFC3339))
 				return nil, err
 			} else if _, ok := err.(*github.AcceptedError); ok {
 				err = errors.New("failed to get github starred repos: scheduled on GitHub side")
@@ -31,14 +73,43 @@ func GetStarredRepos(user string) ([]*github.StarredRepository, error) {
 			}
 		}
 
-		allRepos = append(allRepos, repos...)
+		pages++
+		done := false
+		for _, repo := range repos {
+			if !since.IsZero() && repo.StarredAt != nil && repo.StarredAt.Before(since) {
+				done = true
+				break
+			}
+
+			// pages shift when repositories are starred during listing
+			if id := repo.GetRepository().GetID(); id != 0 {
+				if seen[id] {
+					continue
+				}
+				seen[id] = true
+			}
 
-		if resp.NextPage == 0 {
+			cp.Repos = append(cp.Repos, repo)
+		}
+
+		if onPage != nil {
+			onPage(pages, len(cp.Repos))
+		}
+
+		if done || resp.NextPage == 0 {
 			break
 		}
 
 		opt.Page = resp.NextPage
+
+		cp.NextPage = resp.NextPage
+		err = cp.save()
+		if err != nil {
+			log.Warnw("failed to save github checkpoint", "user", key, "err", err.Error())
+		}
 	}
 
-	return allRepos, nil
+	removeCheckpoint(key)
+
+	return cp.Repos, nil
 }
@@ -1,23 +1,139 @@
 package github_api
 
 import (
+	"context"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
+	"time"
+
+	"github.com/fs714/github-star-manager/pkg/config"
 )
 
+// newFakeEnterprise serves stars of fs714 with 2 repositories per page like a GitHub Enterprise
+// Server over tls, the returned config trusts its certificate
+func newFakeEnterprise(t *testing.T, starredAt []int64) config.Github {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/users/fs714/starred", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sort") != "created" || r.URL.Query().Get("direction") != "desc" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+
+		stars := make([]obj, 0)
+		for i := (page - 1) * 2; i < len(starredAt) && i < page*2; i++ {
+			stars = append(stars, obj{
+				"starred_at": time.Unix(starredAt[i], 0).UTC().Format(time.RFC3339),
+				"repo":       obj{"id": i + 1, "full_name": fmt.Sprintf("fs714/repo%d", i+1)},
+			})
+		}
+
+		if page*2 < len(starredAt) {
+			w.Header().Set("Link", fmt.Sprintf(`<https://%s%s?page=%d>; rel="next"`, r.Host, r.URL.Path, page+1))
+		}
+		json.NewEncoder(w).Encode(stars)
+	})
+
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.DefaultConfig.Github
+	cfg.BaseURL = server.URL
+	cfg.CAFile = caFile
+
+	return cfg
+}
+
+type obj map[string]interface{}
+
 func TestGetStarredRepos(t *testing.T) {
-	repos, err := GetStarredRepos("fs714")
+	cfg := newFakeEnterprise(t, []int64{500, 400, 300, 200, 100})
+
+	client, err := NewClientFromConfig(cfg)
 	if err != nil {
-		fmt.Printf("%+v\n", err)
-		t.FailNow()
+		t.Fatal(err)
 	}
 
-	reposJson, err := json.MarshalIndent(repos, "", "  ")
+	var pages []int
+	repos, err := GetStarredReposSince(context.Background(), client, "", "fs714", time.Time{}, func(p int, fetched int) {
+		pages = append(pages, p)
+	})
 	if err != nil {
-		fmt.Printf("%+v\n", err)
-		t.FailNow()
+		t.Fatalf("%+v", err)
+	}
+
+	if len(repos) != 5 || repos[0].GetRepository().GetFullName() != "fs714/repo1" || len(pages) != 3 {
+		t.Fatalf("got %d repos in %v pages", len(repos), pages)
 	}
 
-	fmt.Println(string(reposJson))
+	repos, err = GetStarredReposSince(context.Background(), client, "", "fs714", time.Unix(300, 0), nil)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	if len(repos) != 3 || repos[2].GetStarredAt().Unix() != 300 {
+		t.Errorf("got %d repos starred since 300", len(repos))
+	}
+
+	// certificate of fake server is not trusted without ca file
+	cfg.CAFile = ""
+	cfg.MaxRetries = 0
+	client, err = NewClientFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = GetStarredReposSince(context.Background(), client, "", "fs714", time.Time{}, nil); err == nil {
+		t.Error("untrusted certificate is accepted")
+	}
+}
+
+func TestNewClientFromConfig(t *testing.T) {
+	client, err := NewClientFromConfig(config.Github{BaseURL: "https://github.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if client.BaseURL.String() != "https://github.example.com/api/v3/" ||
+		client.UploadURL.String() != "https://github.example.com/api/uploads/" {
+		t.Errorf("base url %s, upload url %s", client.BaseURL, client.UploadURL)
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err = os.WriteFile(caFile, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := []config.Github{
+		{CAFile: caFile},
+		{CAFile: filepath.Join(t.TempDir(), "missing.pem")},
+		{Proxy: "://invalid"},
+		{BaseURL: "://invalid"},
+	}
+	for _, cfg := range invalid {
+		if _, err = NewClientFromConfig(cfg); err == nil {
+			t.Errorf("invalid config %+v is accepted", cfg)
+		}
+	}
+
+	if client, err = NewClientFromConfig(config.Github{}); err != nil || client.BaseURL.Host != "api.github.com" {
+		t.Errorf("default client is %v, %v", client, err)
+	}
 }
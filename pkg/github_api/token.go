@@ -0,0 +1,147 @@
+package github_api
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/fs714/github-star-manager/pkg/config"
+	"github.com/pkg/errors"
+)
+
+const (
+	TokenSourceDB   = "db"
+	TokenSourceFile = "file"
+	TokenSourceEnv  = "env"
+)
+
+var currentTokenSource TokenSource = &StaticTokenSource{}
+
+// TokenSource returns the github token which should be used for next api call.
+// An empty token means the api will be called without authentication.
+type TokenSource interface {
+	Token() (string, error)
+}
+
+type StaticTokenSource struct {
+	AccessToken string
+}
+
+func (s *StaticTokenSource) Token() (string, error) {
+	return s.AccessToken, nil
+}
+
+type DBTokenSource struct {
+	GetToken func() string
+}
+
+func (s *DBTokenSource) Token() (string, error) {
+	if s.GetToken == nil {
+		return "", nil
+	}
+
+	return s.GetToken(), nil
+}
+
+type FileTokenSource struct {
+	Path string
+}
+
+func (s *FileTokenSource) Token() (string, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read github token file")
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+type EnvTokenSource struct {
+	Name string
+}
+
+func (s *EnvTokenSource) Token() (string, error) {
+	return strings.TrimSpace(os.Getenv(s.Name)), nil
+}
+
+func NewTokenSource(cfg config.Github, dbToken func() string) (TokenSource, error) {
+	switch cfg.TokenSource {
+	case TokenSourceDB, "":
+		return &DBTokenSource{GetToken: dbToken}, nil
+	case TokenSourceFile:
+		if cfg.TokenFile == "" {
+			return nil, errors.New("token file should be set when token source is file")
+		}
+		return &FileTokenSource{Path: cfg.TokenFile}, nil
+	case TokenSourceEnv:
+		if cfg.TokenEnv == "" {
+			return nil, errors.New("token env should be set when token source is env")
+		}
+		return &EnvTokenSource{Name: cfg.TokenEnv}, nil
+	default:
+		return nil, errors.Errorf("unsupported github token source: %s", cfg.TokenSource)
+	}
+}
+
+func InitTokenSourceFromConfig(dbToken func() string) error {
+	source, err := NewTokenSource(config.Config.Github, dbToken)
+	if err != nil {
+		return errors.WithMessage(err, "failed to init github token source from config")
+	}
+
+	SetTokenSource(source)
+
+	return nil
+}
+
+func SetTokenSource(source TokenSource) {
+	currentTokenSource = source
+}
+
+func CurrentTokenSource() TokenSource {
+	return currentTokenSource
+}
+
+// TokenTransport injects the token from source into every request, so a rotated token
+// is picked up without rebuilding the client.
+type TokenTransport struct {
+	Source TokenSource
+	Base   http.RoundTripper
+}
+
+func (t *TokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if t.Source == nil {
+		return base.RoundTrip(req)
+	}
+
+	token, err := t.Source.Token()
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get github token")
+	}
+
+	if token == "" {
+		return base.RoundTrip(req)
+	}
+
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", "Bearer "+token)
+
+	return base.RoundTrip(r)
+}
+
+func MaskToken(token string) string {
+	if token == "" {
+		return ""
+	}
+
+	if len(token) <= 8 {
+		return strings.Repeat("*", len(token))
+	}
+
+	return token[:4] + strings.Repeat("*", len(token)-8) + token[len(token)-4:]
+}
@@ -0,0 +1,95 @@
+package github_api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fs714/github-star-manager/pkg/config"
+)
+
+func TestMaskToken(t *testing.T) {
+	cases := map[string]string{
+		"":                         "",
+		"abc":                      "***",
+		"ghp_1234567890abcdefWXYZ": "ghp_****************WXYZ",
+	}
+
+	for token, expected := range cases {
+		if masked := MaskToken(token); masked != expected {
+			t.Errorf("MaskToken(%q) = %q, expected %q", token, masked, expected)
+		}
+	}
+}
+
+func TestNewTokenSource(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	err := os.WriteFile(tokenFile, []byte("file-token\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("GSM_TEST_TOKEN", "env-token")
+
+	cases := []struct {
+		cfg      config.Github
+		expected string
+	}{
+		{config.Github{TokenSource: TokenSourceDB}, "db-token"},
+		{config.Github{TokenSource: TokenSourceFile, TokenFile: tokenFile}, "file-token"},
+		{config.Github{TokenSource: TokenSourceEnv, TokenEnv: "GSM_TEST_TOKEN"}, "env-token"},
+	}
+
+	for _, c := range cases {
+		source, err := NewTokenSource(c.cfg, func() string { return "db-token" })
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		token, err := source.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if token != c.expected {
+			t.Errorf("token from %s source = %q, expected %q", c.cfg.TokenSource, token, c.expected)
+		}
+	}
+
+	_, err = NewTokenSource(config.Github{TokenSource: "unknown"}, nil)
+	if err == nil {
+		t.Error("expected error for unknown token source")
+	}
+}
+
+func TestTokenTransport(t *testing.T) {
+	var authHeader string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	source := &StaticTokenSource{}
+	client := &http.Client{Transport: &TokenTransport{Source: source}}
+
+	for _, token := range []string{"", "first", "rotated"} {
+		source.AccessToken = token
+
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		expected := ""
+		if token != "" {
+			expected = "Bearer " + token
+		}
+
+		if authHeader != expected {
+			t.Errorf("Authorization header = %q, expected %q", authHeader, expected)
+		}
+	}
+}
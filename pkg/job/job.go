@@ -0,0 +1,315 @@
+package job
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fs714/github-star-manager/pkg/utils/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	StatePending   = "pending"
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+	StateCanceled  = "canceled"
+
+	// finished jobs exceeding this number are dropped, oldest first
+	defaultMaxHistory = 100
+)
+
+var (
+	ErrJobNotFound     = errors.New("job not found")
+	ErrJobRunning      = errors.New("job with same key is running")
+	ErrJobFinished     = errors.New("job is finished")
+	ErrManagerShutdown = errors.New("job manager is shut down")
+)
+
+var Manager = NewManager()
+
+// Func runs a job, it should return soon after ctx is canceled. Progress reported by
+// job.SetProgress is shown while the job is running.
+type Func func(ctx context.Context, job *Job) (interface{}, error)
+
+type Job struct {
+	ID         string
+	Type       string
+	Key        string
+	Owner      string
+	State      string
+	Progress   interface{}
+	Result     interface{}
+	Error      string
+	CreatedAt  int64
+	StartedAt  int64
+	FinishedAt int64
+
+	mu     sync.RWMutex
+	cancel context.CancelFunc
+}
+
+// Snapshot returns a copy which can be read without lock.
+func (j *Job) Snapshot() *Job {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	return &Job{
+		ID:         j.ID,
+		Type:       j.Type,
+		Key:        j.Key,
+		Owner:      j.Owner,
+		State:      j.State,
+		Progress:   j.Progress,
+		Result:     j.Result,
+		Error:      j.Error,
+		CreatedAt:  j.CreatedAt,
+		StartedAt:  j.StartedAt,
+		FinishedAt: j.FinishedAt,
+	}
+}
+
+// SetProgress replaces progress of job, progress should not be changed after it is set.
+func (j *Job) SetProgress(progress interface{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.Progress = progress
+}
+
+func (j *Job) finished() bool {
+	return j.State == StateSucceeded || j.State == StateFailed || j.State == StateCanceled
+}
+
+type JobManager struct {
+	MaxHistory int
+
+	mu       sync.Mutex
+	jobs     map[string]*Job
+	running  map[string]*Job
+	wg       sync.WaitGroup
+	shutdown bool
+}
+
+func NewManager() *JobManager {
+	return &JobManager{
+		MaxHistory: defaultMaxHistory,
+		jobs:       make(map[string]*Job),
+		running:    make(map[string]*Job),
+	}
+}
+
+// Submit starts fn in background and returns the job at once. Only one job with the same key
+// runs at a time, the running one is returned with ErrJobRunning.
+func (m *JobManager) Submit(typ string, key string, fn Func) (*Job, error) {
+	return m.SubmitFor("", typ, key, fn)
+}
+
+// SubmitFor is Submit with the user who owns the job, owner is empty for jobs of server. Keys
+// of jobs of different owners should not collide.
+func (m *JobManager) SubmitFor(owner string, typ string, key string, fn Func) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.shutdown {
+		return nil, ErrManagerShutdown
+	}
+
+	if j, ok := m.running[key]; ok {
+		return j.Snapshot(), ErrJobRunning
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &Job{
+		ID:        id,
+		Type:      typ,
+		Key:       key,
+		Owner:     owner,
+		State:     StatePending,
+		CreatedAt: time.Now().Unix(),
+		cancel:    cancel,
+	}
+
+	m.jobs[id] = j
+	m.running[key] = j
+	m.trim()
+
+	m.wg.Add(1)
+	go m.run(ctx, j, fn)
+
+	return j.Snapshot(), nil
+}
+
+func (m *JobManager) run(ctx context.Context, j *Job, fn Func) {
+	defer m.wg.Done()
+	defer j.cancel()
+
+	j.mu.Lock()
+	j.State = StateRunning
+	j.StartedAt = time.Now().Unix()
+	j.mu.Unlock()
+
+	log.Infow("job started", "id", j.ID, "type", j.Type, "key", j.Key)
+
+	result, err := runSafely(ctx, j, fn)
+
+	j.mu.Lock()
+	j.Result = result
+	j.FinishedAt = time.Now().Unix()
+	switch {
+	case err == nil:
+		j.State = StateSucceeded
+	case ctx.Err() != nil:
+		j.State = StateCanceled
+		j.Error = err.Error()
+	default:
+		j.State = StateFailed
+		j.Error = err.Error()
+	}
+	state := j.State
+	j.mu.Unlock()
+
+	m.mu.Lock()
+	delete(m.running, j.Key)
+	m.mu.Unlock()
+
+	if err != nil && state == StateFailed {
+		log.Errorf("job %s %s failed:\n%+v", j.Type, j.ID, err)
+	} else {
+		log.Infow("job finished", "id", j.ID, "type", j.Type, "key", j.Key, "state", state)
+	}
+}
+
+func runSafely(ctx context.Context, j *Job, fn Func) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("job panic: %v", r)
+		}
+	}()
+
+	return fn(ctx, j)
+}
+
+// trim drops the oldest finished jobs exceeding MaxHistory, it should be called with lock held.
+func (m *JobManager) trim() {
+	if m.MaxHistory <= 0 || len(m.jobs) <= m.MaxHistory {
+		return
+	}
+
+	finished := make([]*Job, 0)
+	for _, j := range m.jobs {
+		j.mu.RLock()
+		if j.finished() {
+			finished = append(finished, j)
+		}
+		j.mu.RUnlock()
+	}
+
+	sort.Slice(finished, func(a, b int) bool {
+		return finished[a].CreatedAt < finished[b].CreatedAt
+	})
+
+	for _, j := range finished {
+		if len(m.jobs) <= m.MaxHistory {
+			break
+		}
+		delete(m.jobs, j.ID)
+	}
+}
+
+func (m *JobManager) Get(id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+
+	return j.Snapshot(), nil
+}
+
+// List returns jobs newest first, jobs of all types are returned if typ is empty.
+func (m *JobManager) List(typ string) []*Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := make([]*Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		if typ == "" || j.Type == typ {
+			jobs = append(jobs, j.Snapshot())
+		}
+	}
+
+	sort.Slice(jobs, func(a, b int) bool {
+		if jobs[a].CreatedAt != jobs[b].CreatedAt {
+			return jobs[a].CreatedAt > jobs[b].CreatedAt
+		}
+		return jobs[a].ID < jobs[b].ID
+	})
+
+	return jobs
+}
+
+// Cancel asks a running job to stop, the job state becomes canceled when it returns.
+func (m *JobManager) Cancel(id string) error {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	m.mu.Unlock()
+	if !ok {
+		return ErrJobNotFound
+	}
+
+	j.mu.RLock()
+	finished := j.finished()
+	j.mu.RUnlock()
+	if finished {
+		return ErrJobFinished
+	}
+
+	j.cancel()
+
+	return nil
+}
+
+// Shutdown rejects new jobs, cancels running jobs and waits until they return or ctx is done.
+func (m *JobManager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	m.shutdown = true
+	for _, j := range m.running {
+		j.cancel()
+	}
+	m.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "failed to wait for running jobs")
+	}
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to generate job id")
+	}
+
+	return hex.EncodeToString(b), nil
+}
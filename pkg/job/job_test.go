@@ -0,0 +1,140 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func waitJob(t *testing.T, m *JobManager, id string) *Job {
+	t.Helper()
+
+	for i := 0; i < 200; i++ {
+		j, err := m.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if j.finished() {
+			return j
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatalf("job %s is not finished", id)
+	return nil
+}
+
+func TestJobManager(t *testing.T) {
+	m := NewManager()
+
+	release := make(chan struct{})
+	j, err := m.Submit("sync", "sync:fs714", func(ctx context.Context, j *Job) (interface{}, error) {
+		j.SetProgress(1)
+		<-release
+		return "done", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	running, err := m.Submit("sync", "sync:fs714", func(ctx context.Context, j *Job) (interface{}, error) {
+		return nil, nil
+	})
+	if !errors.Is(err, ErrJobRunning) || running.ID != j.ID {
+		t.Errorf("submit same key returns %v, %v", running, err)
+	}
+
+	close(release)
+	j = waitJob(t, m, j.ID)
+	if j.State != StateSucceeded || j.Result != "done" || j.Progress != 1 || j.FinishedAt == 0 {
+		t.Errorf("finished job is %+v", j)
+	}
+
+	failed, err := m.Submit("sync", "sync:fs714", func(ctx context.Context, j *Job) (interface{}, error) {
+		return nil, errors.New("boom")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if failed = waitJob(t, m, failed.ID); failed.State != StateFailed || failed.Error != "boom" {
+		t.Errorf("failed job is %+v", failed)
+	}
+
+	if err = m.Cancel(failed.ID); !errors.Is(err, ErrJobFinished) {
+		t.Errorf("cancel finished job returns %v", err)
+	}
+
+	if _, err = m.Get("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("get missing job returns %v", err)
+	}
+
+	if jobs := m.List("sync"); len(jobs) != 2 {
+		t.Errorf("got %d jobs, expected 2", len(jobs))
+	}
+}
+
+func TestJobManagerCancelAndShutdown(t *testing.T) {
+	m := NewManager()
+
+	block := func(ctx context.Context, j *Job) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	canceled, err := m.Submit("sync", "a", block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.Cancel(canceled.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if canceled = waitJob(t, m, canceled.ID); canceled.State != StateCanceled {
+		t.Errorf("canceled job is %+v", canceled)
+	}
+
+	running, err := m.Submit("sync", "b", block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = m.Shutdown(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if running, _ = m.Get(running.ID); running.State != StateCanceled {
+		t.Errorf("job after shutdown is %+v", running)
+	}
+
+	if _, err = m.Submit("sync", "c", block); !errors.Is(err, ErrManagerShutdown) {
+		t.Errorf("submit after shutdown returns %v", err)
+	}
+}
+
+func TestJobManagerTrim(t *testing.T) {
+	m := NewManager()
+	m.MaxHistory = 2
+
+	for i := 0; i < 4; i++ {
+		j, err := m.Submit("sync", "a", func(ctx context.Context, j *Job) (interface{}, error) {
+			return nil, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		waitJob(t, m, j.ID)
+	}
+
+	if jobs := m.List(""); len(jobs) != 2 {
+		t.Errorf("got %d jobs, expected 2", len(jobs))
+	}
+}
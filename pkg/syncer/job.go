@@ -0,0 +1,43 @@
+package syncer
+
+import (
+	"context"
+	"strings"
+
+	"github.com/fs714/github-star-manager/pkg/job"
+	"github.com/fs714/github-star-manager/pkg/tenant"
+)
+
+const JobType = "sync"
+
+// JobKey identifies sync of user by owner of the job, owner is empty if auth is disabled.
+func JobKey(owner string, user string) string {
+	if owner == "" {
+		return JobType + ":" + strings.ToLower(user)
+	}
+
+	return JobType + ":" + owner + ":" + strings.ToLower(user)
+}
+
+// SubmitSync starts a sync of user into database of t as background job, only one sync of a
+// user runs at a time in a tenant, the running job is returned with job.ErrJobRunning. Sync
+// history is saved with the job id.
+func SubmitSync(t *tenant.Tenant, user string, opts Options) (*job.Job, error) {
+	return submitSync(t, user, opts, nil)
+}
+
+func submitSync(t *tenant.Tenant, user string, opts Options, done func(result *Result, err error)) (*job.Job, error) {
+	return job.Manager.SubmitFor(t.Name, JobType, JobKey(t.Name, user), func(ctx context.Context, j *job.Job) (interface{}, error) {
+		opts.ID = j.ID
+		opts.Owner = t.Name
+		result, err := Sync(ctx, t.Store, t.Client, user, opts, func(r Result) {
+			j.SetProgress(r)
+		})
+
+		if done != nil {
+			done(result, err)
+		}
+
+		return result, err
+	})
+}
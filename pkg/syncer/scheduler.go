@@ -0,0 +1,210 @@
+package syncer
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/fs714/github-star-manager/pkg/config"
+	"github.com/fs714/github-star-manager/pkg/job"
+	"github.com/fs714/github-star-manager/pkg/tenant"
+	"github.com/fs714/github-star-manager/pkg/utils/log"
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+)
+
+// ScheduleStateSkipped means a scheduled sync is not started since the last one is still running
+const ScheduleStateSkipped = "skipped"
+
+// CurrentScheduler is nil if scheduled sync is disabled
+var CurrentScheduler *Scheduler
+
+type ScheduleEntry struct {
+	User       string
+	NextRunAt  int64
+	LastRunAt  int64
+	LastJobID  string
+	LastState  string
+	LastError  string
+	LastResult *Result
+
+	next time.Time
+}
+
+// Scheduler submits sync jobs of configured users by cron expression or interval. Jobs always sync
+// into the server database, which belongs to admin user if auth is enabled, other users sync on
+// demand.
+type Scheduler struct {
+	Spec   string
+	Jitter time.Duration
+
+	schedule cron.Schedule
+	mu       sync.Mutex
+	entries  []*ScheduleEntry
+}
+
+// NewScheduler returns nil if no user is configured or neither cron nor interval is set.
+func NewScheduler(cfg config.Sync) (*Scheduler, error) {
+	if len(cfg.Users) == 0 || (cfg.Cron == "" && cfg.Interval <= 0) {
+		return nil, nil
+	}
+
+	s := &Scheduler{Jitter: time.Duration(cfg.Jitter) * time.Second}
+	if cfg.Cron != "" {
+		schedule, err := cron.ParseStandard(cfg.Cron)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid sync cron %q", cfg.Cron)
+		}
+		s.Spec = cfg.Cron
+		s.schedule = schedule
+	} else {
+		interval := time.Duration(cfg.Interval) * time.Minute
+		s.Spec = "@every " + interval.String()
+		s.schedule = cron.Every(interval)
+	}
+
+	now := time.Now()
+	seen := make(map[string]bool)
+	for _, user := range cfg.Users {
+		if seen[JobKey("", user)] {
+			continue
+		}
+		seen[JobKey("", user)] = true
+
+		e := &ScheduleEntry{User: user}
+		e.setNext(s.nextRun(now))
+		s.entries = append(s.entries, e)
+	}
+
+	return s, nil
+}
+
+func InitSchedulerFromConfig() error {
+	s, err := NewScheduler(config.Config.Sync)
+	if err != nil {
+		return errors.WithMessage(err, "failed to init sync scheduler from config")
+	}
+
+	CurrentScheduler = s
+
+	return nil
+}
+
+func (e *ScheduleEntry) setNext(next time.Time) {
+	e.next = next
+	e.NextRunAt = next.Unix()
+}
+
+func (s *Scheduler) nextRun(t time.Time) time.Time {
+	next := s.schedule.Next(t)
+	if s.Jitter > 0 {
+		next = next.Add(time.Duration(rand.Int63n(int64(s.Jitter))))
+	}
+
+	return next
+}
+
+// Entries returns copies of schedule of all users.
+func (s *Scheduler) Entries() []ScheduleEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]ScheduleEntry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, *e)
+	}
+
+	return entries
+}
+
+// Run submits sync jobs when they are due until ctx is canceled.
+func (s *Scheduler) Run(ctx context.Context) {
+	log.Infow("sync scheduler started", "spec", s.Spec, "jitter", s.Jitter.String())
+
+	for {
+		timer := time.NewTimer(time.Until(s.earliest()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			log.Infow("sync scheduler exit")
+			return
+		case <-timer.C:
+		}
+
+		s.runDue(time.Now())
+	}
+}
+
+func (s *Scheduler) earliest() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var earliest time.Time
+	for _, e := range s.entries {
+		if earliest.IsZero() || e.next.Before(earliest) {
+			earliest = e.next
+		}
+	}
+
+	return earliest
+}
+
+func (s *Scheduler) runDue(now time.Time) {
+	s.mu.Lock()
+	due := make([]*ScheduleEntry, 0)
+	for _, e := range s.entries {
+		if !e.next.After(now) {
+			due = append(due, e)
+			e.setNext(s.nextRun(now))
+		}
+	}
+	s.mu.Unlock()
+
+	for _, e := range due {
+		s.trigger(e, now)
+	}
+}
+
+// trigger submits sync of e, lock is held during submit so the job can not report its outcome
+// before it is recorded as running.
+func (s *Scheduler) trigger(e *ScheduleEntry, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, err := submitSync(tenant.Default(), e.User, Options{}, func(result *Result, err error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		e.LastResult = result
+		switch {
+		case err == nil:
+			e.LastState = job.StateSucceeded
+			e.LastError = ""
+		case errors.Is(err, context.Canceled):
+			e.LastState = job.StateCanceled
+			e.LastError = err.Error()
+		default:
+			e.LastState = job.StateFailed
+			e.LastError = err.Error()
+		}
+	})
+
+	e.LastRunAt = now.Unix()
+	switch {
+	case errors.Is(err, job.ErrJobRunning):
+		e.LastState = ScheduleStateSkipped
+		e.LastError = ""
+		log.Infow("skip scheduled sync since last one is running", "user", e.User, "job", j.ID)
+	case err != nil:
+		e.LastState = job.StateFailed
+		e.LastError = err.Error()
+		log.Errorf("failed to submit scheduled sync of %s:\n%+v", e.User, err)
+	default:
+		e.LastJobID = j.ID
+		e.LastState = job.StateRunning
+		e.LastError = ""
+		e.LastResult = nil
+		log.Infow("scheduled sync submitted", "user", e.User, "job", j.ID)
+	}
+}
@@ -0,0 +1,94 @@
+package syncer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fs714/github-star-manager/pkg/config"
+	"github.com/fs714/github-star-manager/pkg/job"
+	"github.com/google/go-github/v50/github"
+)
+
+func TestNewScheduler(t *testing.T) {
+	cases := []struct {
+		cfg     config.Sync
+		enabled bool
+		spec    string
+		valid   bool
+	}{
+		{config.Sync{Interval: 10}, false, "", true},
+		{config.Sync{Users: []string{"fs714"}}, false, "", true},
+		{config.Sync{Users: []string{"fs714"}, Interval: 90}, true, "@every 1h30m0s", true},
+		{config.Sync{Users: []string{"fs714"}, Cron: "0 */6 * * *", Interval: 90}, true, "0 */6 * * *", true},
+		{config.Sync{Users: []string{"fs714"}, Cron: "invalid"}, false, "", false},
+	}
+
+	for i, c := range cases {
+		s, err := NewScheduler(c.cfg)
+		if (err == nil) != c.valid {
+			t.Errorf("case %d: error is %v", i, err)
+			continue
+		}
+
+		if (s != nil) != c.enabled {
+			t.Errorf("case %d: scheduler is %v", i, s)
+			continue
+		}
+
+		if s != nil && s.Spec != c.spec {
+			t.Errorf("case %d: spec is %s, expected %s", i, s.Spec, c.spec)
+		}
+	}
+}
+
+func TestSchedulerRunDue(t *testing.T) {
+	initTestStore(t)
+	defer func(f func(context.Context, *github.Client, string, string, time.Time, func(int, int)) ([]*github.StarredRepository, error)) {
+		fetchStarredRepos = f
+	}(fetchStarredRepos)
+
+	var sinces []time.Time
+	stars := []*github.StarredRepository{newStarredRepository(1, "fs714/a", 100)}
+	fakeStars(&stars, &sinces)
+
+	s, err := NewScheduler(config.Sync{Users: []string{"fs714", "FS714"}, Interval: 60, Jitter: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := s.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, expected 1 for duplicated users", len(entries))
+	}
+
+	now := time.Now()
+	if next := time.Unix(entries[0].NextRunAt, 0); next.Before(now.Add(59*time.Minute)) ||
+		next.After(now.Add(61*time.Minute)) {
+		t.Errorf("next run is %s", next)
+	}
+
+	// nothing is due now
+	s.runDue(now)
+	if entries = s.Entries(); entries[0].LastRunAt != 0 {
+		t.Errorf("sync is run before due: %+v", entries[0])
+	}
+
+	s.runDue(now.Add(2 * time.Hour))
+	entries = s.Entries()
+	if entries[0].LastJobID == "" || entries[0].NextRunAt <= now.Add(2*time.Hour).Unix() {
+		t.Fatalf("entry after run is %+v", entries[0])
+	}
+
+	for i := 0; i < 200; i++ {
+		if j, _ := job.Manager.Get(entries[0].LastJobID); j != nil && j.State == job.StateSucceeded {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	entries = s.Entries()
+	if entries[0].LastState != job.StateSucceeded || entries[0].LastResult == nil || entries[0].LastResult.Added != 1 {
+		t.Errorf("entry after sync is %+v", entries[0])
+	}
+}
@@ -0,0 +1,177 @@
+package syncer
+
+import (
+	"context"
+	"time"
+
+	"github.com/fs714/github-star-manager/db"
+	"github.com/fs714/github-star-manager/db/jsondb"
+	"github.com/fs714/github-star-manager/pkg/github_api"
+	"github.com/google/go-github/v50/github"
+	"github.com/pkg/errors"
+)
+
+const (
+	StarActionStar   = "star"
+	StarActionUnstar = "unstar"
+)
+
+// StarResult describes a star or unstar on github and the change of db, nothing is changed if
+// DryRun is true.
+type StarResult struct {
+	Action string
+	Name   string
+	DryRun bool
+	// Starred is whether the repository was starred on github before the action
+	Starred bool
+	// Path and Repository are where and what the repository is in db after the action,
+	// Repository is nil if it is not in db
+	Path       []string
+	Repository *jsondb.Repository
+}
+
+// Star stars repository name on github and saves its metadata from github into db, the token
+// owner is added to StarredBy. A new repository is added to path with tags, a repository kept
+// after unstar is marked starred and moved back from unstarred path.
+func Star(ctx context.Context, store db.Store, client *github.Client, name string, path []string, tags []string,
+	dryRun bool) (*StarResult, error) {
+	account, err := accountOf(ctx, client, "")
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := lockStore(store)
+	defer unlock()
+
+	starred, err := github_api.IsStarred(ctx, client, name)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := github_api.GetRepo(ctx, client, name)
+	if err != nil {
+		return nil, err
+	}
+
+	existPath, exist := findSyncedRepository(store, repo)
+
+	r := &jsondb.Repository{}
+	item := &planItem{path: existPath, existPath: existPath, exist: exist, repo: r}
+	if exist != nil {
+		*r = *exist
+		markStarred(item)
+	} else {
+		item.path = path
+		if item.path == nil {
+			item.path = []string{}
+		}
+		r.Tags = tags
+	}
+
+	updateRepositoryFromGithub(r, &github.StarredRepository{Repository: repo})
+	r.StarredBy = jsondb.AddAccount(r.StarredBy, account)
+	if !starred || r.StarredAt == 0 {
+		r.StarredAt = time.Now().Unix()
+	}
+
+	result := &StarResult{
+		Action:     StarActionStar,
+		Name:       r.Name,
+		DryRun:     dryRun,
+		Starred:    starred,
+		Path:       item.path,
+		Repository: r,
+	}
+
+	if dryRun {
+		return result, nil
+	}
+
+	if !starred {
+		err = github_api.StarRepo(ctx, client, r.Name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = saveItem(store, item)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Unstar unstars repository name on github and removes the token owner from StarredBy. The
+// repository in db is deleted if remove is true, otherwise it is kept starred if other accounts
+// star it, or CurrentUnstarredPolicy is applied like a full sync finds it unstarred.
+func Unstar(ctx context.Context, store db.Store, client *github.Client, name string, remove bool,
+	dryRun bool) (*StarResult, error) {
+	account, err := accountOf(ctx, client, "")
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := lockStore(store)
+	defer unlock()
+
+	_, _, exist := store.GetAllRepositoryByName(name)
+	if exist != nil {
+		name = exist.Name
+	}
+
+	starred, err := github_api.IsStarred(ctx, client, name)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &StarResult{
+		Action:  StarActionUnstar,
+		Name:    name,
+		DryRun:  dryRun,
+		Starred: starred,
+	}
+
+	var item *planItem
+	if exist != nil && !remove {
+		others := jsondb.RemoveAccount(exist.StarredBy, account)
+		switch {
+		case len(others) > 0:
+			item = newKeptItem(store, exist)
+		case CurrentUnstarredPolicy.Policy != UnstarredPolicyDrop:
+			item = newUnstarredItem(store, exist, CurrentUnstarredPolicy, time.Now().Unix())
+		}
+
+		if item != nil {
+			item.repo.StarredBy = others
+			result.Path = item.path
+			result.Repository = item.repo
+		}
+	}
+
+	if dryRun {
+		return result, nil
+	}
+
+	if starred {
+		err = github_api.UnstarRepo(ctx, client, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case item != nil:
+		err = saveItem(store, item)
+	case exist != nil:
+		err = store.DeleteRepository(exist.Name)
+		if err != nil {
+			err = errors.Wrapf(err, "failed to delete repository %s", exist.Name)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
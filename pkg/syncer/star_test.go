@@ -0,0 +1,155 @@
+package syncer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/fs714/github-star-manager/db"
+	"github.com/fs714/github-star-manager/db/jsondb"
+	"github.com/fs714/github-star-manager/pkg/config"
+	"github.com/fs714/github-star-manager/pkg/github_api"
+	"github.com/google/go-github/v50/github"
+)
+
+// newFakeGithub serves repositories and star endpoints of github api, ids maps full name to id
+func newFakeGithub(t *testing.T, ids map[string]int64, starred map[string]bool) *github.Client {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/user/starred/", func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path[len("/user/starred/"):]
+		switch {
+		case ids[name] == 0 || (r.Method == http.MethodGet && !starred[name]):
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case r.Method == http.MethodPut:
+			starred[name] = true
+		case r.Method == http.MethodDelete:
+			delete(starred, name)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"login": "fs714"}`))
+	})
+	mux.HandleFunc("/repos/", func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path[len("/repos/"):]
+		if ids[name] == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"id": ` + strconv.FormatInt(ids[name], 10) + `, "full_name": "` + name +
+			`", "stargazers_count": 42}`))
+	})
+
+	// serve like a GitHub Enterprise Server
+	server := httptest.NewServer(http.StripPrefix("/api/v3", mux))
+	t.Cleanup(server.Close)
+
+	client, err := github_api.NewClientFromConfig(config.Github{BaseURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client
+}
+
+func TestStarAndUnstar(t *testing.T) {
+	initTestStore(t)
+	defer func(p *UnstarredPolicy) { CurrentUnstarredPolicy = p }(CurrentUnstarredPolicy)
+	CurrentUnstarredPolicy = &UnstarredPolicy{Policy: UnstarredPolicyArchive, Path: []string{"unstarred"}}
+
+	starred := map[string]bool{}
+	client := newFakeGithub(t, map[string]int64{"fs714/gsm": 1, "fs714/old": 2}, starred)
+	ctx := context.Background()
+
+	result, err := Star(ctx, db.Storage, client, "fs714/gsm", []string{"tool"}, []string{"go"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.DryRun || result.Starred || result.Repository.StarsCount != 42 || starred["fs714/gsm"] {
+		t.Errorf("dry run star result is %+v, starred %v", result, starred)
+	}
+
+	if _, _, r := db.Storage.GetAllRepositoryByName("fs714/gsm"); r != nil {
+		t.Fatalf("dry run adds repository %+v", r)
+	}
+
+	_, err = Star(ctx, db.Storage, client, "fs714/gsm", []string{"tool"}, []string{"go"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, _, r := db.Storage.GetAllRepositoryByName("fs714/gsm")
+	if !starred["fs714/gsm"] || r == nil || r.ID != 1 || r.StarredAt == 0 || len(r.Tags) != 1 ||
+		!equalPath(path, []string{"tool"}) || !equalPath(r.StarredBy, []string{"fs714"}) {
+		t.Fatalf("starred repository is %+v at %v, starred %v", r, path, starred)
+	}
+
+	if _, err = Star(ctx, db.Storage, client, "fs714/missing", nil, nil, false); err == nil {
+		t.Error("star missing repository succeeds")
+	}
+
+	// unstar applies unstarred policy, and star again moves it back
+	result, err = Unstar(ctx, db.Storage, client, "fs714/gsm", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, _, r = db.Storage.GetAllRepositoryByName("fs714/gsm")
+	if !result.Starred || starred["fs714/gsm"] || r == nil || r.UnstarredAt == 0 || !equalPath(path, []string{"unstarred"}) {
+		t.Fatalf("unstarred repository is %+v at %v, starred %v", r, path, starred)
+	}
+
+	_, err = Star(ctx, db.Storage, client, "fs714/gsm", nil, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if path, _, r = db.Storage.GetAllRepositoryByName("fs714/gsm"); r.UnstarredAt != 0 || !equalPath(path, []string{"tool"}) {
+		t.Errorf("restarred repository is %+v at %v", r, path)
+	}
+
+	result, err = Unstar(ctx, db.Storage, client, "fs714/gsm", true, true)
+	if err != nil || !result.DryRun || !starred["fs714/gsm"] {
+		t.Fatalf("dry run unstar returns %+v, %v, starred %v", result, err, starred)
+	}
+
+	_, err = Unstar(ctx, db.Storage, client, "fs714/gsm", true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, r = db.Storage.GetAllRepositoryByName("fs714/gsm"); r != nil || starred["fs714/gsm"] {
+		t.Errorf("removed repository is %+v, starred %v", r, starred)
+	}
+
+	// repository in db but not starred on github is only updated in db
+	err = db.Storage.AddRepository([]string{}, &jsondb.Repository{ID: 2, Name: "fs714/old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = Unstar(ctx, db.Storage, client, "fs714/old", false, false)
+	if err != nil || result.Starred {
+		t.Fatalf("unstar of unstarred repository returns %+v, %v", result, err)
+	}
+
+	// repository starred by another synced account is kept starred
+	err = db.Storage.AddRepository([]string{}, &jsondb.Repository{ID: 3, Name: "team/shared",
+		StarredBy: []string{"alice", "fs714"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = Unstar(ctx, db.Storage, client, "team/shared", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, r = db.Storage.GetAllRepositoryByName("team/shared"); r.UnstarredAt != 0 || !equalPath(r.StarredBy, []string{"alice"}) {
+		t.Errorf("shared repository is %+v", r)
+	}
+}
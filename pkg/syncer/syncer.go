@@ -0,0 +1,604 @@
+package syncer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fs714/github-star-manager/db"
+	"github.com/fs714/github-star-manager/db/jsondb"
+	"github.com/fs714/github-star-manager/pkg/config"
+	"github.com/fs714/github-star-manager/pkg/github_api"
+	"github.com/fs714/github-star-manager/pkg/utils/log"
+	"github.com/google/go-github/v50/github"
+	"github.com/pkg/errors"
+)
+
+// fetchStarredRepos and getLogin are replaced in tests
+var (
+	fetchStarredRepos = github_api.GetStarredReposSince
+	getLogin          = github_api.GetLogin
+)
+
+// storeLocks serializes syncs, stars and unstars of a store, so a plan is never computed while
+// another one is saved. Syncs of different accounts of a tenant share the store.
+var (
+	storeLocks     = make(map[db.Store]*sync.Mutex)
+	storeLocksLock sync.Mutex
+)
+
+// lockStore locks store for sync and returns the function unlocking it.
+func lockStore(store db.Store) func() {
+	storeLocksLock.Lock()
+	l, ok := storeLocks[store]
+	if !ok {
+		l = &sync.Mutex{}
+		storeLocks[store] = l
+	}
+	storeLocksLock.Unlock()
+
+	l.Lock()
+
+	return l.Unlock
+}
+
+type Options struct {
+	// Full forces a full sync, see Sync
+	Full bool
+	// DryRun only computes the diff, neither repositories nor sync state and history are saved
+	DryRun bool
+	// ID identifies the sync history, a random one is generated if it is empty
+	ID string
+	// Owner is the tenant owning the store, checkpoints of listing stars are kept per owner
+	Owner string
+}
+
+type Result struct {
+	ID   string
+	User string
+	// Account is recorded in StarredBy of synced repositories, it is login of token owner if
+	// User is empty
+	Account string
+	Full    bool
+	DryRun  bool
+	Pages   int
+	Fetched int
+	Added   int
+	// Updated is the number of repositories renamed or changed, see Diff
+	Updated int
+	Removed int
+	Diff    *jsondb.SyncDiff
+}
+
+// Sync fetches stars of user from github into store. Only stars newer than the last sync are
+// fetched unless opts.Full is true or the last full sync is older than github.full_sync_interval,
+// a full sync removes the account of user from repositories it no longer stars and applies
+// CurrentUnstarredPolicy to those starred by no account, repositories of other accounts are
+// kept. Fetching stops
+// when ctx is canceled and nothing is saved then. Stars are fetched concurrently, but syncs of
+// a store are planned and saved one by one, and all repositories are merged into db in one
+// write. onProgress receives a copy of result after every fetched page and when sync is
+// finished if it is not nil. The diff against
+// db is returned in result and saved as sync history unless opts.DryRun is true.
+func Sync(ctx context.Context, store db.Store, client *github.Client, user string, opts Options,
+	onProgress func(Result)) (*Result, error) {
+	key := strings.ToLower(user)
+	now := time.Now()
+
+	id := opts.ID
+	if id == "" {
+		var err error
+		id, err = newHistoryID()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	account, err := accountOf(ctx, client, user)
+	if err != nil {
+		return nil, err
+	}
+
+	state := store.GetSyncState(key)
+	if state == nil {
+		state = &jsondb.SyncState{}
+	}
+
+	// repositories synced before accounts are recorded have no StarredBy, they are claimed by
+	// the first full sync of the user synced then
+	claimLegacy := state.LastFullSyncAt != 0 && state.Account == ""
+
+	full := opts.Full
+	if !full && (claimLegacy || needFullSync(state, now)) {
+		full = true
+	}
+
+	var since time.Time
+	if !full {
+		since = time.Unix(state.LastStarredAt, 0)
+	}
+
+	result := &Result{ID: id, User: user, Account: account, Full: full, DryRun: opts.DryRun}
+	report := func() {
+		if onProgress != nil {
+			onProgress(*result)
+		}
+	}
+
+	statsBefore := github_api.CurrentCacheStats()
+	repos, err := fetchStarredRepos(ctx, client, opts.Owner, user, since, func(pages int, fetched int) {
+		result.Pages = pages
+		result.Fetched = fetched
+		report()
+	})
+	statsAfter := github_api.CurrentCacheStats()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get starred repos from github")
+	}
+
+	err = ctx.Err()
+	if err != nil {
+		return nil, errors.Wrap(err, "sync is canceled")
+	}
+
+	unlock := lockStore(store)
+	defer unlock()
+
+	result.Fetched = len(repos)
+	p := newPlan(store, repos, account, claimLegacy, full, CurrentUnstarredPolicy, now.Unix())
+	result.Diff = p.diff
+	result.Added = len(p.diff.Added)
+	result.Updated = p.updated
+	result.Removed = len(p.diff.Removed)
+
+	if opts.DryRun {
+		report()
+		log.Infow("sync from github dry run finished", "user", user, "full", full, "fetched", result.Fetched,
+			"added", result.Added, "changed", len(p.diff.Changed), "renamed", len(p.diff.Renamed),
+			"removed", result.Removed)
+
+		return result, nil
+	}
+
+	err = applyPlan(p)
+	if err != nil {
+		return nil, err
+	}
+
+	state.LastSyncAt = now.Unix()
+	state.Account = account
+	if full {
+		state.LastFullSyncAt = now.Unix()
+	}
+
+	for _, repo := range repos {
+		if repo.StarredAt != nil && repo.StarredAt.Unix() > state.LastStarredAt {
+			state.LastStarredAt = repo.StarredAt.Unix()
+		}
+	}
+
+	err = store.UpdateSyncState(key, state)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to update sync state")
+	}
+
+	err = store.AddSyncHistory(&jsondb.SyncHistory{
+		ID:         id,
+		User:       user,
+		Full:       full,
+		StartedAt:  now.Unix(),
+		FinishedAt: time.Now().Unix(),
+		Fetched:    result.Fetched,
+		Diff:       p.diff,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to add sync history")
+	}
+
+	report()
+
+	log.Infow("sync from github finished", "user", user, "full", full, "fetched", result.Fetched,
+		"added", result.Added, "updated", result.Updated, "removed", result.Removed,
+		"cache_hits", statsAfter.Hits-statsBefore.Hits, "cache_misses", statsAfter.Misses-statsBefore.Misses)
+
+	return result, nil
+}
+
+// accountOf returns lower-cased login of user, which is the token owner if user is empty.
+func accountOf(ctx context.Context, client *github.Client, user string) (string, error) {
+	if user != "" {
+		return strings.ToLower(user), nil
+	}
+
+	login, err := getLogin(ctx, client)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ToLower(login), nil
+}
+
+func needFullSync(state *jsondb.SyncState, now time.Time) bool {
+	if state.LastFullSyncAt == 0 || state.LastStarredAt == 0 {
+		return true
+	}
+
+	interval := time.Duration(config.Config.Github.FullSyncInterval) * time.Hour
+
+	return now.Sub(time.Unix(state.LastFullSyncAt, 0)) >= interval
+}
+
+// planItem is one repository saved by sync, exist is nil if it is newly starred. path is where
+// it is saved and existPath is where it is now, exist is deleted if delete is true.
+type planItem struct {
+	path      []string
+	existPath []string
+	exist     *jsondb.Repository
+	repo      *jsondb.Repository
+	delete    bool
+}
+
+func (item *planItem) syncItem() *jsondb.SyncItem {
+	si := &jsondb.SyncItem{ExistPath: item.existPath, Path: item.path, Repository: item.repo, Delete: item.delete}
+	if item.exist != nil {
+		si.Name = item.exist.Name
+	}
+
+	return si
+}
+
+// plan is computed from db before anything is changed, so dry run and real sync report the
+// same diff. Repositories unstarred by account are in items of full sync too, others not
+// starred by account are not.
+type plan struct {
+	store db.Store
+	items []*planItem
+	// updated is the number of existing repositories renamed or changed on github
+	updated int
+	diff    *jsondb.SyncDiff
+}
+
+func newPlan(store db.Store, repos []*github.StarredRepository, account string, claimLegacy bool, full bool,
+	policy *UnstarredPolicy, now int64) *plan {
+	p := &plan{
+		store: store,
+		items: make([]*planItem, 0, len(repos)),
+		diff:  jsondb.NewSyncDiff(),
+	}
+
+	matched := make(map[string]bool)
+	for _, repo := range repos {
+		path, exist := findSyncedRepository(store, repo.Repository)
+
+		r := &jsondb.Repository{}
+		if exist != nil {
+			// copy so the tree in use is not changed before it is saved
+			*r = *exist
+			matched[exist.Name] = true
+		}
+		updateRepositoryFromGithub(r, repo)
+		r.StarredBy = jsondb.AddAccount(r.StarredBy, account)
+
+		item := &planItem{path: path, existPath: path, exist: exist, repo: r}
+		if exist == nil {
+			item.path = []string{}
+			p.diff.Added = append(p.diff.Added, r.Name)
+		} else {
+			if markStarred(item) {
+				p.diff.Restarred = append(p.diff.Restarred, r.Name)
+			}
+
+			fields := changedFields(exist, r)
+			if r.Name != exist.Name {
+				p.diff.Renamed = append(p.diff.Renamed, jsondb.SyncRename{From: exist.Name, To: r.Name})
+			}
+
+			if len(fields) > 0 {
+				p.diff.Changed = append(p.diff.Changed, jsondb.SyncChange{Name: r.Name, Fields: fields})
+			}
+
+			if r.Name != exist.Name || len(fields) > 0 {
+				p.updated++
+			}
+		}
+
+		p.items = append(p.items, item)
+	}
+
+	// unstar is only noticed when all stars are fetched
+	if full {
+		for _, exist := range store.GetAllRepositoryByPath([]string{}) {
+			if matched[exist.Name] {
+				continue
+			}
+
+			owned := jsondb.HasAccount(exist.StarredBy, account) ||
+				(claimLegacy && len(exist.StarredBy) == 0 && exist.UnstarredAt == 0)
+			others := jsondb.RemoveAccount(exist.StarredBy, account)
+
+			switch {
+			case owned && len(others) > 0:
+				// still starred by other accounts
+				p.diff.Removed = append(p.diff.Removed, exist.Name)
+				item := newKeptItem(store, exist)
+				item.repo.StarredBy = others
+				p.items = append(p.items, item)
+			case owned || (len(exist.StarredBy) == 0 && exist.UnstarredAt != 0):
+				if exist.UnstarredAt == 0 || policy.Policy == UnstarredPolicyDrop {
+					p.diff.Removed = append(p.diff.Removed, exist.Name)
+				}
+
+				var item *planItem
+				if policy.Policy == UnstarredPolicyDrop {
+					item = newKeptItem(store, exist)
+					item.delete = true
+				} else {
+					item = newUnstarredItem(store, exist, policy, now)
+					item.repo.StarredBy = others
+				}
+				p.items = append(p.items, item)
+			}
+		}
+		sort.Strings(p.diff.Removed)
+	}
+
+	return p
+}
+
+// newKeptItem keeps exist where it is.
+func newKeptItem(store db.Store, exist *jsondb.Repository) *planItem {
+	path, _, _ := store.GetAllRepositoryByName(exist.Name)
+	r := *exist
+
+	return &planItem{path: path, existPath: path, exist: exist, repo: &r}
+}
+
+// newUnstarredItem keeps exist by policy, repositories already unstarred keep their UnstarredAt.
+func newUnstarredItem(store db.Store, exist *jsondb.Repository, policy *UnstarredPolicy, now int64) *planItem {
+	path, _, _ := store.GetAllRepositoryByName(exist.Name)
+
+	r := *exist
+	if r.UnstarredAt == 0 {
+		r.UnstarredAt = now
+	}
+
+	item := &planItem{path: path, existPath: path, exist: exist, repo: &r}
+	if policy.Policy == UnstarredPolicyArchive && !equalPath(path, policy.Path) {
+		if r.UnstarredFrom == nil {
+			r.UnstarredFrom = path
+		}
+		item.path = policy.Path
+	}
+
+	return item
+}
+
+// markStarred clears unstarred mark of item and moves it back if it is archived, false is
+// returned if it is not unstarred.
+func markStarred(item *planItem) bool {
+	r := item.repo
+	if r.UnstarredAt == 0 {
+		return false
+	}
+
+	if r.UnstarredFrom != nil {
+		item.path = r.UnstarredFrom
+	}
+	r.UnstarredAt = 0
+	r.UnstarredFrom = nil
+
+	return true
+}
+
+// changedFields compares metadata from github, fields set by user are never changed by sync.
+func changedFields(old *jsondb.Repository, cur *jsondb.Repository) []jsondb.FieldChange {
+	fields := make([]jsondb.FieldChange, 0)
+	add := func(field string, o string, n string) {
+		if o != n {
+			fields = append(fields, jsondb.FieldChange{Field: field, Old: o, New: n})
+		}
+	}
+
+	add("Url", old.Url, cur.Url)
+	add("Language", old.Language, cur.Language)
+	add("Description", old.Description, cur.Description)
+	add("StarsCount", strconv.Itoa(old.StarsCount), strconv.Itoa(cur.StarsCount))
+	add("ForksCount", strconv.Itoa(old.ForksCount), strconv.Itoa(cur.ForksCount))
+	add("Archived", strconv.FormatBool(old.Archived), strconv.FormatBool(cur.Archived))
+	add("Topics", strings.Join(old.Topics, ","), strings.Join(cur.Topics, ","))
+	add("License", old.License, cur.License)
+	add("Homepage", old.Homepage, cur.Homepage)
+	add("Fork", strconv.FormatBool(old.Fork), strconv.FormatBool(cur.Fork))
+	add("Disabled", strconv.FormatBool(old.Disabled), strconv.FormatBool(cur.Disabled))
+	add("DefaultBranch", old.DefaultBranch, cur.DefaultBranch)
+	add("OwnerType", old.OwnerType, cur.OwnerType)
+
+	return fields
+}
+
+// applyPlan merges all items into db in one write.
+func applyPlan(p *plan) error {
+	items := make([]*jsondb.SyncItem, 0, len(p.items))
+	for _, item := range p.items {
+		items = append(items, item.syncItem())
+	}
+
+	skipped, err := p.store.ApplySync(items)
+	if err != nil {
+		return errors.Wrap(err, "failed to save synced repositories")
+	}
+
+	if len(skipped) > 0 {
+		log.Warnw("synced repositories conflicting with others are skipped", "names", skipped)
+	}
+
+	return nil
+}
+
+// saveItem merges one repository into db to match item.
+func saveItem(store db.Store, item *planItem) error {
+	skipped, err := store.ApplySync([]*jsondb.SyncItem{item.syncItem()})
+	if err != nil {
+		return errors.Wrapf(err, "failed to save repository %s", item.repo.Name)
+	}
+
+	if len(skipped) > 0 {
+		return errors.Wrapf(jsondb.ErrRepositoryExists, "failed to save repository %s", item.repo.Name)
+	}
+
+	return nil
+}
+
+// findSyncedRepository finds the stored repository of a github repository by id first, so tags
+// and folder are kept after the repository is renamed or transferred. Repositories stored before
+// id is recorded are matched by name.
+func findSyncedRepository(store db.Store, repo *github.Repository) ([]string, *jsondb.Repository) {
+	if repo.ID != nil {
+		path, _, r := store.GetRepositoryByID(*repo.ID)
+		if r != nil {
+			return path, r
+		}
+	}
+
+	if repo.FullName != nil {
+		path, _, r := store.GetAllRepositoryByName(*repo.FullName)
+		if r != nil && (r.ID == 0 || repo.ID == nil || r.ID == *repo.ID) {
+			return path, r
+		}
+	}
+
+	return nil, nil
+}
+
+func updateRepositoryFromGithub(r *jsondb.Repository, starred *github.StarredRepository) {
+	repo := starred.Repository
+
+	if starred.StarredAt != nil {
+		r.StarredAt = starred.StarredAt.Unix()
+	}
+
+	if repo.ID != nil {
+		r.ID = *repo.ID
+	}
+
+	if repo.NodeID != nil {
+		r.NodeID = *repo.NodeID
+	}
+
+	if repo.FullName != nil {
+		if r.Name != "" && r.Name != *repo.FullName {
+			r.PreviousNames = appendPreviousName(r.PreviousNames, r.Name, *repo.FullName)
+		}
+		r.Name = *repo.FullName
+	}
+
+	if repo.HTMLURL != nil {
+		r.Url = *repo.HTMLURL
+	}
+
+	if repo.Language != nil {
+		r.Language = *repo.Language
+	}
+
+	if repo.StargazersCount != nil {
+		r.StarsCount = *repo.StargazersCount
+	}
+
+	if repo.ForksCount != nil {
+		r.ForksCount = *repo.ForksCount
+	}
+
+	if repo.Description != nil {
+		r.Description = *repo.Description
+	}
+
+	if repo.Archived != nil {
+		r.Archived = *repo.Archived
+	}
+
+	if repo.Topics != nil {
+		r.Topics = repo.Topics
+	}
+
+	if repo.License != nil {
+		r.License = repo.License.GetSPDXID()
+	}
+
+	if repo.Homepage != nil {
+		r.Homepage = *repo.Homepage
+	}
+
+	if repo.Fork != nil {
+		r.Fork = *repo.Fork
+	}
+
+	if repo.Disabled != nil {
+		r.Disabled = *repo.Disabled
+	}
+
+	if repo.DefaultBranch != nil {
+		r.DefaultBranch = *repo.DefaultBranch
+	}
+
+	if repo.Size != nil {
+		r.Size = *repo.Size
+	}
+
+	if repo.Owner != nil && repo.Owner.Type != nil {
+		r.OwnerType = *repo.Owner.Type
+	}
+
+	if repo.CreatedAt != nil {
+		r.CreatedAt = repo.CreatedAt.Unix()
+	}
+
+	if repo.UpdatedAt != nil {
+		r.UpdatedAt = repo.UpdatedAt.Unix()
+	}
+
+	if repo.PushedAt != nil {
+		r.PushedAt = repo.PushedAt.Unix()
+	}
+}
+
+// appendPreviousName records old name once, current name is removed in case repository is
+// renamed back.
+func appendPreviousName(names []string, old string, current string) []string {
+	result := make([]string, 0, len(names)+1)
+	for _, n := range names {
+		if n != old && n != current {
+			result = append(result, n)
+		}
+	}
+
+	return append(result, old)
+}
+
+func newHistoryID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to generate sync history id")
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func equalPath(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,571 @@
+package syncer
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fs714/github-star-manager/db"
+	"github.com/fs714/github-star-manager/db/jsondb"
+	"github.com/fs714/github-star-manager/pkg/config"
+	"github.com/google/go-github/v50/github"
+)
+
+func initTestStore(t *testing.T) {
+	s, err := db.InitStore(config.Database{Driver: db.DriverJson, Path: filepath.Join(t.TempDir(), "db.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	db.Storage = s
+}
+
+func newStarredRepository(id int64, name string, starredAt int64) *github.StarredRepository {
+	return &github.StarredRepository{
+		StarredAt: &github.Timestamp{Time: time.Unix(starredAt, 0)},
+		Repository: &github.Repository{
+			ID:       github.Int64(id),
+			NodeID:   github.String("R_" + name),
+			FullName: github.String(name),
+		},
+	}
+}
+
+// fakeStars serves stars newest first like github and records since of every fetch
+func fakeStars(stars *[]*github.StarredRepository, sinces *[]time.Time) {
+	fetchStarredRepos = func(ctx context.Context, client *github.Client, owner string, user string, since time.Time,
+		onPage func(int, int)) ([]*github.StarredRepository, error) {
+		*sinces = append(*sinces, since)
+
+		repos := make([]*github.StarredRepository, 0)
+		for _, r := range *stars {
+			if !since.IsZero() && r.StarredAt.Before(since) {
+				break
+			}
+			repos = append(repos, r)
+		}
+		onPage(1, len(repos))
+
+		return repos, nil
+	}
+}
+
+func TestSyncIncremental(t *testing.T) {
+	initTestStore(t)
+	defer func(f func(context.Context, *github.Client, string, string, time.Time, func(int, int)) ([]*github.StarredRepository, error)) {
+		fetchStarredRepos = f
+	}(fetchStarredRepos)
+
+	var sinces []time.Time
+	stars := []*github.StarredRepository{
+		newStarredRepository(2, "fs714/b", 200),
+		newStarredRepository(1, "fs714/a", 100),
+	}
+	fakeStars(&stars, &sinces)
+
+	var progress []Result
+	result, err := Sync(context.Background(), db.Storage, nil, "FS714", Options{}, func(r Result) { progress = append(progress, r) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(progress) != 2 || progress[0].Pages != 1 || progress[1].Added != 2 {
+		t.Errorf("progress is %+v", progress)
+	}
+
+	if !result.Full || result.Added != 2 {
+		t.Errorf("first sync result is %+v, expected full sync adding 2", result)
+	}
+
+	_, _, repo := db.Storage.GetAllRepositoryByName("fs714/b")
+	if repo == nil || repo.StarredAt != 200 || repo.ID != 2 {
+		t.Fatalf("synced repository is %+v", repo)
+	}
+
+	err = db.Storage.MoveRepository("fs714/a", []string{"kept"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stars = append([]*github.StarredRepository{newStarredRepository(3, "fs714/c", 300)}, stars...)
+	result, err = Sync(context.Background(), db.Storage, nil, "fs714", Options{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Full || result.Added != 1 || result.Updated != 0 || result.Fetched != 2 {
+		t.Errorf("incremental sync result is %+v", result)
+	}
+
+	if len(sinces) != 2 || !sinces[0].IsZero() || sinces[1].Unix() != 200 {
+		t.Errorf("fetched since %v", sinces)
+	}
+
+	state := db.Storage.GetSyncState("fs714")
+	if state == nil || state.LastStarredAt != 300 || state.LastFullSyncAt == 0 {
+		t.Errorf("sync state is %+v", state)
+	}
+
+	// unstar is only noticed by full sync, folders and tags are kept
+	stars = stars[:2]
+	result, err = Sync(context.Background(), db.Storage, nil, "fs714", Options{Full: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.Full || result.Removed != 1 || result.Updated != 0 {
+		t.Errorf("full sync result is %+v", result)
+	}
+
+	path, _, repo := db.Storage.GetAllRepositoryByName("fs714/a")
+	if repo == nil || repo.UnstarredAt == 0 || !equalPath(path, []string{"kept"}) {
+		t.Errorf("unstarred repository is %+v at %v, expected to be kept in place", repo, path)
+	}
+
+	if db.Storage.GetRepositories([]string{"kept"}) == nil {
+		t.Error("empty folder is removed by full sync")
+	}
+}
+
+func TestSyncFullInterval(t *testing.T) {
+	now := time.Unix(100000, 0)
+	config.Config.Github.FullSyncInterval = 1
+	defer func() { config.Config.Github.FullSyncInterval = config.DefaultConfig.Github.FullSyncInterval }()
+
+	cases := []struct {
+		state    *jsondb.SyncState
+		expected bool
+	}{
+		{&jsondb.SyncState{}, true},
+		{&jsondb.SyncState{LastFullSyncAt: now.Unix() - 60, LastStarredAt: 1}, false},
+		{&jsondb.SyncState{LastFullSyncAt: now.Unix() - 3600, LastStarredAt: 1}, true},
+	}
+
+	for i, c := range cases {
+		if full := needFullSync(c.state, now); full != c.expected {
+			t.Errorf("case %d: got %v, expected %v", i, full, c.expected)
+		}
+	}
+}
+
+func TestSyncFollowsRenamedRepository(t *testing.T) {
+	initTestStore(t)
+
+	err := db.Storage.AddRepository([]string{"tool"}, &jsondb.Repository{ID: 42, Name: "fs714/gsm", Tags: []string{"go"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renamed := newStarredRepository(42, "someone/gsm", 100)
+
+	path, exist := findSyncedRepository(db.Storage, renamed.Repository)
+	if exist == nil || len(path) != 1 || path[0] != "tool" {
+		t.Fatalf("renamed repository is not matched by id: %v at %v", exist, path)
+	}
+
+	r := *exist
+	updateRepositoryFromGithub(&r, renamed)
+	if r.Name != "someone/gsm" || r.NodeID != "R_someone/gsm" || len(r.PreviousNames) != 1 ||
+		r.PreviousNames[0] != "fs714/gsm" || len(r.Tags) != 1 || r.StarredAt != 100 {
+		t.Errorf("updated repository is %+v", r)
+	}
+
+	// a new repository reusing the old name is a different repository
+	reused := newStarredRepository(43, "fs714/gsm", 100)
+	if _, exist = findSyncedRepository(db.Storage, reused.Repository); exist != nil {
+		t.Errorf("repository with different id is matched: %v", exist)
+	}
+
+	r.PreviousNames = appendPreviousName(r.PreviousNames, "someone/gsm", "fs714/gsm")
+	if len(r.PreviousNames) != 1 || r.PreviousNames[0] != "someone/gsm" {
+		t.Errorf("previous names after renaming back are %v", r.PreviousNames)
+	}
+}
+
+func TestSyncDiffAndDryRun(t *testing.T) {
+	initTestStore(t)
+	defer func(f func(context.Context, *github.Client, string, string, time.Time, func(int, int)) ([]*github.StarredRepository, error)) {
+		fetchStarredRepos = f
+	}(fetchStarredRepos)
+
+	for _, r := range []*jsondb.Repository{
+		{ID: 1, Name: "fs714/a", Language: "Go", StarsCount: 10},
+		{ID: 2, Name: "fs714/old", StarsCount: 5},
+		{ID: 3, Name: "fs714/gone", StarredBy: []string{"fs714"}},
+	} {
+		err := db.Storage.AddRepository([]string{}, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a := newStarredRepository(1, "fs714/a", 300)
+	a.Repository.Language = github.String("Rust")
+	a.Repository.StargazersCount = github.Int(10)
+	a.Repository.Archived = github.Bool(true)
+
+	var sinces []time.Time
+	stars := []*github.StarredRepository{
+		newStarredRepository(4, "fs714/new", 400),
+		a,
+		newStarredRepository(2, "fs714/renamed", 200),
+	}
+	fakeStars(&stars, &sinces)
+
+	result, err := Sync(context.Background(), db.Storage, nil, "fs714", Options{Full: true, DryRun: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff := result.Diff
+	if !result.DryRun || result.Added != 1 || result.Updated != 2 || result.Removed != 1 {
+		t.Errorf("dry run result is %+v", result)
+	}
+
+	if len(diff.Added) != 1 || diff.Added[0] != "fs714/new" || len(diff.Removed) != 1 || diff.Removed[0] != "fs714/gone" {
+		t.Errorf("added %v, removed %v", diff.Added, diff.Removed)
+	}
+
+	if len(diff.Renamed) != 1 || diff.Renamed[0] != (jsondb.SyncRename{From: "fs714/old", To: "fs714/renamed"}) {
+		t.Errorf("renamed %+v", diff.Renamed)
+	}
+
+	if len(diff.Changed) != 1 || diff.Changed[0].Name != "fs714/a" || len(diff.Changed[0].Fields) != 2 ||
+		diff.Changed[0].Fields[0] != (jsondb.FieldChange{Field: "Language", Old: "Go", New: "Rust"}) ||
+		diff.Changed[0].Fields[1] != (jsondb.FieldChange{Field: "Archived", Old: "false", New: "true"}) {
+		t.Errorf("changed %+v", diff.Changed)
+	}
+
+	if _, _, r := db.Storage.GetAllRepositoryByName("fs714/new"); r != nil {
+		t.Error("dry run adds repository")
+	}
+
+	if db.Storage.GetSyncState("fs714") != nil || len(db.Storage.GetSyncHistory("", 0)) != 0 {
+		t.Error("dry run saves sync state or history")
+	}
+
+	result, err = Sync(context.Background(), db.Storage, nil, "fs714", Options{Full: true, ID: "sync-1"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := db.Storage.GetSyncHistoryByID("sync-1")
+	if h == nil || !h.Full || h.User != "fs714" || h.Fetched != 3 || len(h.Diff.Changed) != len(result.Diff.Changed) {
+		t.Fatalf("sync history is %+v", h)
+	}
+
+	if _, _, r := db.Storage.GetAllRepositoryByName("fs714/a"); r == nil || !r.Archived || r.Language != "Rust" {
+		t.Errorf("synced repository is %+v", r)
+	}
+}
+
+func TestSyncImportsGithubFields(t *testing.T) {
+	initTestStore(t)
+	defer func(f func(context.Context, *github.Client, string, string, time.Time, func(int, int)) ([]*github.StarredRepository, error)) {
+		fetchStarredRepos = f
+	}(fetchStarredRepos)
+
+	r := newStarredRepository(1, "fs714/gsm", 100)
+	r.Repository.Topics = []string{"cli", "github"}
+	r.Repository.License = &github.License{SPDXID: github.String("MIT")}
+	r.Repository.Homepage = github.String("https://gsm.example.com")
+	r.Repository.Fork = github.Bool(true)
+	r.Repository.Disabled = github.Bool(false)
+	r.Repository.DefaultBranch = github.String("main")
+	r.Repository.Size = github.Int(512)
+	r.Repository.Owner = &github.User{Login: github.String("fs714"), Type: github.String("User")}
+
+	var sinces []time.Time
+	stars := []*github.StarredRepository{r}
+	fakeStars(&stars, &sinces)
+
+	_, err := Sync(context.Background(), db.Storage, nil, "fs714", Options{Full: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, repo := db.Storage.GetAllRepositoryByName("fs714/gsm")
+	if repo == nil || !equalPath(repo.Topics, []string{"cli", "github"}) || repo.License != "MIT" ||
+		repo.Homepage != "https://gsm.example.com" || !repo.Fork || repo.DefaultBranch != "main" || repo.Size != 512 ||
+		repo.OwnerType != "User" {
+		t.Fatalf("synced repository is %+v", repo)
+	}
+
+	r.Repository.Topics = []string{"cli"}
+	r.Repository.Size = github.Int(600)
+	result, err := Sync(context.Background(), db.Storage, nil, "fs714", Options{Full: true, DryRun: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// size changes on every push, so it is not reported as change
+	if len(result.Diff.Changed) != 1 || len(result.Diff.Changed[0].Fields) != 1 ||
+		result.Diff.Changed[0].Fields[0] != (jsondb.FieldChange{Field: "Topics", Old: "cli,github", New: "cli"}) {
+		t.Errorf("changed %+v", result.Diff.Changed)
+	}
+}
+
+func TestSyncMultipleAccounts(t *testing.T) {
+	initTestStore(t)
+	defer func(f func(context.Context, *github.Client, string, string, time.Time, func(int, int)) ([]*github.StarredRepository, error)) {
+		fetchStarredRepos = f
+	}(fetchStarredRepos)
+
+	stars := map[string][]*github.StarredRepository{
+		"alice": {newStarredRepository(1, "team/a", 100), newStarredRepository(2, "team/b", 100)},
+		"bob":   {newStarredRepository(2, "team/b", 200), newStarredRepository(3, "team/c", 200)},
+	}
+	fetchStarredRepos = func(ctx context.Context, client *github.Client, owner string, user string, since time.Time,
+		onPage func(int, int)) ([]*github.StarredRepository, error) {
+		return stars[strings.ToLower(user)], nil
+	}
+
+	starredBy := func(name string) []string {
+		_, _, r := db.Storage.GetAllRepositoryByName(name)
+		if r == nil || r.UnstarredAt != 0 {
+			return nil
+		}
+
+		return r.StarredBy
+	}
+
+	for _, user := range []string{"alice", "Bob"} {
+		_, err := Sync(context.Background(), db.Storage, nil, user, Options{Full: true}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !equalPath(starredBy("team/a"), []string{"alice"}) || !equalPath(starredBy("team/b"), []string{"alice", "bob"}) ||
+		!equalPath(starredBy("team/c"), []string{"bob"}) {
+		t.Fatalf("starred by %v, %v, %v", starredBy("team/a"), starredBy("team/b"), starredBy("team/c"))
+	}
+
+	// unstarred by alice but still starred by bob
+	stars["alice"] = stars["alice"][:1]
+	result, err := Sync(context.Background(), db.Storage, nil, "alice", Options{Full: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !equalPath(result.Diff.Removed, []string{"team/b"}) || !equalPath(starredBy("team/b"), []string{"bob"}) ||
+		!equalPath(starredBy("team/c"), []string{"bob"}) {
+		t.Errorf("removed %v, team/b starred by %v", result.Diff.Removed, starredBy("team/b"))
+	}
+
+	counts := db.Storage.GetAccountCounts()
+	if len(counts) != 2 || *counts[0] != (jsondb.AccountCount{Account: "alice", Count: 1}) ||
+		*counts[1] != (jsondb.AccountCount{Account: "bob", Count: 2}) {
+		t.Errorf("account counts %+v %+v", counts[0], counts[1])
+	}
+}
+
+func TestSyncClaimsLegacyRepositories(t *testing.T) {
+	initTestStore(t)
+	defer func(f func(context.Context, *github.Client, string, string, time.Time, func(int, int)) ([]*github.StarredRepository, error)) {
+		fetchStarredRepos = f
+	}(fetchStarredRepos)
+	defer func(f func(context.Context, *github.Client) (string, error)) { getLogin = f }(getLogin)
+	getLogin = func(ctx context.Context, client *github.Client) (string, error) {
+		return "FS714", nil
+	}
+
+	// synced by token owner before accounts are recorded
+	err := db.Storage.UpdateSyncState("", &jsondb.SyncState{LastSyncAt: 100, LastFullSyncAt: 100, LastStarredAt: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range []*jsondb.Repository{{ID: 1, Name: "fs714/a"}, {ID: 2, Name: "fs714/gone"}} {
+		err = db.Storage.AddRepository([]string{}, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var sinces []time.Time
+	stars := []*github.StarredRepository{newStarredRepository(1, "fs714/a", 100)}
+	fakeStars(&stars, &sinces)
+
+	result, err := Sync(context.Background(), db.Storage, nil, "", Options{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.Full || result.Account != "fs714" || !equalPath(result.Diff.Removed, []string{"fs714/gone"}) {
+		t.Errorf("legacy sync result is %+v", result)
+	}
+
+	if _, _, r := db.Storage.GetAllRepositoryByName("fs714/gone"); r == nil || r.UnstarredAt == 0 {
+		t.Errorf("legacy repository is %+v", r)
+	}
+
+	if state := db.Storage.GetSyncState(""); state.Account != "fs714" || needFullSync(state, time.Now()) {
+		t.Errorf("sync state is %+v", state)
+	}
+
+	// repositories added by user are not claimed any more
+	err = db.Storage.AddRepository([]string{}, &jsondb.Repository{Name: "fs714/manual"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = Sync(context.Background(), db.Storage, nil, "", Options{Full: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, r := db.Storage.GetAllRepositoryByName("fs714/manual"); r == nil || r.UnstarredAt != 0 || len(result.Diff.Removed) != 0 {
+		t.Errorf("manual repository is %+v, removed %v", r, result.Diff.Removed)
+	}
+}
+
+func TestSyncUnstarredPolicy(t *testing.T) {
+	initTestStore(t)
+	defer func(f func(context.Context, *github.Client, string, string, time.Time, func(int, int)) ([]*github.StarredRepository, error)) {
+		fetchStarredRepos = f
+	}(fetchStarredRepos)
+	defer func(p *UnstarredPolicy) { CurrentUnstarredPolicy = p }(CurrentUnstarredPolicy)
+
+	_, err := NewUnstarredPolicy(config.Sync{UnstarredPolicy: "archive", UnstarredPath: " / "})
+	if err == nil {
+		t.Error("archive policy with empty path is accepted")
+	}
+
+	CurrentUnstarredPolicy, err = NewUnstarredPolicy(config.Sync{UnstarredPolicy: "archive", UnstarredPath: "old/unstarred"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Storage.AddRepository([]string{"linux"}, &jsondb.Repository{ID: 1, Name: "fs714/a", Tags: []string{"linux"},
+		StarredBy: []string{"fs714"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sinces []time.Time
+	stars := []*github.StarredRepository{newStarredRepository(2, "fs714/b", 200)}
+	fakeStars(&stars, &sinces)
+
+	_, err = Sync(context.Background(), db.Storage, nil, "fs714", Options{Full: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, _, repo := db.Storage.GetAllRepositoryByName("fs714/a")
+	if repo == nil || repo.UnstarredAt == 0 || len(repo.Tags) != 1 || !equalPath(path, []string{"old", "unstarred"}) ||
+		!equalPath(repo.UnstarredFrom, []string{"linux"}) {
+		t.Fatalf("archived repository is %+v at %v", repo, path)
+	}
+
+	// starred again on github, incremental sync moves it back
+	stars = append([]*github.StarredRepository{newStarredRepository(1, "fs714/a", 300)}, stars...)
+	result, err := Sync(context.Background(), db.Storage, nil, "fs714", Options{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Full || len(result.Diff.Restarred) != 1 || result.Diff.Restarred[0] != "fs714/a" {
+		t.Errorf("restar sync result is %+v", result)
+	}
+
+	path, _, repo = db.Storage.GetAllRepositoryByName("fs714/a")
+	if repo == nil || repo.UnstarredAt != 0 || repo.UnstarredFrom != nil || !equalPath(path, []string{"linux"}) {
+		t.Fatalf("restarred repository is %+v at %v", repo, path)
+	}
+
+	CurrentUnstarredPolicy = &UnstarredPolicy{Policy: UnstarredPolicyDrop}
+	stars = stars[1:]
+	result, err = Sync(context.Background(), db.Storage, nil, "fs714", Options{Full: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, repo = db.Storage.GetAllRepositoryByName("fs714/a"); repo != nil || result.Removed != 1 {
+		t.Errorf("unstarred repository is not dropped: %+v, result %+v", repo, result)
+	}
+}
+
+func TestSyncMergesChangesAfterPlan(t *testing.T) {
+	initTestStore(t)
+
+	stars := []*github.StarredRepository{newStarredRepository(1, "fs714/a", 100)}
+	err := applyPlan(newPlan(db.Storage, stars, "fs714", false, true, CurrentUnstarredPolicy, 1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stars[0].Repository.StargazersCount = github.Int(10)
+	stars[0].Repository.FullName = github.String("fs714/renamed")
+	p := newPlan(db.Storage, stars, "fs714", false, true, CurrentUnstarredPolicy, 1000)
+
+	// changed by user after sync is planned
+	_, _, repo := db.Storage.GetAllRepositoryByName("fs714/a")
+	edited := *repo
+	edited.Tags = []string{"go"}
+	edited.Note = "note"
+	err = db.Storage.UpdateAndMoveRepository(&edited, []string{"tool"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Storage.AddRepository([]string{"web"}, &jsondb.Repository{Name: "fs714/manual"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = applyPlan(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, _, repo := db.Storage.GetAllRepositoryByName("fs714/renamed")
+	if repo == nil || repo.StarsCount != 10 || repo.Note != "note" || len(repo.Tags) != 1 ||
+		!equalPath(path, []string{"tool"}) {
+		t.Errorf("synced repository is %+v at %v", repo, path)
+	}
+
+	if _, _, repo = db.Storage.GetAllRepositoryByName("fs714/manual"); repo == nil {
+		t.Error("repository added after sync is planned is lost")
+	}
+}
+
+func TestSyncAccountsConcurrently(t *testing.T) {
+	initTestStore(t)
+	defer func(f func(context.Context, *github.Client, string, string, time.Time, func(int, int)) ([]*github.StarredRepository, error)) {
+		fetchStarredRepos = f
+	}(fetchStarredRepos)
+
+	var sinces []time.Time
+	stars := []*github.StarredRepository{newStarredRepository(1, "fs714/a", 100)}
+	fakeStars(&stars, &sinces)
+	fetch := fetchStarredRepos
+	var fetchLock sync.Mutex
+	fetchStarredRepos = func(ctx context.Context, client *github.Client, owner string, user string, since time.Time,
+		onPage func(int, int)) ([]*github.StarredRepository, error) {
+		fetchLock.Lock()
+		defer fetchLock.Unlock()
+
+		return fetch(ctx, client, owner, user, since, onPage)
+	}
+
+	var wg sync.WaitGroup
+	for _, user := range []string{"alice", "bob", "carol"} {
+		wg.Add(1)
+		go func(user string) {
+			defer wg.Done()
+			if _, err := Sync(context.Background(), db.Storage, nil, user, Options{Full: true}, nil); err != nil {
+				t.Error(err)
+			}
+		}(user)
+	}
+	wg.Wait()
+
+	_, _, repo := db.Storage.GetAllRepositoryByName("fs714/a")
+	if repo == nil || len(repo.StarredBy) != 3 {
+		t.Errorf("repository starred by all accounts is %+v", repo)
+	}
+}
@@ -0,0 +1,61 @@
+package syncer
+
+import (
+	"strings"
+
+	"github.com/fs714/github-star-manager/pkg/config"
+	"github.com/pkg/errors"
+)
+
+const (
+	UnstarredPolicyDrop    = "drop"
+	UnstarredPolicyKeep    = "keep"
+	UnstarredPolicyArchive = "archive"
+)
+
+// CurrentUnstarredPolicy is applied by every full sync
+var CurrentUnstarredPolicy = &UnstarredPolicy{Policy: UnstarredPolicyKeep}
+
+// UnstarredPolicy decides what full sync does to repositories no longer starred on github, they
+// are deleted by drop, marked with UnstarredAt in place by keep, or marked and moved to Path by
+// archive. Tags and notes are kept unless they are dropped.
+type UnstarredPolicy struct {
+	Policy string
+	Path   []string
+}
+
+func NewUnstarredPolicy(cfg config.Sync) (*UnstarredPolicy, error) {
+	p := &UnstarredPolicy{Policy: strings.ToLower(strings.TrimSpace(cfg.UnstarredPolicy))}
+
+	switch p.Policy {
+	case "":
+		p.Policy = UnstarredPolicyKeep
+	case UnstarredPolicyDrop, UnstarredPolicyKeep:
+	case UnstarredPolicyArchive:
+		for _, f := range strings.Split(cfg.UnstarredPath, "/") {
+			f = strings.TrimSpace(f)
+			if f != "" {
+				p.Path = append(p.Path, f)
+			}
+		}
+
+		if len(p.Path) == 0 {
+			return nil, errors.New("unstarred path should not be empty for archive policy")
+		}
+	default:
+		return nil, errors.Errorf("invalid unstarred policy %q, expected drop, keep or archive", cfg.UnstarredPolicy)
+	}
+
+	return p, nil
+}
+
+func InitUnstarredPolicyFromConfig() error {
+	p, err := NewUnstarredPolicy(config.Config.Sync)
+	if err != nil {
+		return errors.WithMessage(err, "failed to init unstarred policy from config")
+	}
+
+	CurrentUnstarredPolicy = p
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package tenant
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fs714/github-star-manager/db"
+	"github.com/fs714/github-star-manager/pkg/auth"
+	"github.com/fs714/github-star-manager/pkg/config"
+	"github.com/fs714/github-star-manager/pkg/github_api"
+	"github.com/google/go-github/v50/github"
+	"github.com/pkg/errors"
+)
+
+var currentManager *Manager
+
+// Tenant is the data of one user, repositories are read and written through Store and github
+// is called with Client which uses github token of the user from TokenSource.
+type Tenant struct {
+	Name        string
+	Store       db.Store
+	Client      *github.Client
+	TokenSource string
+}
+
+// Default returns the tenant served when auth is disabled, which is also the admin user. It
+// uses the server database and github client.
+func Default() *Tenant {
+	name := ""
+	if config.Config.Auth.Enabled {
+		name = auth.NormalizeName(config.Config.Auth.AdminUser)
+	}
+
+	return &Tenant{Name: name, Store: db.Storage, Client: github_api.CurrentClient(),
+		TokenSource: config.Config.Github.TokenSource}
+}
+
+// Manager opens databases of users on first use and keeps them open until Remove or Close.
+type Manager struct {
+	admin    string
+	dataDir  string
+	database config.Database
+	github   config.Github
+
+	mu      sync.Mutex
+	tenants map[string]*Tenant
+}
+
+func NewManager(admin string, dataDir string, database config.Database, github config.Github) *Manager {
+	return &Manager{
+		admin:    auth.NormalizeName(admin),
+		dataDir:  dataDir,
+		database: database,
+		github:   github,
+		tenants:  make(map[string]*Tenant),
+	}
+}
+
+func InitManagerFromConfig() {
+	SetManager(NewManager(config.Config.Auth.AdminUser, config.Config.Auth.DataDir, config.Config.Database,
+		config.Config.Github))
+}
+
+func SetManager(m *Manager) {
+	currentManager = m
+}
+
+func CurrentManager() *Manager {
+	return currentManager
+}
+
+// Get returns tenant of user, Default is returned for admin user. Other users always read
+// github token from their own database, token file and env of the server belong to admin.
+func (m *Manager) Get(name string) (*Tenant, error) {
+	name = auth.NormalizeName(name)
+	if name == m.admin {
+		return Default(), nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if t, ok := m.tenants[name]; ok {
+		return t, nil
+	}
+
+	dir := m.dir(name)
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create data dir of user")
+	}
+
+	cfg := m.database
+	cfg.Path = filepath.Join(dir, filepath.Base(m.database.Path))
+	store, err := db.InitStore(cfg)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to open database of user %s", name)
+	}
+
+	source := &github_api.DBTokenSource{GetToken: store.GetGithubToken}
+	client, err := github_api.NewClientWithTokenSource(m.github, source)
+	if err != nil {
+		store.Close()
+		return nil, errors.WithMessagef(err, "failed to create github client of user %s", name)
+	}
+
+	t := &Tenant{Name: name, Store: store, Client: client, TokenSource: github_api.TokenSourceDB}
+	m.tenants[name] = t
+
+	return t, nil
+}
+
+// Remove closes database of user, and deletes data dir of user if purge is true.
+func (m *Manager) Remove(name string, purge bool) error {
+	name = auth.NormalizeName(name)
+	if name == m.admin {
+		return errors.WithMessage(auth.ErrInvalidUser, "data of admin can not be removed")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if t, ok := m.tenants[name]; ok {
+		delete(m.tenants, name)
+		err := t.Store.Close()
+		if err != nil {
+			return errors.WithMessagef(err, "failed to close database of user %s", name)
+		}
+	}
+
+	if purge {
+		err := os.RemoveAll(m.dir(name))
+		if err != nil {
+			return errors.Wrapf(err, "failed to remove data of user %s", name)
+		}
+	}
+
+	return nil
+}
+
+// Close closes databases of all opened tenants except admin.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var err error
+	for name, t := range m.tenants {
+		cerr := t.Store.Close()
+		if cerr != nil && err == nil {
+			err = errors.WithMessagef(cerr, "failed to close database of user %s", name)
+		}
+		delete(m.tenants, name)
+	}
+
+	return err
+}
+
+func (m *Manager) dir(name string) string {
+	return filepath.Join(m.dataDir, name)
+}
@@ -5,4 +5,9 @@ type RespCode int
 const (
 	RespOk RespCode = iota
 	RespCommonError
+	RespInvalidParameter
+	RespNotFound
+	RespConflict
+	RespUnauthorized
+	RespForbidden
 )